@@ -28,6 +28,23 @@ type Accumulator interface {
 		tags map[string]string,
 		t ...time.Time)
 
+	// AddSummary is the same as AddFields, but will add the metric as a "Summary" type.
+	// The fields are the quantiles, keyed by their value such as "0.5", along
+	// with the "count" and "sum" of the observations.
+	AddSummary(measurement string,
+		fields map[string]interface{},
+		tags map[string]string,
+		t ...time.Time)
+
+	// AddHistogram is the same as AddFields, but will add the metric as a "Histogram" type.
+	// The fields are the cumulative counts of the buckets, keyed by their upper
+	// bound such as "0.5" or "+Inf", along with the "count" and "sum" of the
+	// observations.
+	AddHistogram(measurement string,
+		fields map[string]interface{},
+		tags map[string]string,
+		t ...time.Time)
+
 	SetPrecision(precision, interval time.Duration)
 
 	AddError(err error)
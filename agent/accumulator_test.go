@@ -309,6 +309,27 @@ func TestAddCounter(t *testing.T) {
 	assert.Equal(t, testm.Type(), telegraf.Counter)
 }
 
+func TestAddSummaryHistogram(t *testing.T) {
+	metrics := make(chan telegraf.Metric, 10)
+	defer close(metrics)
+	a := NewAccumulator(&TestMetricMaker{}, metrics)
+
+	a.AddSummary("acctest",
+		map[string]interface{}{"0.5": float64(1), "count": float64(3), "sum": float64(4)},
+		map[string]string{"acc": "test"})
+	a.AddHistogram("acctest",
+		map[string]interface{}{"1": float64(2), "+Inf": float64(3), "count": float64(3), "sum": float64(4)},
+		map[string]string{"acc": "test"})
+
+	testm := <-metrics
+	assert.Equal(t, telegraf.Summary, testm.Type())
+	assert.Equal(t, float64(1), testm.Fields()["0.5"])
+
+	testm = <-metrics
+	assert.Equal(t, telegraf.Histogram, testm.Type())
+	assert.Equal(t, float64(2), testm.Fields()["1"])
+}
+
 type TestMetricMaker struct {
 }
 
@@ -335,6 +356,10 @@ func (tm *TestMetricMaker) MakeMetric(
 		if m, err := metric.New(measurement, tags, fields, t, telegraf.Gauge); err == nil {
 			return m
 		}
+	case telegraf.Summary, telegraf.Histogram:
+		if m, err := metric.New(measurement, tags, fields, t, mType); err == nil {
+			return m
+		}
 	}
 	return nil
 }
@@ -155,6 +155,48 @@ func GetTLSConfig(
 	return t, nil
 }
 
+// GetServerTLSConfig gets a tls.Config object for a server from the given
+// cert and key files. When a CA file is given, clients are required to present
+// a certificate signed by it.
+// If all files are blank, returns a nil pointer.
+func GetServerTLSConfig(
+	SSLCert, SSLKey, SSLCA string,
+) (*tls.Config, error) {
+	if SSLCert == "" && SSLKey == "" && SSLCA == "" {
+		return nil, nil
+	}
+
+	if SSLCert == "" || SSLKey == "" {
+		return nil, errors.New("TLS server requires both a key and a certificate")
+	}
+
+	cert, err := tls.LoadX509KeyPair(SSLCert, SSLKey)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf(
+			"Could not load TLS server key/certificate from %s:%s: %s",
+			SSLKey, SSLCert, err))
+	}
+
+	t := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if SSLCA != "" {
+		caCert, err := ioutil.ReadFile(SSLCA)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not load TLS CA: %s",
+				err))
+		}
+
+		caCertPool := x509.NewCertPool()
+		caCertPool.AppendCertsFromPEM(caCert)
+		t.ClientCAs = caCertPool
+		t.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return t, nil
+}
+
 // SnakeCase converts the given string to snake case following the Golang format:
 // acronyms are converted to lower-case and preceded by an underscore.
 func SnakeCase(in string) string {
@@ -0,0 +1,145 @@
+package multiline
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+const (
+	// MatchStart makes the lines matching the pattern start a new event, the
+	// lines not matching it are continuations of the current event.
+	MatchStart = "start"
+	// MatchContinuation makes the lines matching the pattern continuations
+	// of the current event, the lines not matching it start a new event.
+	MatchContinuation = "continuation"
+
+	defaultTimeout = 5 * time.Second
+	defaultMaxSize = 64 * 1024
+
+	// the expired events are checked for this many times per timeout
+	flushIntervalDivisor = 10
+)
+
+// Config holds the multiline options of the plugins tailing files. It must
+// be compiled before creating the Multiline joining the lines of each file.
+type Config struct {
+	// Pattern is the regular expression marking either the start or the
+	// continuation lines of an event, depending on Match.
+	Pattern string `toml:"pattern"`
+	// Match is either MatchStart or MatchContinuation, defaults to
+	// MatchContinuation.
+	Match string `toml:"match"`
+	// InvertMatch negates the pattern.
+	InvertMatch bool `toml:"invert_match"`
+	// Timeout is the time after which an event is flushed when no new line
+	// arrived, defaults to 5s.
+	Timeout internal.Duration `toml:"timeout"`
+	// MaxSize is the maximum size of the events joining lines in bytes,
+	// defaults to 64KiB. When a line would make an event grow past it, the
+	// event is flushed and the line starts a new event. Lines are not split,
+	// so a line longer than MaxSize is an event on its own.
+	MaxSize int `toml:"max_size"`
+
+	re *regexp.Regexp
+}
+
+// Compile checks the configuration and compiles the pattern.
+func (c *Config) Compile() error {
+	switch c.Match {
+	case "":
+		c.Match = MatchContinuation
+	case MatchStart, MatchContinuation:
+	default:
+		return fmt.Errorf("invalid multiline match %q, must be %q or %q",
+			c.Match, MatchStart, MatchContinuation)
+	}
+
+	if c.Pattern == "" {
+		return fmt.Errorf("multiline pattern is required")
+	}
+	re, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid multiline pattern %q: %s", c.Pattern, err)
+	}
+	c.re = re
+
+	if c.Timeout.Duration <= 0 {
+		c.Timeout.Duration = defaultTimeout
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultMaxSize
+	}
+	return nil
+}
+
+// New returns a Multiline joining lines according to the compiled Config.
+// A Multiline is not safe for concurrent use, there should be one for every
+// tailed file.
+func (c *Config) New() *Multiline {
+	return &Multiline{config: c}
+}
+
+// Multiline assembles the lines of a file into multi-line events. Lines of
+// an event are joined with a newline.
+type Multiline struct {
+	config *Config
+
+	buf  bytes.Buffer
+	last time.Time
+}
+
+// Add adds a line read at the given time. It returns the previous event when
+// the line completes it, in which case ok is true.
+func (m *Multiline) Add(line string, now time.Time) (event string, ok bool) {
+	matched := m.config.re.MatchString(line) != m.config.InvertMatch
+
+	var continuation bool
+	if m.config.Match == MatchStart {
+		continuation = !matched
+	} else {
+		continuation = matched
+	}
+
+	if m.buf.Len() > 0 &&
+		(!continuation || m.buf.Len()+1+len(line) > m.config.MaxSize) {
+		event, ok = m.Flush()
+	}
+
+	if m.buf.Len() > 0 {
+		m.buf.WriteByte('\n')
+	}
+	m.buf.WriteString(line)
+	m.last = now
+
+	return event, ok
+}
+
+// Flush returns the current event, if any, and resets the buffer.
+func (m *Multiline) Flush() (event string, ok bool) {
+	if m.buf.Len() == 0 {
+		return "", false
+	}
+	event = m.buf.String()
+	m.buf.Reset()
+	return event, true
+}
+
+// FlushExpired flushes the current event if no line was added to it during
+// the configured timeout.
+func (m *Multiline) FlushExpired(now time.Time) (event string, ok bool) {
+	if now.Sub(m.last) < m.config.Timeout.Duration {
+		return "", false
+	}
+	return m.Flush()
+}
+
+// FlushInterval returns the interval at which receivers should check for
+// expired events. It is a fraction of the timeout, so that events are flushed
+// at most a tenth of the timeout late.
+func (m *Multiline) FlushInterval() time.Duration {
+	return m.config.Timeout.Duration / flushIntervalDivisor
+}
@@ -0,0 +1,112 @@
+package multiline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func addLines(m *Multiline, lines []string) []string {
+	var events []string
+	now := time.Now()
+	for _, line := range lines {
+		if event, ok := m.Add(line, now); ok {
+			events = append(events, event)
+		}
+	}
+	if event, ok := m.Flush(); ok {
+		events = append(events, event)
+	}
+	return events
+}
+
+var stackTrace = []string{
+	"2017-05-03 10:00:00 ERROR something failed",
+	"java.lang.NullPointerException",
+	"    at com.example.Foo.bar(Foo.java:12)",
+	"    at com.example.Foo.main(Foo.java:5)",
+	"2017-05-03 10:00:01 INFO recovered",
+}
+
+func TestMultilineContinuation(t *testing.T) {
+	c := &Config{Pattern: `^\s|^java\.`}
+	require.NoError(t, c.Compile())
+
+	events := addLines(c.New(), stackTrace)
+	assert.Equal(t, []string{
+		"2017-05-03 10:00:00 ERROR something failed\n" +
+			"java.lang.NullPointerException\n" +
+			"    at com.example.Foo.bar(Foo.java:12)\n" +
+			"    at com.example.Foo.main(Foo.java:5)",
+		"2017-05-03 10:00:01 INFO recovered",
+	}, events)
+}
+
+func TestMultilineStart(t *testing.T) {
+	c := &Config{Pattern: `^\d{4}-\d{2}-\d{2} `, Match: MatchStart}
+	require.NoError(t, c.Compile())
+
+	events := addLines(c.New(), stackTrace)
+	assert.Len(t, events, 2)
+	assert.Equal(t, "2017-05-03 10:00:01 INFO recovered", events[1])
+}
+
+func TestMultilineInvertMatch(t *testing.T) {
+	c := &Config{
+		Pattern:     `^\d{4}-\d{2}-\d{2} `,
+		Match:       MatchContinuation,
+		InvertMatch: true,
+	}
+	require.NoError(t, c.Compile())
+
+	events := addLines(c.New(), stackTrace)
+	assert.Len(t, events, 2)
+}
+
+func TestMultilineMaxSize(t *testing.T) {
+	c := &Config{Pattern: `^\s`, MaxSize: 10}
+	require.NoError(t, c.Compile())
+
+	events := addLines(c.New(), []string{"start", " a", " b", " c"})
+	assert.Equal(t, []string{"start\n a", " b\n c"}, events)
+
+	// lines longer than the max size aren't split
+	events = addLines(c.New(), []string{"start", " a very long line", " b"})
+	assert.Equal(t, []string{"start", " a very long line", " b"}, events)
+}
+
+func TestMultilineFlushExpired(t *testing.T) {
+	c := &Config{Pattern: `^\s`}
+	c.Timeout.Duration = time.Second
+	require.NoError(t, c.Compile())
+
+	m := c.New()
+	now := time.Now()
+	_, ok := m.Add("start", now)
+	assert.False(t, ok)
+
+	_, ok = m.FlushExpired(now.Add(500 * time.Millisecond))
+	assert.False(t, ok)
+
+	event, ok := m.FlushExpired(now.Add(time.Second))
+	assert.True(t, ok)
+	assert.Equal(t, "start", event)
+
+	_, ok = m.FlushExpired(now.Add(2 * time.Second))
+	assert.False(t, ok)
+
+	assert.Equal(t, 100*time.Millisecond, m.FlushInterval())
+}
+
+func TestConfigCompileErrors(t *testing.T) {
+	c := &Config{Pattern: `^\s`, Match: "previous"}
+	assert.Error(t, c.Compile())
+
+	c = &Config{}
+	assert.Error(t, c.Compile())
+
+	c = &Config{Pattern: `(`}
+	assert.Error(t, c.Compile())
+}
@@ -0,0 +1,11 @@
+package offsets
+
+import (
+	"os"
+)
+
+// fileInode returns 0 on windows, where files are identified by their path
+// only.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,229 @@
+package offsets
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Store keeps track of the read offsets of files, so that plugins tailing
+// files can resume where they left off after a restart or a reload.
+//
+// Files are identified by their path and inode, which makes it possible to
+// recognize files that were rotated (renamed) since the offsets were saved,
+// as well as files that were replaced by a new file under the same path.
+type Store struct {
+	path string
+
+	offsets map[string]entry
+	sync.Mutex
+}
+
+type entry struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// NewStore returns a Store persisting the offsets to the given state file.
+func NewStore(path string) *Store {
+	return &Store{
+		path:    path,
+		offsets: make(map[string]entry),
+	}
+}
+
+// Load reads the offsets from the state file. A missing or empty state file
+// is not an error, there are simply no offsets to resume from.
+func (s *Store) Load() error {
+	s.Lock()
+	defer s.Unlock()
+
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	offsets := make(map[string]entry)
+	if err := json.Unmarshal(data, &offsets); err != nil {
+		return err
+	}
+	s.offsets = offsets
+	return nil
+}
+
+// Save writes the offsets to the state file, dropping the offsets of files
+// that no longer exist. The state file is replaced atomically so that a
+// crash while saving does not lose the previous offsets.
+func (s *Store) Save() error {
+	s.Lock()
+	defer s.Unlock()
+
+	for path := range s.offsets {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			delete(s.offsets, path)
+		}
+	}
+
+	data, err := json.Marshal(s.offsets)
+	if err != nil {
+		return err
+	}
+
+	tmpfile, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmpfile.Write(data); err != nil {
+		tmpfile.Close()
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	if err := tmpfile.Close(); err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	return os.Rename(tmpfile.Name(), s.path)
+}
+
+// Get returns the offset to resume reading the file at path from.
+// ok is false when nothing is known about the file, in which case the caller
+// should fall back to its default behavior.
+//
+// If the file under path was replaced since its offset was saved, it is read
+// from the beginning, and if a file was renamed to path, the offset saved for
+// its previous path is used. Files that were truncated below the saved offset
+// are also read from the beginning.
+func (s *Store) Get(path string) (offset int64, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	inode := fileInode(info)
+
+	e, ok := s.offsets[path]
+	if !ok || (inode != 0 && e.Inode != inode) {
+		// look for the file under a path it had before being rotated.
+		found := false
+		if inode != 0 {
+			for _, other := range s.offsets {
+				if other.Inode == inode {
+					e, found = other, true
+					break
+				}
+			}
+		}
+		if !found {
+			if ok {
+				// a new file replaced the one we were reading.
+				return 0, true
+			}
+			return 0, false
+		}
+	}
+
+	if info.Size() < e.Offset {
+		// the file was truncated.
+		return 0, true
+	}
+	return e.Offset, true
+}
+
+// Set records the offset up to which the file at path has been read.
+func (s *Store) Set(path string, offset int64) {
+	info, err := os.Stat(path)
+	if err != nil {
+		info = nil
+	}
+	s.SetFile(path, info, offset)
+}
+
+// SetFile records the offset up to which the file at path has been read, the
+// file being identified by info rather than by the file currently at path,
+// such as when the file was rotated while it is read. info may be nil when
+// the file is unknown.
+func (s *Store) SetFile(path string, info os.FileInfo, offset int64) {
+	s.Lock()
+	defer s.Unlock()
+
+	var inode uint64
+	if info != nil {
+		inode = fileInode(info)
+	}
+	s.offsets[path] = entry{Inode: inode, Offset: offset}
+}
+
+// Rotated returns the file that was at path when its offset was saved, when
+// it was rotated since: the file of the same directory with the saved inode,
+// and the offset to read the rest of it from. ok is false when the file
+// wasn't rotated, was read entirely or can't be found, such as when it was
+// compressed or on systems without inodes.
+func (s *Store) Rotated(path string) (rotated string, offset int64, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	e, ok := s.offsets[path]
+	if !ok || e.Inode == 0 {
+		return "", 0, false
+	}
+	if info, err := os.Stat(path); err == nil && fileInode(info) == e.Inode {
+		return "", 0, false
+	}
+
+	dir := filepath.Dir(path)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", 0, false
+	}
+	for _, info := range files {
+		if !info.Mode().IsRegular() || fileInode(info) != e.Inode {
+			continue
+		}
+		if info.Size() <= e.Offset {
+			return "", 0, false
+		}
+		return filepath.Join(dir, info.Name()), e.Offset, true
+	}
+	return "", 0, false
+}
+
+// ReadLines calls fn with each line of the file at path from the offset,
+// without its newline, until the end of the file or until fn returns false.
+// It is used to read the rest of rotated files, which aren't followed.
+func ReadLines(path string, offset int64, fn func(line string) bool) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 && !fn(strings.TrimSuffix(line, "\n")) {
+			return nil
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
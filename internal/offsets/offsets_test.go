@@ -0,0 +1,175 @@
+package offsets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestStoreSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	writeFile(t, logfile, "line1\nline2\n")
+
+	s := NewStore(filepath.Join(dir, "state"))
+	require.NoError(t, s.Load())
+	_, ok := s.Get(logfile)
+	assert.False(t, ok)
+
+	s.Set(logfile, 6)
+	require.NoError(t, s.Save())
+
+	s2 := NewStore(filepath.Join(dir, "state"))
+	require.NoError(t, s2.Load())
+	offset, ok := s2.Get(logfile)
+	assert.True(t, ok)
+	assert.Equal(t, int64(6), offset)
+}
+
+func TestStoreTruncatedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	writeFile(t, logfile, "line1\nline2\n")
+
+	s := NewStore(filepath.Join(dir, "state"))
+	s.Set(logfile, 12)
+
+	writeFile(t, logfile, "line3\n")
+	offset, ok := s.Get(logfile)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), offset)
+}
+
+func TestStoreRotatedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	rotated := filepath.Join(dir, "app.log.1")
+	writeFile(t, logfile, "line1\nline2\n")
+
+	s := NewStore(filepath.Join(dir, "state"))
+	s.Set(logfile, 6)
+
+	// rotate the file, and create a new one under the same path
+	require.NoError(t, os.Rename(logfile, rotated))
+	writeFile(t, logfile, "line3\nline4\nline5\n")
+
+	// the new file is read from the beginning
+	offset, ok := s.Get(logfile)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), offset)
+
+	// the rotated file resumes from its saved offset
+	if fileInode(mustStat(t, rotated)) != 0 {
+		offset, ok = s.Get(rotated)
+		assert.True(t, ok)
+		assert.Equal(t, int64(6), offset)
+	}
+}
+
+func TestStoreSaveDropsRemovedFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	writeFile(t, logfile, "line1\n")
+
+	s := NewStore(filepath.Join(dir, "state"))
+	s.Set(logfile, 6)
+	require.NoError(t, os.Remove(logfile))
+	require.NoError(t, s.Save())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "state"))
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(data))
+}
+
+func mustStat(t *testing.T, path string) os.FileInfo {
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	return info
+}
+
+func TestStoreRotatedWhileStopped(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	rotated := filepath.Join(dir, "app.log.1")
+	writeFile(t, logfile, "line1\nline2\n")
+
+	s := NewStore(filepath.Join(dir, "state"))
+	s.Set(logfile, 6)
+	_, _, ok := s.Rotated(logfile)
+	assert.False(t, ok)
+
+	// lines are written before the rotation, after the offset was saved
+	f, err := os.OpenFile(logfile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("line3\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Rename(logfile, rotated))
+	writeFile(t, logfile, "line4\n")
+
+	if fileInode(mustStat(t, rotated)) == 0 {
+		t.Skip("files have no inode")
+	}
+	path, offset, ok := s.Rotated(logfile)
+	require.True(t, ok)
+	assert.Equal(t, rotated, path)
+	assert.Equal(t, int64(6), offset)
+
+	var lines []string
+	require.NoError(t, ReadLines(path, offset, func(line string) bool {
+		lines = append(lines, line)
+		return true
+	}))
+	assert.Equal(t, []string{"line2", "line3"}, lines)
+
+	// the offset of the rest of the rotated file is saved for the path
+	s.SetFile(logfile, mustStat(t, rotated), 18)
+	_, _, ok = s.Rotated(logfile)
+	assert.False(t, ok)
+}
+
+func TestReadLinesStops(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	writeFile(t, logfile, "line1\nline2\nline3")
+
+	var lines []string
+	require.NoError(t, ReadLines(logfile, 0, func(line string) bool {
+		lines = append(lines, line)
+		return len(lines) < 2
+	}))
+	assert.Equal(t, []string{"line1", "line2"}, lines)
+
+	lines = nil
+	require.NoError(t, ReadLines(logfile, 12, func(line string) bool {
+		lines = append(lines, line)
+		return true
+	}))
+	assert.Equal(t, []string{"line3"}, lines)
+}
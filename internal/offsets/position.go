@@ -0,0 +1,83 @@
+package offsets
+
+import (
+	"os"
+	"sync"
+)
+
+// Position counts the offsets of the lines read from a file, so that the
+// offset saved is the one of the last line emitted. It can be behind the
+// offset the file was read up to, such as while the lines of an event are
+// joined or when lines are queued to be parsed.
+//
+// The files are identified by their FileInfo, which changes when a file is
+// reopened after being rotated, the lines of the previous file emitted after
+// it being ignored.
+type Position struct {
+	sync.Mutex
+
+	info    os.FileInfo
+	read    int64
+	emitted int64
+}
+
+// Reset counts the offsets of another file, from offset, info identifying
+// the file. info may be nil when the file doesn't exist yet.
+func (p *Position) Reset(info os.FileInfo, offset int64) {
+	p.Lock()
+	defer p.Unlock()
+	p.info = info
+	p.read, p.emitted = offset, offset
+}
+
+// Read moves the read offset past a line, without its newline. It returns
+// the file the line was read from and the offsets of its start and end.
+func (p *Position) Read(line string) (info os.FileInfo, start, end int64) {
+	p.Lock()
+	defer p.Unlock()
+	start = p.read
+	p.read += int64(len(line)) + 1
+	return p.info, start, p.read
+}
+
+// Emit records that the lines of the file up to the offset were emitted.
+func (p *Position) Emit(info os.FileInfo, offset int64) {
+	p.Lock()
+	defer p.Unlock()
+	if !sameFile(p.info, info) {
+		return
+	}
+	if offset > p.read {
+		offset = p.read
+	}
+	p.emitted = offset
+}
+
+// Record sets the offset of the last line emitted in the store, for the file
+// at path. tell returns the offset the file is read up to, which is where the
+// offsets are counted from when the file was truncated in place, as it is then
+// read again from its beginning.
+func (p *Position) Record(s *Store, path string, tell func() (int64, error)) {
+	p.Lock()
+	defer p.Unlock()
+
+	info, err := os.Stat(path)
+	if err == nil && sameFile(p.info, info) && info.Size() < p.read {
+		offset, err := tell()
+		if err != nil || offset > info.Size() {
+			offset = info.Size()
+		}
+		p.read = offset
+		if p.emitted > offset {
+			p.emitted = offset
+		}
+	}
+	s.SetFile(path, p.info, p.emitted)
+}
+
+func sameFile(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return os.SameFile(a, b)
+}
@@ -0,0 +1,73 @@
+package offsets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPositionEmit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	writeFile(t, logfile, "line1\nline2\n")
+	s := NewStore(filepath.Join(dir, "state"))
+
+	var p Position
+	p.Reset(mustStat(t, logfile), 0)
+	info, start, end := p.Read("line1")
+	assert.Equal(t, int64(0), start)
+	assert.Equal(t, int64(6), end)
+	p.Read("line2")
+
+	// only the first line was emitted
+	p.Emit(info, end)
+	p.Record(s, logfile, func() (int64, error) { return 12, nil })
+	offset, ok := s.Get(logfile)
+	assert.True(t, ok)
+	assert.Equal(t, int64(6), offset)
+
+	// the lines of the previous file are ignored once the file is reopened
+	require.NoError(t, os.Rename(logfile, logfile+".1"))
+	writeFile(t, logfile, "line3\n")
+	p.Reset(mustStat(t, logfile), 0)
+	p.Emit(info, 12)
+	p.Record(s, logfile, func() (int64, error) { return 0, nil })
+	offset, ok = s.Get(logfile)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), offset)
+}
+
+func TestPositionTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "offsets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	logfile := filepath.Join(dir, "app.log")
+	writeFile(t, logfile, "line1\nline2\n")
+	s := NewStore(filepath.Join(dir, "state"))
+
+	var p Position
+	p.Reset(mustStat(t, logfile), 0)
+	info, _, _ := p.Read("line1")
+	_, _, end := p.Read("line2")
+	p.Emit(info, end)
+
+	// truncated in place, and read again up to the first line
+	writeFile(t, logfile, "line3\nline4\n")
+	require.NoError(t, os.Truncate(logfile, 6))
+	p.Record(s, logfile, func() (int64, error) { return 6, nil })
+	offset, ok := s.Get(logfile)
+	assert.True(t, ok)
+	assert.Equal(t, int64(6), offset)
+
+	// the offsets are counted from there
+	_, _, end = p.Read("line4")
+	assert.Equal(t, int64(12), end)
+}
@@ -0,0 +1,37 @@
+// Package placeholder replaces the {{key}} placeholders of the templates of
+// the outputs, such as index names, paths or routing keys, with the values of
+// the metrics written.
+package placeholder
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+var placeholderRE = regexp.MustCompile(`{{\s*[^{}\s]+\s*}}`)
+
+// Has reports whether template has any placeholder.
+func Has(template string) bool {
+	return placeholderRE.MatchString(template)
+}
+
+// Replace replaces the placeholders of template with the values of the tags
+// of m, and {{measurement}} with its name when measurement is true. Each
+// value goes through fix when it isn't nil, to sanitize it or to default
+// missing tags.
+func Replace(template string, m telegraf.Metric, measurement bool, fix func(string) string) string {
+	tags := m.Tags()
+	return placeholderRE.ReplaceAllStringFunc(template, func(s string) string {
+		key := strings.TrimSpace(s[2 : len(s)-2])
+		value := tags[key]
+		if measurement && key == "measurement" {
+			value = m.Name()
+		}
+		if fix != nil {
+			value = fix(value)
+		}
+		return value
+	})
+}
@@ -0,0 +1,33 @@
+package placeholder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplace(t *testing.T) {
+	m, err := metric.New("cpu",
+		map[string]string{"host": "a", "measurement": "tag"},
+		map[string]interface{}{"value": 1.0},
+		time.Now())
+	require.NoError(t, err)
+
+	assert.Equal(t, "a-cpu-",
+		Replace("{{host}}-{{ measurement }}-{{missing}}", m, true, nil))
+	assert.Equal(t, "a-tag", Replace("{{host}}-{{measurement}}", m, false, nil))
+
+	fix := func(value string) string {
+		if value == "" {
+			return "none"
+		}
+		return value
+	}
+	assert.Equal(t, "a/none/{{}}", Replace("{{host}}/{{missing}}/{{}}", m, true, fix))
+
+	assert.True(t, Has("/tmp/{{host}}.out"))
+	assert.False(t, Has("/tmp/{{}}.out"))
+}
@@ -37,6 +37,11 @@ func escape(s string, t string) string {
 }
 
 func unescape(s string, t string) string {
+	// all of the escape sequences start with a backslash, the replacers
+	// allocate even when there's nothing to replace
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
 	switch t {
 	case "fieldkey", "tagkey", "tagval":
 		return unEscaper.Replace(s)
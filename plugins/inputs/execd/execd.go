@@ -0,0 +1,331 @@
+package execd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/parsers"
+)
+
+const sampleConfig = `
+  ## Program to run as daemon, followed by its arguments.
+  command = ["/usr/bin/mycollector", "--foo=bar"]
+
+  ## Define how the process is signaled on each collection interval.
+  ## Valid values are:
+  ##   "none"    : Do not signal anything. The process must output metrics by
+  ##               itself.
+  ##   "STDIN"   : Send a newline on STDIN.
+  ##   "SIGHUP"  : Send a HUP signal. Not available on Windows.
+  ##   "SIGUSR1" : Send a USR1 signal. Not available on Windows.
+  ##   "SIGUSR2" : Send a USR2 signal. Not available on Windows.
+  signal = "none"
+
+  ## Delay before the process is restarted after an unexpected termination.
+  ## The delay doubles on each consecutive failure, up to max_restart_delay.
+  restart_delay = "10s"
+  # max_restart_delay = "5m"
+
+  ## Data format to consume, each line of the output is parsed on its own.
+  ## Each data format has its own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
+  data_format = "influx"
+`
+
+// execCommand is so tests can mock out exec.Command usage.
+var execCommand = exec.Command
+
+// killTimeout is how long the process is given to exit once its STDIN is
+// closed, before it is killed.
+var killTimeout = 5 * time.Second
+
+// maxLineSize is the size of the longest line read from the output of the
+// process.
+var maxLineSize = 1024 * 1024
+
+var errStopped = errors.New("execd: plugin stopped")
+
+type Execd struct {
+	Command         []string
+	Signal          string
+	RestartDelay    internal.Duration
+	MaxRestartDelay internal.Duration
+
+	acc    telegraf.Accumulator
+	parser parsers.Parser
+
+	sync.Mutex
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewExecd() *Execd {
+	return &Execd{
+		Signal:          "none",
+		RestartDelay:    internal.Duration{Duration: 10 * time.Second},
+		MaxRestartDelay: internal.Duration{Duration: 5 * time.Minute},
+	}
+}
+
+func (e *Execd) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Execd) Description() string {
+	return "Run a long running program as daemon and read metrics from its output"
+}
+
+func (e *Execd) SetParser(parser parsers.Parser) {
+	e.parser = parser
+}
+
+func (e *Execd) Start(acc telegraf.Accumulator) error {
+	if len(e.Command) == 0 {
+		return fmt.Errorf("execd: no command specified")
+	}
+	switch e.Signal {
+	case "", "none", "STDIN":
+	default:
+		if _, ok := signals[e.Signal]; !ok {
+			return fmt.Errorf("execd: unsupported signal %q", e.Signal)
+		}
+	}
+
+	e.acc = acc
+	e.done = make(chan struct{})
+
+	stdout, stderr, err := e.cmdStart()
+	if err != nil {
+		return err
+	}
+
+	e.wg.Add(1)
+	go e.cmdLoop(stdout, stderr)
+	return nil
+}
+
+func (e *Execd) Stop() {
+	if e.done == nil {
+		return
+	}
+
+	e.Lock()
+	close(e.done)
+	if e.stdin != nil {
+		e.stdin.Close()
+	}
+	e.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(killTimeout):
+		e.kill()
+		<-stopped
+	}
+}
+
+func (e *Execd) Gather(acc telegraf.Accumulator) error {
+	e.Lock()
+	defer e.Unlock()
+
+	if e.cmd == nil {
+		// the process is being restarted
+		return nil
+	}
+
+	switch e.Signal {
+	case "", "none":
+	case "STDIN":
+		if _, err := io.WriteString(e.stdin, "\n"); err != nil {
+			return fmt.Errorf("execd: error writing to STDIN: %s", err)
+		}
+	default:
+		if err := e.cmd.Process.Signal(signals[e.Signal]); err != nil {
+			return fmt.Errorf("execd: error signaling process: %s", err)
+		}
+	}
+	return nil
+}
+
+// cmdStart starts the process and returns its output pipes.
+func (e *Execd) cmdStart() (io.ReadCloser, io.ReadCloser, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	select {
+	case <-e.done:
+		return nil, nil, errStopped
+	default:
+	}
+
+	cmd := execCommand(e.Command[0], e.Command[1:]...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("execd: error opening STDIN pipe: %s", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("execd: error opening STDOUT pipe: %s", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("execd: error opening STDERR pipe: %s", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("execd: error starting process %s: %s",
+			strings.Join(e.Command, " "), err)
+	}
+
+	e.cmd = cmd
+	e.stdin = stdin
+	return stdout, stderr, nil
+}
+
+// cmdLoop waits for the process to exit and restarts it, until the plugin is
+// stopped.
+func (e *Execd) cmdLoop(stdout, stderr io.ReadCloser) {
+	defer e.wg.Done()
+
+	delay := e.RestartDelay.Duration
+	for {
+		started := time.Now()
+		err := e.cmdWait(stdout, stderr)
+
+		select {
+		case <-e.done:
+			return
+		default:
+		}
+
+		if err != nil {
+			log.Printf("E! execd: process %s terminated: %s", e.Command[0], err)
+		} else {
+			log.Printf("E! execd: process %s terminated", e.Command[0])
+		}
+
+		// reset the backoff once the process ran for a while
+		if time.Since(started) > delay {
+			delay = e.RestartDelay.Duration
+		}
+
+		for {
+			log.Printf("I! execd: restarting process %s in %s", e.Command[0], delay)
+			select {
+			case <-e.done:
+				return
+			case <-time.After(delay):
+			}
+
+			if delay *= 2; e.MaxRestartDelay.Duration > 0 && delay > e.MaxRestartDelay.Duration {
+				delay = e.MaxRestartDelay.Duration
+			}
+
+			stdout, stderr, err = e.cmdStart()
+			if err == errStopped {
+				return
+			}
+			if err == nil {
+				break
+			}
+			e.acc.AddError(err)
+		}
+	}
+}
+
+// cmdWait reads the output of the process until it exits.
+func (e *Execd) cmdWait(stdout, stderr io.ReadCloser) error {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		e.readStdout(stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		e.readStderr(stderr)
+	}()
+	wg.Wait()
+
+	err := e.cmd.Wait()
+
+	e.Lock()
+	e.cmd = nil
+	e.stdin = nil
+	e.Unlock()
+	return err
+}
+
+// kill kills the process, it is restarted unless the plugin is stopped.
+func (e *Execd) kill() {
+	e.Lock()
+	defer e.Unlock()
+	if e.cmd != nil {
+		e.cmd.Process.Kill()
+	}
+}
+
+func (e *Execd) readStdout(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	for scanner.Scan() {
+		metrics, err := e.parser.Parse(scanner.Bytes())
+		if err != nil {
+			e.acc.AddError(fmt.Errorf("execd: unable to parse output: %s", err))
+			continue
+		}
+		for _, m := range metrics {
+			e.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		e.acc.AddError(fmt.Errorf("execd: error reading STDOUT: %s", err))
+		// the output can't be read past the error, the process is restarted
+		// rather than left blocked writing to it
+		e.kill()
+		io.Copy(ioutil.Discard, r)
+	}
+}
+
+func (e *Execd) readStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	for scanner.Scan() {
+		log.Printf("E! execd: %s: %s", e.Command[0], scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		e.acc.AddError(fmt.Errorf("execd: error reading STDERR: %s", err))
+		// keep the process from blocking on writes
+		io.Copy(ioutil.Discard, r)
+	}
+}
+
+func init() {
+	inputs.Add("execd", func() telegraf.Input {
+		return NewExecd()
+	})
+}
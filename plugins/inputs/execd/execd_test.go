@@ -0,0 +1,166 @@
+package execd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/parsers"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This is not a real test. This is just a way of running the test binary as
+// the daemon.
+//
+// Idea based on https://github.com/golang/go/blob/7c31043/src/os/exec/exec_test.go#L568
+func TestHelperProcess(t *testing.T) {
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+			break
+		}
+	}
+	if args == nil {
+		return
+	}
+
+	switch args[0] {
+	case "counter":
+		fmt.Fprintln(os.Stderr, "waiting for STDIN")
+		scanner := bufio.NewScanner(os.Stdin)
+		count := 0
+		for scanner.Scan() {
+			count++
+			fmt.Printf("counter count=%di\n", count)
+		}
+	case "exit":
+		fmt.Println("exit value=1i")
+		os.Exit(1)
+	case "hang":
+		fmt.Println("hang value=1i")
+		select {}
+	case "long":
+		fmt.Println("long value=1i")
+		fmt.Println(strings.Repeat("x", 4096))
+		fmt.Println("long value=2i")
+		select {}
+	}
+	os.Exit(0)
+}
+
+func newTestExecd(mode string) *Execd {
+	e := NewExecd()
+	e.Command = []string{os.Args[0], "-test.run=TestHelperProcess", "--", mode}
+	e.parser, _ = parsers.NewInfluxParser()
+	return e
+}
+
+func waitMetrics(acc *testutil.Accumulator, n int) {
+	acc.Lock()
+	defer acc.Unlock()
+	for len(acc.Metrics) < n {
+		acc.Wait()
+	}
+}
+
+func TestExecd_stdin(t *testing.T) {
+	e := newTestExecd("counter")
+	e.Signal = "STDIN"
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	require.NoError(t, e.Gather(acc))
+	waitMetrics(acc, 1)
+	require.NoError(t, e.Gather(acc))
+	waitMetrics(acc, 2)
+
+	acc.Lock()
+	defer acc.Unlock()
+	assert.Equal(t, int64(1), acc.Metrics[0].Fields["count"])
+	assert.Equal(t, int64(2), acc.Metrics[1].Fields["count"])
+}
+
+func TestExecd_restart(t *testing.T) {
+	e := newTestExecd("exit")
+	e.RestartDelay = internal.Duration{Duration: 10 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	waitMetrics(acc, 3)
+	acc.AssertContainsFields(t, "exit", map[string]interface{}{"value": int64(1)})
+}
+
+func TestExecd_kill(t *testing.T) {
+	defer func(d time.Duration) { killTimeout = d }(killTimeout)
+	killTimeout = 100 * time.Millisecond
+
+	e := newTestExecd("hang")
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	waitMetrics(acc, 1)
+
+	stopped := make(chan struct{})
+	go func() {
+		e.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("process not killed")
+	}
+}
+
+func TestExecd_longLine(t *testing.T) {
+	defer func(d time.Duration) { killTimeout = d }(killTimeout)
+	killTimeout = 100 * time.Millisecond
+	defer func(n int) { maxLineSize = n }(maxLineSize)
+	maxLineSize = 1024
+
+	e := newTestExecd("long")
+	e.RestartDelay = internal.Duration{Duration: 10 * time.Millisecond}
+
+	acc := &testutil.Accumulator{}
+	require.NoError(t, e.Start(acc))
+	defer e.Stop()
+
+	// the process is restarted once its output can't be read
+	waitMetrics(acc, 2)
+	acc.Lock()
+	defer acc.Unlock()
+	for _, m := range acc.Metrics {
+		assert.Equal(t, int64(1), m.Fields["value"])
+	}
+	require.NotEmpty(t, acc.Errors)
+	assert.Contains(t, acc.Errors[0].Error(), "token too long")
+}
+
+func TestExecd_invalid(t *testing.T) {
+	acc := &testutil.Accumulator{}
+
+	e := NewExecd()
+	assert.Error(t, e.Start(acc))
+
+	e = newTestExecd("counter")
+	e.Signal = "SIGWHATEVER"
+	assert.Error(t, e.Start(acc))
+
+	e = newTestExecd("counter")
+	e.Command = []string{"/nonexistent/command"}
+	assert.Error(t, e.Start(acc))
+
+	// stopping a plugin which failed to start is a noop
+	e.Stop()
+}
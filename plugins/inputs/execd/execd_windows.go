@@ -0,0 +1,10 @@
+// +build windows
+
+package execd
+
+import (
+	"os"
+)
+
+// signals can't be sent to processes on Windows, use STDIN instead.
+var signals = map[string]os.Signal{}
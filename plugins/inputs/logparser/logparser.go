@@ -3,14 +3,18 @@ package logparser
 import (
 	"fmt"
 	"log"
+	"os"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/hpcloud/tail"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal/errchan"
 	"github.com/influxdata/telegraf/internal/globpath"
+	"github.com/influxdata/telegraf/internal/multiline"
+	"github.com/influxdata/telegraf/internal/offsets"
 	"github.com/influxdata/telegraf/plugins/inputs"
 
 	// Parsers
@@ -25,9 +29,13 @@ type LogParser interface {
 type LogParserPlugin struct {
 	Files         []string
 	FromBeginning bool
+	OffsetsFile   string `toml:"offsets_file"`
 
-	tailers map[string]*tail.Tail
-	lines   chan string
+	Multiline *multiline.Config `toml:"multiline"`
+
+	files   map[string]*tailedFile
+	offsets *offsets.Store
+	lines   chan logLine
 	done    chan struct{}
 	wg      sync.WaitGroup
 	acc     telegraf.Accumulator
@@ -50,6 +58,29 @@ const sampleConfig = `
   ## while telegraf is running (and that match the "files" globs) will always
   ## be read from the beginning.
   from_beginning = false
+  ## File in which to save the read offsets of the log files, so that they are
+  ## resumed from where they were left on restart. When set, files that have a
+  ## saved offset are not read according to from_beginning.
+  ## Each plugin instance should use its own offsets file.
+  # offsets_file = "/var/lib/telegraf/logparser.offsets"
+
+  ## Join multi-line events, such as stack traces, into a single event before
+  ## parsing them. Lines of an event are joined with a newline, use the (?s)
+  ## flag for "." to match newlines in the grok patterns.
+  # [inputs.logparser.multiline]
+  #   ## Regular expression matching the start or the continuation lines of
+  #   ## an event.
+  #   pattern = '^\s'
+  #   ## Whether the lines matching the pattern are the "start" or the
+  #   ## "continuation" lines of an event.
+  #   match = "continuation"
+  #   ## Negate the pattern.
+  #   invert_match = false
+  #   ## Flush the current event when no new line arrived for this long.
+  #   timeout = "5s"
+  #   ## Maximum size of an event in bytes, lines are not joined past it.
+  #   ## Lines are never split, a longer line is an event on its own.
+  #   max_size = 65536
 
   ## Parse logstash-style "grok" patterns:
   ##   Telegraf built-in parsing patterns: https://goo.gl/dkay10
@@ -83,7 +114,11 @@ func (l *LogParserPlugin) Gather(acc telegraf.Accumulator) error {
 	defer l.Unlock()
 
 	// always start from the beginning of files that appear while we're running
-	return l.tailNewfiles(true)
+	if err := l.tailNewfiles(true); err != nil {
+		return err
+	}
+
+	return l.saveOffsets()
 }
 
 func (l *LogParserPlugin) Start(acc telegraf.Accumulator) error {
@@ -91,9 +126,9 @@ func (l *LogParserPlugin) Start(acc telegraf.Accumulator) error {
 	defer l.Unlock()
 
 	l.acc = acc
-	l.lines = make(chan string, 1000)
+	l.lines = make(chan logLine, 1000)
 	l.done = make(chan struct{})
-	l.tailers = make(map[string]*tail.Tail)
+	l.files = make(map[string]*tailedFile)
 
 	// Looks for fields which implement LogParser interface
 	l.parsers = []LogParser{}
@@ -117,6 +152,12 @@ func (l *LogParserPlugin) Start(acc telegraf.Accumulator) error {
 		return fmt.Errorf("ERROR: logparser input plugin: no parser defined.")
 	}
 
+	if l.Multiline != nil {
+		if err := l.Multiline.Compile(); err != nil {
+			return err
+		}
+	}
+
 	// compile log parser patterns:
 	errChan := errchan.New(len(l.parsers))
 	for _, parser := range l.parsers {
@@ -128,6 +169,14 @@ func (l *LogParserPlugin) Start(acc telegraf.Accumulator) error {
 		return err
 	}
 
+	if l.OffsetsFile != "" {
+		l.offsets = offsets.NewStore(l.OffsetsFile)
+		if err := l.offsets.Load(); err != nil {
+			return fmt.Errorf("Error loading offsets file %s: %s",
+				l.OffsetsFile, err)
+		}
+	}
+
 	l.wg.Add(1)
 	go l.parser()
 
@@ -156,49 +205,265 @@ func (l *LogParserPlugin) tailNewfiles(fromBeginning bool) error {
 		errChan = errchan.New(len(files))
 
 		for file, _ := range files {
-			if _, ok := l.tailers[file]; ok {
+			if _, ok := l.files[file]; ok {
 				// we're already tailing this file
 				continue
 			}
 
-			tailer, err := tail.TailFile(file,
-				tail.Config{
-					ReOpen:    true,
-					Follow:    true,
-					Location:  &seek,
-					MustExist: true,
-				})
+			location := &seek
+			var rotated string
+			var rotatedOffset int64
+			if l.offsets != nil {
+				if path, offset, ok := l.offsets.Rotated(file); ok {
+					// the rest of the file rotated while we were stopped is
+					// read first, then the new file from its beginning
+					rotated, rotatedOffset = path, offset
+					location = nil
+				} else if offset, ok := l.offsets.Get(file); ok {
+					location = &tail.SeekInfo{
+						Whence: 0,
+						Offset: offset,
+					}
+				}
+			}
+
+			f := &tailedFile{path: file}
+			err := tailFile(f, location, true)
 			errChan.C <- err
+			if err != nil {
+				continue
+			}
 
 			// create a goroutine for each "tailer"
 			l.wg.Add(1)
-			go l.receiver(tailer)
-			l.tailers[file] = tailer
+			go l.receiver(f, rotated, rotatedOffset)
+			l.files[file] = f
 		}
 	}
 
 	return errChan.Error()
 }
 
+// tailedFile is a file being tailed, along with the offsets of its lines.
+type tailedFile struct {
+	path string
+	pos  offsets.Position
+
+	sync.Mutex
+	tailer *tail.Tail
+}
+
+// logLine is a log line, or a multi-line event, to parse. The offsets of its
+// file are moved to offset once it is parsed.
+type logLine struct {
+	text string
+
+	pos    *offsets.Position
+	info   os.FileInfo
+	offset int64
+}
+
+// tailFile starts tailing the file at f's path, from location or from its
+// beginning when nil, and counts its offsets from there. The tailers don't
+// reopen the files when they are rotated, the receivers do, so that the
+// offsets are counted from the beginning of the new file.
+// Assumes f's lock is held, or that f isn't shared yet!
+func tailFile(f *tailedFile, location *tail.SeekInfo, mustExist bool) error {
+	info, err := os.Stat(f.path)
+	if err != nil && mustExist {
+		return err
+	}
+
+	var offset int64
+	if location != nil && info != nil {
+		offset = location.Offset
+		if location.Whence == 2 {
+			offset = info.Size()
+		}
+		// seek to the offset the lines are counted from
+		location = &tail.SeekInfo{Whence: 0, Offset: offset}
+	}
+
+	tailer, err := tail.TailFile(f.path,
+		tail.Config{
+			ReOpen:    false,
+			Follow:    true,
+			Location:  location,
+			MustExist: mustExist,
+		})
+	if err != nil {
+		return err
+	}
+	f.tailer = tailer
+	f.pos.Reset(info, offset)
+	return nil
+}
+
 // receiver is launched as a goroutine to continuously watch a tailed logfile
-// for changes and send any log lines down the l.lines channel.
-func (l *LogParserPlugin) receiver(tailer *tail.Tail) {
+// for changes and send any log lines down the l.lines channel. rotated is
+// the file the path had before being rotated while we were stopped, whose
+// rest is read first.
+func (l *LogParserPlugin) receiver(f *tailedFile, rotated string, offset int64) {
 	defer l.wg.Done()
 
-	var line *tail.Line
-	for line = range tailer.Lines {
+	r := &lineReader{LogParserPlugin: l, file: f}
+	var flush <-chan time.Time
+	if l.Multiline != nil {
+		r.mline = l.Multiline.New()
+		ticker := time.NewTicker(r.mline.FlushInterval())
+		defer ticker.Stop()
+		flush = ticker.C
+	}
 
-		if line.Err != nil {
-			log.Printf("E! Error tailing file %s, Error: %s\n",
-				tailer.Filename, line.Err)
-			continue
+	f.Lock()
+	tailer := f.tailer
+	f.Unlock()
+
+	if rotated != "" && !r.readRotated(rotated, offset) {
+		return
+	}
+
+	for {
+		select {
+		case line, ok := <-tailer.Lines:
+			if !ok {
+				select {
+				case <-l.done:
+					// the lines of the event being joined are read again
+					// on restart when the offsets are saved
+					if l.offsets == nil {
+						r.flush()
+					}
+					return
+				default:
+				}
+				r.flush()
+				if err := tailer.Err(); err != nil {
+					log.Printf("E! Error tailing file %s, Error: %s\n",
+						tailer.Filename, err)
+					return
+				}
+				// the file was rotated or removed, follow the new file
+				// under its path once created
+				if tailer = l.reopen(f); tailer == nil {
+					return
+				}
+				continue
+			}
+			if line.Err != nil {
+				log.Printf("E! Error tailing file %s, Error: %s\n",
+					tailer.Filename, line.Err)
+				continue
+			}
+			r.add(line.Text)
+		case now := <-flush:
+			if text, ok := r.mline.FlushExpired(now); ok {
+				r.send(text, r.end)
+			}
 		}
+	}
+}
+
+// reopen tails the new file under f's path, unless the plugin is stopped.
+func (l *LogParserPlugin) reopen(f *tailedFile) *tail.Tail {
+	f.Lock()
+	defer f.Unlock()
+
+	select {
+	case <-l.done:
+		return nil
+	default:
+	}
+	f.tailer.Cleanup()
+	if err := tailFile(f, nil, false); err != nil {
+		log.Printf("E! Error tailing file %s, Error: %s\n", f.path, err)
+		return nil
+	}
+	return f.tailer
+}
+
+// lineReader sends the lines read from a file to the parser, joining them
+// into events when mline is set.
+type lineReader struct {
+	*LogParserPlugin
+	file  *tailedFile
+	mline *multiline.Multiline
+
+	// file and offset of the end of the last line read
+	info os.FileInfo
+	end  int64
+}
 
+func (r *lineReader) add(text string) {
+	var start int64
+	r.info, start, r.end = r.file.pos.Read(text)
+	if r.mline == nil {
+		r.send(text, r.end)
+		return
+	}
+	// the line starts the next event when the current one is complete
+	if event, ok := r.mline.Add(text, time.Now()); ok {
+		r.send(event, start)
+	}
+}
+
+// flush sends the event being joined, if any.
+func (r *lineReader) flush() {
+	if r.mline == nil {
+		return
+	}
+	if text, ok := r.mline.Flush(); ok {
+		r.send(text, r.end)
+	}
+}
+
+// send sends a log line, or a multi-line event, to the parser, the lines of
+// the file up to offset being parsed after it.
+func (r *lineReader) send(text string, offset int64) {
+	line := logLine{
+		text:   text,
+		pos:    &r.file.pos,
+		info:   r.info,
+		offset: offset,
+	}
+	select {
+	case <-r.done:
+	case r.lines <- line:
+	}
+}
+
+// readRotated reads the rest of the file the path had before being rotated,
+// from the offset saved for it, before the new file is read from its
+// beginning. It returns false when the plugin was stopped meanwhile.
+func (r *lineReader) readRotated(rotated string, offset int64) bool {
+	current, _ := os.Stat(r.file.path)
+	info, err := os.Stat(rotated)
+	if err != nil {
+		log.Printf("E! Error reading rotated file %s, Error: %s\n", rotated, err)
+		return true
+	}
+	r.file.pos.Reset(info, offset)
+
+	stopped := false
+	err = offsets.ReadLines(rotated, offset, func(line string) bool {
 		select {
-		case <-l.done:
-		case l.lines <- line.Text:
+		case <-r.done:
+			stopped = true
+			return false
+		default:
 		}
+		r.add(line)
+		return true
+	})
+	if err != nil {
+		log.Printf("E! Error reading rotated file %s, Error: %s\n", rotated, err)
+	}
+	if stopped {
+		return false
 	}
+	r.flush()
+	r.file.pos.Reset(current, 0)
+	return true
 }
 
 // parser is launched as a goroutine to watch the l.lines channel.
@@ -209,19 +474,20 @@ func (l *LogParserPlugin) parser() {
 
 	var m telegraf.Metric
 	var err error
-	var line string
+	var line logLine
 	for {
 		select {
 		case <-l.done:
 			return
 		case line = <-l.lines:
-			if line == "" || line == "\n" {
+			if line.text == "" || line.text == "\n" {
+				line.pos.Emit(line.info, line.offset)
 				continue
 			}
 		}
 
 		for _, parser := range l.parsers {
-			m, err = parser.ParseLine(line)
+			m, err = parser.ParseLine(line.text)
 			if err == nil {
 				if m != nil {
 					l.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
@@ -230,6 +496,7 @@ func (l *LogParserPlugin) parser() {
 				log.Println("E! Error parsing log line: " + err.Error())
 			}
 		}
+		line.pos.Emit(line.info, line.offset)
 	}
 }
 
@@ -237,15 +504,53 @@ func (l *LogParserPlugin) Stop() {
 	l.Lock()
 	defer l.Unlock()
 
-	for _, t := range l.tailers {
-		err := t.Stop()
+	close(l.done)
+	for _, f := range l.files {
+		f.Lock()
+		err := f.tailer.Stop()
 		if err != nil {
-			log.Printf("E! Error stopping tail on file %s\n", t.Filename)
+			log.Printf("E! Error stopping tail on file %s\n", f.path)
 		}
-		t.Cleanup()
+		f.tailer.Cleanup()
+		f.Unlock()
 	}
-	close(l.done)
 	l.wg.Wait()
+
+	// the offsets are those of the lines parsed until the parser stopped,
+	// the lines still queued are read again on restart
+	if l.offsets != nil {
+		l.recordOffsets()
+		if err := l.offsets.Save(); err != nil {
+			log.Printf("E! Error saving offsets file %s: %s\n", l.OffsetsFile, err)
+		}
+	}
+}
+
+// recordOffsets records the offset of the last line parsed from every
+// tailed file.
+// Assumes l's lock is held!
+func (l *LogParserPlugin) recordOffsets() {
+	if l.offsets == nil {
+		return
+	}
+
+	for _, f := range l.files {
+		f.Lock()
+		f.pos.Record(l.offsets, f.path, f.tailer.Tell)
+		f.Unlock()
+	}
+}
+
+// saveOffsets records and saves the offset of the last line parsed from
+// every tailed file.
+// Assumes l's lock is held!
+func (l *LogParserPlugin) saveOffsets() error {
+	if l.offsets == nil {
+		return nil
+	}
+
+	l.recordOffsets()
+	return l.offsets.Save()
 }
 
 func init() {
@@ -4,6 +4,7 @@ package ping
 
 import (
 	"errors"
+	"fmt"
 	"os/exec"
 	"runtime"
 	"strconv"
@@ -37,8 +38,14 @@ type Ping struct {
 	// URLs to ping
 	Urls []string
 
+	// Method used to ping, either "exec" or "native"
+	Method string
+
 	// host ping function
 	pingHost HostPinger
+
+	// native ping function
+	pingNative NativePinger
 }
 
 func (_ *Ping) Description() string {
@@ -46,8 +53,14 @@ func (_ *Ping) Description() string {
 }
 
 const sampleConfig = `
-  ## NOTE: this plugin forks the ping command. You may need to set capabilities
-  ## via setcap cap_net_raw+p /bin/ping
+  ## Method used to send the pings:
+  ##   "exec"   : forks the ping command. You may need to set capabilities via
+  ##              setcap cap_net_raw+p /bin/ping
+  ##   "native" : sends the ICMP echo requests directly, using unprivileged
+  ##              ICMP sockets when allowed by the net.ipv4.ping_group_range
+  ##              sysctl, or raw sockets which need telegraf to run as root or
+  ##              with the cap_net_raw capability.
+  # method = "exec"
   #
   ## urls to ping
   urls = ["www.google.com"] # required
@@ -56,6 +69,7 @@ const sampleConfig = `
   ## interval, in s, at which to ping. 0 == default (ping -i <PING_INTERVAL>)
   # ping_interval = 1.0
   ## per-ping timeout, in s. 0 == no timeout (ping -W <TIMEOUT>)
+  ## With the native method, 0 waits up to 5s for the replies.
   # timeout = 1.0
   ## interface to send ping from (ping -I <INTERFACE>)
   # interface = ""
@@ -66,6 +80,11 @@ func (_ *Ping) SampleConfig() string {
 }
 
 func (p *Ping) Gather(acc telegraf.Accumulator) error {
+	switch p.Method {
+	case "", "exec", "native":
+	default:
+		return fmt.Errorf("ping: unknown method %q", p.Method)
+	}
 
 	var wg sync.WaitGroup
 	errorChannel := make(chan error, len(p.Urls)*2)
@@ -75,6 +94,13 @@ func (p *Ping) Gather(acc telegraf.Accumulator) error {
 		wg.Add(1)
 		go func(u string) {
 			defer wg.Done()
+			if p.Method == "native" {
+				if err := p.gatherNative(acc, u); err != nil {
+					errorChannel <- err
+				}
+				return
+			}
+
 			args := p.args(u)
 			totalTimeout := float64(p.Count)*p.Timeout + float64(p.Count-1)*p.PingInterval
 			out, err := p.pingHost(totalTimeout, args...)
@@ -122,6 +148,32 @@ func (p *Ping) Gather(acc telegraf.Accumulator) error {
 	return errors.New(strings.Join(errorStrings, "\n"))
 }
 
+// gatherNative pings url with the native pinger and adds its statistics.
+func (p *Ping) gatherNative(acc telegraf.Accumulator, url string) error {
+	stats, err := p.pingNative(p, url)
+	if err != nil {
+		return fmt.Errorf("%s: %s", url, err)
+	}
+
+	tags := map[string]string{"url": url}
+	fields := map[string]interface{}{
+		"packets_transmitted": stats.trans,
+		"packets_received":    stats.recv,
+		"percent_packet_loss": float64(stats.trans-stats.recv) / float64(stats.trans) * 100.0,
+	}
+	if stats.recv > 0 {
+		fields["minimum_response_ms"] = stats.min
+		fields["average_response_ms"] = stats.avg
+		fields["maximum_response_ms"] = stats.max
+		fields["standard_deviation_ms"] = stats.stddev
+	}
+	if stats.recv > 1 {
+		fields["jitter_ms"] = stats.jitter
+	}
+	acc.AddFields("ping", fields, tags)
+	return nil
+}
+
 func hostPinger(timeout float64, args ...string) (string, error) {
 	bin, err := exec.LookPath("ping")
 	if err != nil {
@@ -206,6 +258,7 @@ func init() {
 	inputs.Add("ping", func() telegraf.Input {
 		return &Ping{
 			pingHost:     hostPinger,
+			pingNative:   nativePinger,
 			PingInterval: 1.0,
 			Count:        1,
 			Timeout:      1.0,
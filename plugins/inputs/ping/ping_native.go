@@ -0,0 +1,270 @@
+// +build !windows
+
+package ping
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protocolICMP     = 1
+	protocolIPv6ICMP = 58
+
+	// size of the echo payload, the same as "ping -s 16"
+	payloadSize = 16
+)
+
+// defaultNativeTimeout is how long to wait for replies when no timeout is
+// configured.
+var defaultNativeTimeout = 5 * time.Second
+
+// pingStats are the statistics of the echo requests sent to a host, response
+// times are in milliseconds.
+type pingStats struct {
+	trans  int
+	recv   int
+	min    float64
+	avg    float64
+	max    float64
+	stddev float64
+	jitter float64
+}
+
+// NativePinger is a function that pings the given url without the ping
+// command. This can be switched with a mocked function for unit test purposes
+// (see ping_test.go)
+type NativePinger func(p *Ping, url string) (*pingStats, error)
+
+// nativePinger sends p.Count echo requests to url, p.PingInterval seconds apart,
+// and waits up to p.Timeout seconds after the last request for the replies.
+func nativePinger(p *Ping, url string) (*pingStats, error) {
+	dst, err := net.ResolveIPAddr("ip", url)
+	if err != nil {
+		return nil, err
+	}
+	v6 := dst.IP.To4() == nil
+
+	conn, raw, err := listenICMP(v6, p.Interface)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var peer net.Addr = &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}
+	if raw {
+		peer = dst
+	}
+
+	var echoType, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	proto := protocolICMP
+	if v6 {
+		echoType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		proto = protocolIPv6ICMP
+	}
+
+	// Datagram sockets get their echo identifier assigned by the kernel, raw
+	// sockets receive the replies of all the pings of the host.
+	id := rand.Intn(0xffff)
+
+	count := p.Count
+	if count < 1 {
+		count = 1
+	}
+	rtts := make([]time.Duration, count)
+	for i := range rtts {
+		rtts[i] = -1
+	}
+
+	recvDone := make(chan struct{})
+	go func() {
+		defer close(recvDone)
+		buf := make([]byte, 1500)
+		for received := 0; received < count; {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			now := time.Now()
+
+			if !addrIP(from).Equal(dst.IP) {
+				continue
+			}
+			m, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil || m.Type != replyType {
+				continue
+			}
+			echo, ok := m.Body.(*icmp.Echo)
+			if !ok || (raw && echo.ID != id) || echo.Seq >= count || len(echo.Data) < 8 {
+				continue
+			}
+			if rtts[echo.Seq] >= 0 {
+				// duplicate
+				continue
+			}
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(echo.Data)))
+			rtts[echo.Seq] = now.Sub(sent)
+			received++
+		}
+	}()
+
+	interval := time.Duration(p.PingInterval * float64(time.Second))
+	if interval <= 0 {
+		interval = time.Second
+	}
+	timeout := time.Duration(p.Timeout * float64(time.Second))
+	if timeout <= 0 {
+		timeout = defaultNativeTimeout
+	}
+
+	var sendErr error
+	trans := 0
+	for seq := 0; seq < count; seq++ {
+		if seq > 0 {
+			time.Sleep(interval)
+		}
+
+		data := make([]byte, payloadSize)
+		binary.BigEndian.PutUint64(data, uint64(time.Now().UnixNano()))
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: data},
+		}
+		b, err := msg.Marshal(nil)
+		if err != nil {
+			sendErr = err
+			break
+		}
+		if _, err := conn.WriteTo(b, peer); err != nil {
+			sendErr = err
+			break
+		}
+		trans++
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	<-recvDone
+
+	if trans == 0 {
+		return nil, sendErr
+	}
+	return newPingStats(trans, rtts[:trans]), nil
+}
+
+// listenICMP opens an unprivileged ICMP datagram socket, falling back to a raw
+// socket when those aren't allowed, and returns whether the socket is raw.
+func listenICMP(v6 bool, iface string) (*icmp.PacketConn, bool, error) {
+	source, err := sourceAddress(v6, iface)
+	if err != nil {
+		return nil, false, err
+	}
+
+	network, rawNetwork := "udp4", "ip4:icmp"
+	if v6 {
+		network, rawNetwork = "udp6", "ip6:ipv6-icmp"
+	}
+
+	conn, err := icmp.ListenPacket(network, source)
+	if err == nil {
+		return conn, false, nil
+	}
+	conn, rawErr := icmp.ListenPacket(rawNetwork, source)
+	if rawErr != nil {
+		return nil, false, fmt.Errorf("unable to open ICMP socket: %s, %s", err, rawErr)
+	}
+	return conn, true, nil
+}
+
+// sourceAddress returns the address to send the pings from, iface is either
+// an address or the name of an interface.
+func sourceAddress(v6 bool, iface string) (string, error) {
+	if iface == "" {
+		if v6 {
+			return "::", nil
+		}
+		return "0.0.0.0", nil
+	}
+	if ip := net.ParseIP(iface); ip != nil {
+		return ip.String(), nil
+	}
+
+	i, err := net.InterfaceByName(iface)
+	if err != nil {
+		return "", err
+	}
+	addrs, err := i.Addrs()
+	if err != nil {
+		return "", err
+	}
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() == nil) != v6 {
+			continue
+		}
+		if v6 && ipnet.IP.IsLinkLocalUnicast() {
+			return ipnet.IP.String() + "%" + iface, nil
+		}
+		return ipnet.IP.String(), nil
+	}
+	return "", errors.New("no address found for interface " + iface)
+}
+
+func addrIP(addr net.Addr) net.IP {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.IPAddr:
+		return addr.IP
+	}
+	return nil
+}
+
+// newPingStats computes the statistics of the round trip times of the echo
+// requests, in the order they were sent. Lost requests have a negative rtt.
+func newPingStats(trans int, rtts []time.Duration) *pingStats {
+	s := &pingStats{trans: trans}
+
+	var sum, sumSquares, jitterSum float64
+	var prev float64
+	jitters := 0
+	for _, rtt := range rtts {
+		if rtt < 0 {
+			continue
+		}
+		ms := float64(rtt) / float64(time.Millisecond)
+		if s.recv == 0 || ms < s.min {
+			s.min = ms
+		}
+		if ms > s.max {
+			s.max = ms
+		}
+		if s.recv > 0 {
+			jitterSum += math.Abs(ms - prev)
+			jitters++
+		}
+		prev = ms
+		sum += ms
+		sumSquares += ms * ms
+		s.recv++
+	}
+
+	if s.recv > 0 {
+		s.avg = sum / float64(s.recv)
+		if variance := sumSquares/float64(s.recv) - s.avg*s.avg; variance > 0 {
+			s.stddev = math.Sqrt(variance)
+		}
+	}
+	if jitters > 0 {
+		s.jitter = jitterSum / float64(jitters)
+	}
+	return s
+}
@@ -8,9 +8,11 @@ import (
 	"runtime"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // BSD/Darwin ping output
@@ -247,3 +249,86 @@ func TestFatalPingGather(t *testing.T) {
 	assert.False(t, acc.HasMeasurement("average_response_ms"),
 		"Fatal ping should not have packet measurements")
 }
+
+// Test that the statistics of the native pinger are computed in the order the
+// requests were sent, ignoring the lost ones
+func TestNewPingStats(t *testing.T) {
+	ms := time.Millisecond
+	stats := newPingStats(5, []time.Duration{10 * ms, -1, 14 * ms, 12 * ms, -1})
+	assert.Equal(t, 5, stats.trans)
+	assert.Equal(t, 3, stats.recv)
+	assert.InDelta(t, 10.0, stats.min, 0.001)
+	assert.InDelta(t, 12.0, stats.avg, 0.001)
+	assert.InDelta(t, 14.0, stats.max, 0.001)
+	assert.InDelta(t, 1.633, stats.stddev, 0.001)
+	assert.InDelta(t, 3.0, stats.jitter, 0.001)
+
+	stats = newPingStats(2, []time.Duration{-1, -1})
+	assert.Equal(t, &pingStats{trans: 2}, stats)
+}
+
+func mockNativePinger(p *Ping, url string) (*pingStats, error) {
+	if url == "www.amazon.com" {
+		return &pingStats{trans: 2}, nil
+	}
+	return &pingStats{trans: 4, recv: 3, min: 10, avg: 12, max: 14, stddev: 1.633, jitter: 3}, nil
+}
+
+// Test that Gather reports the statistics of the native pinger
+func TestNativePingGather(t *testing.T) {
+	var acc testutil.Accumulator
+	p := Ping{
+		Urls:       []string{"www.google.com", "www.amazon.com"},
+		Method:     "native",
+		pingNative: mockNativePinger,
+	}
+
+	assert.NoError(t, p.Gather(&acc))
+	acc.AssertContainsTaggedFields(t, "ping", map[string]interface{}{
+		"packets_transmitted":   4,
+		"packets_received":      3,
+		"percent_packet_loss":   25.0,
+		"minimum_response_ms":   10.0,
+		"average_response_ms":   12.0,
+		"maximum_response_ms":   14.0,
+		"standard_deviation_ms": 1.633,
+		"jitter_ms":             3.0,
+	}, map[string]string{"url": "www.google.com"})
+	acc.AssertContainsTaggedFields(t, "ping", map[string]interface{}{
+		"packets_transmitted": 2,
+		"packets_received":    0,
+		"percent_packet_loss": 100.0,
+	}, map[string]string{"url": "www.amazon.com"})
+}
+
+func TestUnknownMethodPingGather(t *testing.T) {
+	var acc testutil.Accumulator
+	p := Ping{
+		Urls:   []string{"www.google.com"},
+		Method: "carrier-pigeon",
+	}
+	assert.Error(t, p.Gather(&acc))
+}
+
+// Test the native pinger against the loopback interface, when the ICMP
+// sockets can be opened
+func TestNativePingLoopback(t *testing.T) {
+	conn, _, err := listenICMP(false, "")
+	if err != nil {
+		t.Skipf("unable to open ICMP socket: %s", err)
+	}
+	conn.Close()
+
+	p := &Ping{
+		Count:        3,
+		PingInterval: 0.01,
+		Timeout:      1.0,
+	}
+	stats, err := nativePinger(p, "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.trans)
+	assert.Equal(t, 3, stats.recv)
+	assert.True(t, stats.min > 0)
+	assert.True(t, stats.min <= stats.avg)
+	assert.True(t, stats.avg <= stats.max)
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/inputs"
+	parser "github.com/influxdata/telegraf/plugins/parsers/prometheus"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -136,7 +137,7 @@ func (p *Prometheus) gatherURL(url string, acc telegraf.Accumulator) error {
 		return fmt.Errorf("error reading body: %s", err)
 	}
 
-	metrics, err := Parse(body, resp.Header)
+	metrics, err := parser.Parse(body, resp.Header)
 	if err != nil {
 		return fmt.Errorf("error reading metrics for %s: %s",
 			url, err)
@@ -145,7 +146,18 @@ func (p *Prometheus) gatherURL(url string, acc telegraf.Accumulator) error {
 	for _, metric := range metrics {
 		tags := metric.Tags()
 		tags["url"] = url
-		acc.AddFields(metric.Name(), metric.Fields(), tags, collectDate)
+		switch metric.Type() {
+		case telegraf.Counter:
+			acc.AddCounter(metric.Name(), metric.Fields(), tags, collectDate)
+		case telegraf.Gauge:
+			acc.AddGauge(metric.Name(), metric.Fields(), tags, collectDate)
+		case telegraf.Summary:
+			acc.AddSummary(metric.Name(), metric.Fields(), tags, collectDate)
+		case telegraf.Histogram:
+			acc.AddHistogram(metric.Name(), metric.Fields(), tags, collectDate)
+		default:
+			acc.AddFields(metric.Name(), metric.Fields(), tags, collectDate)
+		}
 	}
 
 	return nil
@@ -0,0 +1,859 @@
+package snmp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// mibs holds the MIBs loaded from the `mib_dirs` of all the snmp inputs.
+var mibs = &mibTree{}
+
+// mibTree resolves OIDs, table columns and textual conventions from MIB
+// files, without the net-snmp tools.
+type mibTree struct {
+	sync.RWMutex
+
+	dirs    map[string]bool
+	modules map[string]*mibModule
+
+	byOID  map[string]*mibNode
+	byName map[string][]*mibNode
+}
+
+// mibModule is a parsed MIB module.
+type mibModule struct {
+	name string
+	// imports maps the imported symbols to the module they are imported from.
+	imports map[string]string
+	// types maps the types and textual conventions defined in the module to
+	// the type they are derived from.
+	types   map[string]string
+	objects []*mibObject
+}
+
+// mibObject is an object defined in a MIB module, with its OID still
+// relative to its parent.
+type mibObject struct {
+	name     string
+	value    []oidComponent
+	syntax   string
+	access   string
+	index    []string
+	augments string
+}
+
+type oidComponent struct {
+	name   string
+	number int64 // -1 when the component is a name alone
+}
+
+// mibNode is an object of the tree with its resolved numeric OID.
+type mibNode struct {
+	module string
+	name   string
+	oid    string
+	object *mibObject
+}
+
+// roots of the OID tree, see X.660.
+var mibRoots = map[string]string{
+	"ccitt":           ".0",
+	"iso":             ".1",
+	"joint-iso-ccitt": ".2",
+}
+
+// load reads the MIB files of the directories which weren't loaded yet, and
+// rebuilds the tree. It reports whether any module was added.
+// Files that can't be parsed are skipped, as net-snmp does.
+func (t *mibTree) load(dirs []string) (bool, error) {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.dirs == nil {
+		t.dirs = map[string]bool{}
+		t.modules = map[string]*mibModule{}
+	}
+
+	added := false
+	for _, dir := range dirs {
+		if t.dirs[dir] {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return added, err
+		}
+		for _, fi := range files {
+			if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(dir, fi.Name())
+			buf, err := ioutil.ReadFile(path)
+			if err != nil {
+				return added, err
+			}
+			modules, err := parseMib(string(buf))
+			if err != nil {
+				log.Printf("W! Unable to parse MIB file %s: %s", path, err)
+				continue
+			}
+			for _, m := range modules {
+				if _, ok := t.modules[m.name]; ok {
+					continue
+				}
+				t.modules[m.name] = m
+				added = true
+			}
+		}
+		t.dirs[dir] = true
+	}
+
+	if added {
+		t.resolve()
+	}
+	return added, nil
+}
+
+// resolve computes the numeric OID of all the objects of all the modules.
+func (t *mibTree) resolve() {
+	t.byOID = map[string]*mibNode{}
+	t.byName = map[string][]*mibNode{}
+
+	names := make([]string, 0, len(t.modules))
+	for name := range t.modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type pending struct {
+		module *mibModule
+		object *mibObject
+	}
+	var todo []pending
+	for _, name := range names {
+		for _, o := range t.modules[name].objects {
+			todo = append(todo, pending{t.modules[name], o})
+		}
+	}
+
+	// objects can be defined before their parent, repeat until no more
+	// objects can be resolved.
+	for len(todo) > 0 {
+		var left []pending
+		for _, p := range todo {
+			if !t.resolveObject(p.module, p.object) {
+				left = append(left, p)
+			}
+		}
+		if len(left) == len(todo) {
+			unresolved := map[string]int{}
+			for _, p := range left {
+				unresolved[p.module.name]++
+			}
+			for module, n := range unresolved {
+				log.Printf("W! Unable to resolve the OID of %d objects of MIB %s", n, module)
+			}
+			break
+		}
+		todo = left
+	}
+}
+
+func (t *mibTree) resolveObject(m *mibModule, o *mibObject) bool {
+	first := o.value[0]
+	var oid string
+	switch {
+	case first.name == "":
+		oid = "." + strconv.FormatInt(first.number, 10)
+	case first.number >= 0:
+		oid = "." + strconv.FormatInt(first.number, 10)
+	default:
+		parent := t.lookup(m, first.name)
+		if parent == nil {
+			return false
+		}
+		oid = parent.oid
+	}
+
+	for _, c := range o.value[1:] {
+		if c.number < 0 {
+			// only the first component may be a name alone
+			return false
+		}
+		oid += "." + strconv.FormatInt(c.number, 10)
+		if c.name != "" && t.byOID[oid] == nil {
+			t.add(&mibNode{module: m.name, name: c.name, oid: oid})
+		}
+	}
+
+	t.add(&mibNode{module: m.name, name: o.name, oid: oid, object: o})
+	return true
+}
+
+func (t *mibTree) add(n *mibNode) {
+	if prev, ok := t.byOID[n.oid]; !ok || prev.object == nil {
+		t.byOID[n.oid] = n
+	}
+	t.byName[n.name] = append(t.byName[n.name], n)
+}
+
+// lookup finds a node by name, as seen from module m: the nodes of m first,
+// then the ones of the module it is imported from, then any.
+func (t *mibTree) lookup(m *mibModule, name string) *mibNode {
+	if oid, ok := mibRoots[name]; ok {
+		return &mibNode{name: name, oid: oid}
+	}
+
+	nodes := t.byName[name]
+	if m != nil {
+		for _, n := range nodes {
+			if n.module == m.name {
+				return n
+			}
+		}
+		if m.defines(name) {
+			// not resolved yet
+			return nil
+		}
+		if from, ok := m.imports[name]; ok {
+			for _, n := range nodes {
+				if n.module == from {
+					return n
+				}
+			}
+		}
+	}
+	if len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+func (m *mibModule) defines(name string) bool {
+	for _, o := range m.objects {
+		if o.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// translate resolves an OID, numeric or textual, the way snmpTranslate does.
+// ok is false when the OID can't be resolved from the loaded MIBs.
+func (t *mibTree) translate(oid string) (mibName string, oidNum string, oidText string, conversion string, ok bool) {
+	t.RLock()
+	defer t.RUnlock()
+	return t.translateLocked(oid)
+}
+
+// translateLocked is translate, assuming t's lock is held.
+func (t *mibTree) translateLocked(oid string) (mibName string, oidNum string, oidText string, conversion string, ok bool) {
+	if len(t.byOID) == 0 {
+		return "", "", "", "", false
+	}
+
+	var n *mibNode
+	if strings.ContainsAny(oid, ":abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") {
+		if n, oidNum, ok = t.findName(oid); !ok {
+			return "", "", "", "", false
+		}
+	} else {
+		oidNum = oid
+		if !strings.HasPrefix(oidNum, ".") {
+			oidNum = "." + oidNum
+		}
+		if n, ok = t.findOID(oidNum); !ok {
+			return "", "", "", "", false
+		}
+	}
+
+	if n.module == "" || (oidNum != n.oid && (n.object == nil || n.object.syntax == "")) {
+		// only a root of the tree was found, or a node prefixing the OID
+		// which isn't an object with instances, such as enterprises: let
+		// snmptranslate try with the MIBs it knows of
+		return "", "", "", "", false
+	}
+
+	oidText = n.name + oidNum[len(n.oid):]
+	if n.object != nil {
+		conversion = t.conversion(n.object.syntax)
+	}
+	return n.module, oidNum, oidText, conversion, true
+}
+
+// findOID finds the node with the longest OID prefixing oid.
+func (t *mibTree) findOID(oid string) (*mibNode, bool) {
+	for prefix := oid; prefix != ""; {
+		if n, ok := t.byOID[prefix]; ok {
+			return n, true
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return nil, false
+}
+
+// findName finds the node of a textual OID, "MODULE::name.1.2", "name.1.2"
+// or ".iso.2", and returns it with the numeric form of the OID.
+func (t *mibTree) findName(oid string) (*mibNode, string, bool) {
+	var module string
+	if i := strings.Index(oid, "::"); i >= 0 {
+		module = oid[:i]
+		oid = oid[i+2:]
+		if _, ok := t.modules[module]; !ok {
+			return nil, "", false
+		}
+	}
+
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	var n *mibNode
+	if module != "" {
+		for _, c := range t.byName[parts[0]] {
+			if c.module == module {
+				n = c
+				break
+			}
+		}
+	} else {
+		n = t.lookup(nil, parts[0])
+	}
+	if n == nil {
+		return nil, "", false
+	}
+
+	oidNum := n.oid
+	for _, part := range parts[1:] {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return nil, "", false
+		}
+		oidNum += "." + part
+	}
+	return n, oidNum, true
+}
+
+// conversion returns the conversion of a syntax, following the textual
+// conventions it is derived from.
+func (t *mibTree) conversion(syntax string) string {
+	for i := 0; i < 10 && syntax != ""; i++ {
+		if conversion := textualConventionConversion(syntax); conversion != "" {
+			return conversion
+		}
+		var base string
+		for _, m := range t.modules {
+			if b, ok := m.types[syntax]; ok {
+				base = b
+				break
+			}
+		}
+		syntax = base
+	}
+	return ""
+}
+
+// table resolves a table the way snmpTable does: its columns are the
+// accessible objects of its entry, and the objects indexing the entry are
+// tags.
+func (t *mibTree) table(oid string) (mibName string, oidNum string, oidText string, fields []Field, ok bool) {
+	t.RLock()
+	defer t.RUnlock()
+
+	mibName, oidNum, oidText, _, ok = t.translateLocked(oid)
+	if !ok || mibName == "" {
+		return "", "", "", nil, false
+	}
+
+	entry, ok := t.byOID[oidNum+".1"]
+	if !ok || entry.object == nil {
+		return "", "", "", nil, false
+	}
+
+	tagOids := map[string]struct{}{}
+	index := entry.object.index
+	if entry.object.augments != "" {
+		if augmented := t.lookup(t.modules[entry.module], entry.object.augments); augmented != nil && augmented.object != nil {
+			index = augmented.object.index
+		}
+	}
+	for _, name := range index {
+		tagOids[name] = struct{}{}
+	}
+
+	var columns []*mibNode
+	prefix := entry.oid + "."
+	for o, n := range t.byOID {
+		if strings.HasPrefix(o, prefix) && !strings.Contains(o[len(prefix):], ".") &&
+			n.object != nil && n.object.access != "not-accessible" {
+			columns = append(columns, n)
+		}
+	}
+	if len(columns) == 0 {
+		return "", "", "", nil, false
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		a, _ := strconv.Atoi(columns[i].oid[len(prefix):])
+		b, _ := strconv.Atoi(columns[j].oid[len(prefix):])
+		return a < b
+	})
+
+	mibPrefix := mibName + "::"
+	for _, col := range columns {
+		_, isTag := tagOids[col.name]
+		fields = append(fields, Field{Name: col.name, Oid: mibPrefix + col.name, IsTag: isTag})
+	}
+	return mibName, oidNum, oidText, fields, true
+}
+
+// mibParser parses the subset of ASN.1 used by SMIv1 and SMIv2 MIB modules.
+type mibParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseMib parses the modules defined in a MIB file.
+func parseMib(src string) ([]*mibModule, error) {
+	tokens, err := tokenizeMib(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &mibParser{tokens: tokens}
+
+	var modules []*mibModule
+	for !p.eof() {
+		m, err := p.parseModule()
+		if err != nil {
+			return nil, err
+		}
+		modules = append(modules, m)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("no MIB module found")
+	}
+	return modules, nil
+}
+
+func (p *mibParser) eof() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *mibParser) peek() string {
+	if p.eof() {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *mibParser) next() (string, error) {
+	if p.eof() {
+		return "", fmt.Errorf("unexpected end of file")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok, nil
+}
+
+func (p *mibParser) expect(expected string) error {
+	tok, err := p.next()
+	if err != nil {
+		return err
+	}
+	if tok != expected {
+		return fmt.Errorf("expected %q, found %q", expected, tok)
+	}
+	return nil
+}
+
+// skipUntil skips tokens up to and including tok.
+func (p *mibParser) skipUntil(tok string) error {
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		if t == tok {
+			return nil
+		}
+	}
+}
+
+// skipGroup skips a group of tokens enclosed in balanced brackets, starting
+// at the opening one.
+func (p *mibParser) skipGroup() error {
+	depth := 0
+	for {
+		t, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case "(", "{", "[":
+			depth++
+		case ")", "}", "]":
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *mibParser) parseModule() (*mibModule, error) {
+	name, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	m := &mibModule{
+		name:    name,
+		imports: map[string]string{},
+		types:   map[string]string{},
+	}
+
+	if err := p.expect("DEFINITIONS"); err != nil {
+		return nil, err
+	}
+	if err := p.skipUntil("::="); err != nil {
+		return nil, err
+	}
+	if err := p.expect("BEGIN"); err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok {
+		case "END":
+			return m, nil
+		case "IMPORTS":
+			if err := p.parseImports(m); err != nil {
+				return nil, err
+			}
+			continue
+		case "EXPORTS":
+			if err := p.skipUntil(";"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err := p.parseAssignment(m, tok); err != nil {
+			return nil, fmt.Errorf("%s::%s: %s", m.name, tok, err)
+		}
+	}
+}
+
+func (p *mibParser) parseImports(m *mibModule) error {
+	var symbols []string
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case ";":
+			return nil
+		case ",":
+		case "FROM":
+			from, err := p.next()
+			if err != nil {
+				return err
+			}
+			for _, s := range symbols {
+				m.imports[s] = from
+			}
+			symbols = nil
+		default:
+			symbols = append(symbols, tok)
+		}
+	}
+}
+
+func isTypeReference(name string) bool {
+	return unicode.IsUpper(rune(name[0]))
+}
+
+// parseAssignment parses the definition of name, a type, a macro or a value.
+func (p *mibParser) parseAssignment(m *mibModule, name string) error {
+	switch p.peek() {
+	case "MACRO":
+		// macro definitions, such as OBJECT-TYPE in SNMPv2-SMI, end with
+		// their own END.
+		return p.skipUntil("END")
+	case "::=":
+		p.pos++
+		if isTypeReference(name) {
+			return p.parseTypeAssignment(m, name)
+		}
+		// value assignment without type, such as `name ::= { parent 1 }`
+		return p.parseValue(m, &mibObject{name: name})
+	}
+
+	// value assignment of a macro, such as OBJECT-TYPE, or of a type, such as
+	// OBJECT IDENTIFIER
+	o := &mibObject{name: name}
+	macro, err := p.next()
+	if err != nil {
+		return err
+	}
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if tok == "::=" {
+			break
+		}
+		if macro != "OBJECT-TYPE" {
+			continue
+		}
+
+		switch tok {
+		case "SYNTAX":
+			if o.syntax, err = p.parseType(); err != nil {
+				return err
+			}
+		case "ACCESS", "MAX-ACCESS":
+			if o.access, err = p.next(); err != nil {
+				return err
+			}
+		case "INDEX":
+			if o.index, err = p.parseNames(); err != nil {
+				return err
+			}
+		case "AUGMENTS":
+			names, err := p.parseNames()
+			if err != nil {
+				return err
+			}
+			if len(names) == 1 {
+				o.augments = names[0]
+			}
+		case "(", "{", "[":
+			// such as DEFVAL
+			p.pos--
+			if err := p.skipGroup(); err != nil {
+				return err
+			}
+		}
+	}
+	return p.parseValue(m, o)
+}
+
+func (p *mibParser) parseTypeAssignment(m *mibModule, name string) error {
+	if p.peek() == "TEXTUAL-CONVENTION" {
+		for {
+			tok, err := p.next()
+			if err != nil {
+				return err
+			}
+			if tok == "SYNTAX" {
+				break
+			}
+		}
+	}
+	base, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	m.types[name] = base
+	return nil
+}
+
+// parseType parses a type, with its tag and constraints, and returns its
+// name.
+func (p *mibParser) parseType() (string, error) {
+	if p.peek() == "[" {
+		if err := p.skipGroup(); err != nil {
+			return "", err
+		}
+	}
+	if p.peek() == "IMPLICIT" || p.peek() == "EXPLICIT" {
+		p.pos++
+	}
+
+	name, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	switch name {
+	case "OCTET":
+		if err := p.expect("STRING"); err != nil {
+			return "", err
+		}
+		name = "OCTET STRING"
+	case "OBJECT":
+		if err := p.expect("IDENTIFIER"); err != nil {
+			return "", err
+		}
+		name = "OBJECT IDENTIFIER"
+	case "SEQUENCE", "SET":
+		if p.peek() == "OF" {
+			p.pos++
+			return p.parseType()
+		}
+	}
+
+	// constraints, enumerations, or the elements of a SEQUENCE or CHOICE
+	for p.peek() == "(" || p.peek() == "{" {
+		if err := p.skipGroup(); err != nil {
+			return "", err
+		}
+	}
+	return name, nil
+}
+
+// parseNames parses a list of names, such as the ones of an INDEX clause.
+func (p *mibParser) parseNames() ([]string, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch tok {
+		case "}":
+			return names, nil
+		case ",", "IMPLIED":
+		default:
+			names = append(names, tok)
+		}
+	}
+}
+
+// parseValue parses the value of an assignment, adding o to m when it is an
+// OID.
+func (p *mibParser) parseValue(m *mibModule, o *mibObject) error {
+	if p.peek() != "{" {
+		// such as the trap number of a TRAP-TYPE
+		_, err := p.next()
+		return err
+	}
+	p.pos++
+
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+		if tok == "}" {
+			break
+		}
+		if tok == "," || tok == "{" {
+			// not an OID
+			p.pos--
+			for p.peek() != "}" {
+				if p.peek() == "{" {
+					if err := p.skipGroup(); err != nil {
+						return err
+					}
+					continue
+				}
+				p.pos++
+			}
+			p.pos++
+			return nil
+		}
+
+		c := oidComponent{number: -1}
+		if n, err := strconv.ParseInt(tok, 10, 64); err == nil {
+			c.number = n
+		} else {
+			c.name = tok
+			if p.peek() == "(" {
+				p.pos++
+				number, err := p.next()
+				if err != nil {
+					return err
+				}
+				if c.number, err = strconv.ParseInt(number, 10, 64); err != nil {
+					return fmt.Errorf("invalid OID component %s(%s)", tok, number)
+				}
+				if err := p.expect(")"); err != nil {
+					return err
+				}
+			}
+		}
+		o.value = append(o.value, c)
+	}
+
+	if len(o.value) == 0 {
+		return nil
+	}
+	m.objects = append(m.objects, o)
+	return nil
+}
+
+// tokenizeMib splits a MIB file into tokens, dropping the comments. Strings
+// are kept as a single token, with their quotes.
+func tokenizeMib(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			// comments end at the end of the line. As net-snmp, and unlike
+			// ASN.1, a second "--" on the line doesn't end them, since many
+			// MIBs have comments such as "-- name -- description".
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			end := strings.IndexByte(src[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, src[i:i+end+2])
+			i += end + 2
+		case c == '\'':
+			// binary or hexadecimal string, such as '00'H
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			end += i + 2
+			if end < len(src) && (src[end] == 'H' || src[end] == 'h' || src[end] == 'B' || src[end] == 'b') {
+				end++
+			}
+			tokens = append(tokens, src[i:end])
+			i = end
+		case strings.HasPrefix(src[i:], "::="):
+			tokens = append(tokens, "::=")
+			i += 3
+		case strings.HasPrefix(src[i:], ".."):
+			tokens = append(tokens, "..")
+			i += 2
+		case strings.IndexByte("{}()[],;|.", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case isMibWordChar(c) || (c == '-' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9'):
+			start := i
+			for i++; i < len(src) && isMibWordChar(src[i]) && !strings.HasPrefix(src[i:], "--"); i++ {
+			}
+			tokens = append(tokens, src[start:i])
+		default:
+			return nil, fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return tokens, nil
+}
+
+func isMibWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
@@ -0,0 +1,168 @@
+package snmp
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeMib(t *testing.T) {
+	tokens, err := tokenizeMib(`a ::= { b 1 } -- comment
+c-d OBJECT-TYPE -- comment -- still a comment
+	SYNTAX "x -- y" (-1..10) '0F'H`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a", "::=", "{", "b", "1", "}",
+		"c-d", "OBJECT-TYPE", "SYNTAX", `"x -- y"`,
+		"(", "-1", "..", "10", ")", "'0F'H",
+	}, tokens)
+
+	_, err = tokenizeMib(`"unterminated`)
+	assert.Error(t, err)
+	_, err = tokenizeMib(`# not a MIB`)
+	assert.Error(t, err)
+}
+
+func TestParseMib(t *testing.T) {
+	buf, err := ioutil.ReadFile("testdata/test2.mib")
+	require.NoError(t, err)
+	modules, err := parseMib(string(buf))
+	require.NoError(t, err)
+	require.Len(t, modules, 1)
+
+	m := modules[0]
+	assert.Equal(t, "TEST2-MIB", m.name)
+	assert.Equal(t, "SNMPv2-TC", m.imports["PhysAddress"])
+	assert.Equal(t, "SNMPv2-SMI", m.imports["Integer32"])
+	assert.Equal(t, map[string]string{
+		"PortAddress":    "PhysAddress",
+		"PortStatus":     "INTEGER",
+		"PortEntry":      "SEQUENCE",
+		"PortStatsEntry": "SEQUENCE",
+	}, m.types)
+
+	objects := map[string]*mibObject{}
+	for _, o := range m.objects {
+		objects[o.name] = o
+	}
+	assert.Len(t, objects, 12)
+	assert.Equal(t, []oidComponent{
+		{name: "iso", number: -1},
+		{name: "org", number: 3},
+		{name: "dod", number: 6},
+		{name: "internet", number: 1},
+		{name: "private", number: 4},
+		{name: "enterprises", number: 1},
+		{number: 999},
+	}, objects["test2MIB"].value)
+	assert.Equal(t, []string{"portIndex", "portName"}, objects["portEntry"].index)
+	assert.Equal(t, "portEntry", objects["portStatsEntry"].augments)
+	assert.Equal(t, "PortEntry", objects["portTable"].syntax)
+	assert.Equal(t, "Integer32", objects["portIndex"].syntax)
+	assert.Equal(t, "not-accessible", objects["portIndex"].access)
+	assert.Equal(t, "PortAddress", objects["portAddress"].syntax)
+}
+
+func TestParseMib_invalid(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"TEST DEFINITIONS ::= BEGIN",
+		"TEST ::= BEGIN END",
+		"TEST DEFINITIONS ::= BEGIN a ::= { b c(x) } END",
+	} {
+		_, err := parseMib(src)
+		assert.Error(t, err, src)
+	}
+}
+
+func newTestMibTree(t *testing.T) *mibTree {
+	tree := &mibTree{}
+	added, err := tree.load([]string{"testdata"})
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	added, err = tree.load([]string{"testdata"})
+	require.NoError(t, err)
+	assert.False(t, added)
+	return tree
+}
+
+func TestMibTranslate(t *testing.T) {
+	tree := newTestMibTree(t)
+
+	tests := []struct {
+		oid        string
+		mibName    string
+		oidNum     string
+		oidText    string
+		conversion string
+	}{
+		{".1.0.0.1.1", "TEST", ".1.0.0.1.1", "hostname", ""},
+		{"1.0.0.1.1", "TEST", ".1.0.0.1.1", "hostname", ""},
+		{".1.0.0.0.1.1.0", "TEST", ".1.0.0.0.1.1.0", "server.0", ""},
+		{".1.0.0.0.1.4", "TEST", ".1.0.0.0.1.4", "testTableEntry.4", ""},
+		{"TEST::server.0", "TEST", ".1.0.0.0.1.1.0", "server.0", ""},
+		{"TEST::testTable", "TEST", ".1.0.0.0", "testTable", ""},
+		{"hostname", "TEST", ".1.0.0.1.1", "hostname", ""},
+		{"TEST2-MIB::enterprises", "TEST2-MIB", ".1.3.6.1.4.1", "enterprises", ""},
+		{".1.3.6.1.4.1.999.1.1.1.3.5", "TEST2-MIB", ".1.3.6.1.4.1.999.1.1.1.3.5", "portAddress.5", "hwaddr"},
+		{"portAddress.5", "TEST2-MIB", ".1.3.6.1.4.1.999.1.1.1.3.5", "portAddress.5", "hwaddr"},
+		{".1.3.6.1.4.1.999.0.1", "TEST2-MIB", ".1.3.6.1.4.1.999.0.1", "portDown", ""},
+	}
+	for _, tt := range tests {
+		mibName, oidNum, oidText, conversion, ok := tree.translate(tt.oid)
+		require.True(t, ok, tt.oid)
+		assert.Equal(t, tt.mibName, mibName, tt.oid)
+		assert.Equal(t, tt.oidNum, oidNum, tt.oid)
+		assert.Equal(t, tt.oidText, oidText, tt.oid)
+		assert.Equal(t, tt.conversion, conversion, tt.oid)
+	}
+
+	// left to snmptranslate
+	for _, oid := range []string{".999", ".1.2.3", "NOPE-MIB::nope", "TEST::nope", "TEST::server.x", "iso.2.3",
+		".1.3.6.1.4.1.1234.1", "TEST2-MIB::enterprises.1234", ".1.3.6.1.4.1.999.5.1"} {
+		_, _, _, _, ok := tree.translate(oid)
+		assert.False(t, ok, oid)
+	}
+}
+
+func TestMibTranslate_empty(t *testing.T) {
+	_, _, _, _, ok := (&mibTree{}).translate(".1.0.0.1.1")
+	assert.False(t, ok)
+}
+
+func TestMibTable(t *testing.T) {
+	tree := newTestMibTree(t)
+
+	mibName, oidNum, oidText, fields, ok := tree.table("TEST::testTable")
+	require.True(t, ok)
+	assert.Equal(t, "TEST", mibName)
+	assert.Equal(t, ".1.0.0.0", oidNum)
+	assert.Equal(t, "testTable", oidText)
+	assert.Equal(t, []Field{
+		{Name: "server", Oid: "TEST::server", IsTag: true},
+		{Name: "connections", Oid: "TEST::connections"},
+		{Name: "latency", Oid: "TEST::latency"},
+	}, fields)
+
+	// not-accessible columns are skipped
+	_, _, _, fields, ok = tree.table(".1.3.6.1.4.1.999.1.1")
+	require.True(t, ok)
+	assert.Equal(t, []Field{
+		{Name: "portName", Oid: "TEST2-MIB::portName", IsTag: true},
+		{Name: "portAddress", Oid: "TEST2-MIB::portAddress"},
+		{Name: "portStatus", Oid: "TEST2-MIB::portStatus"},
+	}, fields)
+
+	// the index of augmenting tables is the one of the augmented table
+	_, _, _, fields, ok = tree.table("TEST2-MIB::portStatsTable")
+	require.True(t, ok)
+	assert.Equal(t, []Field{
+		{Name: "portInOctets", Oid: "TEST2-MIB::portInOctets"},
+	}, fields)
+
+	_, _, _, _, ok = tree.table("TEST::hostname")
+	assert.False(t, ok)
+}
@@ -34,6 +34,11 @@ const sampleConfig = `
   ## The GETBULK max-repetitions parameter
   max_repetitions = 10
 
+  ## Directories to load MIB files from, to resolve OIDs without the net-snmp
+  ## tools. OIDs which can't be resolved from these MIBs are looked up with
+  ## snmptranslate.
+  # mib_dirs = ["/usr/share/snmp/mibs"]
+
   ## SNMPv3 auth parameters
   #sec_name = "myuser"
   #auth_protocol = "md5"      # Values: "MD5", "SHA", ""
@@ -127,6 +132,9 @@ type Snmp struct {
 	EngineBoots  uint32
 	EngineTime   uint32
 
+	// Directories to load MIB files from.
+	MibDirs []string
+
 	Tables []Table `toml:"table"`
 
 	// Name & Fields are the elements of a Table.
@@ -144,6 +152,10 @@ func (s *Snmp) init() error {
 		return nil
 	}
 
+	if err := LoadMibs(s.MibDirs); err != nil {
+		return err
+	}
+
 	for i := range s.Tables {
 		if err := s.Tables[i].init(); err != nil {
 			return Errorf(err, "initializing table %s", s.Tables[i].Name)
@@ -201,8 +213,8 @@ func (t *Table) init() error {
 }
 
 // initBuild initializes the table if it has an OID configured. If so, the
-// net-snmp tools will be used to look up the OID and auto-populate the table's
-// fields.
+// loaded MIBs or the net-snmp tools will be used to look up the OID and
+// auto-populate the table's fields.
 func (t *Table) initBuild() error {
 	if t.Oid == "" {
 		return nil
@@ -824,7 +836,22 @@ func snmpTable(oid string) (mibName string, oidNum string, oidText string, field
 	return stc.mibName, stc.oidNum, stc.oidText, stc.fields, stc.err
 }
 
+// resetCaches clears the results of the previous lookups.
+func resetCaches() {
+	snmpTableCachesLock.Lock()
+	snmpTableCaches = nil
+	snmpTableCachesLock.Unlock()
+
+	snmpTranslateCachesLock.Lock()
+	snmpTranslateCaches = nil
+	snmpTranslateCachesLock.Unlock()
+}
+
 func snmpTableCall(oid string) (mibName string, oidNum string, oidText string, fields []Field, err error) {
+	if mibName, oidNum, oidText, fields, ok := mibs.table(oid); ok {
+		return mibName, oidNum, oidText, fields, nil
+	}
+
 	mibName, oidNum, oidText, _, err = snmpTranslate(oid)
 	if err != nil {
 		return "", "", "", nil, Errorf(err, "translating")
@@ -891,6 +918,39 @@ type snmpTranslateCache struct {
 var snmpTranslateCachesLock sync.Mutex
 var snmpTranslateCaches map[string]snmpTranslateCache
 
+// LoadMibs loads the MIB files found in dirs, for the OIDs to be resolved
+// from them by the snmp input as well as by Translate.
+func LoadMibs(dirs []string) error {
+	if len(dirs) == 0 {
+		return nil
+	}
+	added, err := mibs.load(dirs)
+	if err != nil {
+		return Errorf(err, "loading MIBs")
+	}
+	if added {
+		// previous lookups may have been done without these MIBs
+		resetCaches()
+	}
+	return nil
+}
+
+// Translate resolves the given OID the same way the snmp input does, from
+// the loaded MIBs first and with snmptranslate otherwise. It is shared with
+// the other SNMP plugins, so they name OIDs alike. Unlike the lookups of the
+// snmp input, the results aren't cached, as the OIDs of the other plugins,
+// such as those of received traps, are up to the agents: callers bound their
+// own cache.
+func Translate(oid string) (mibName string, oidNum string, oidText string, conversion string, err error) {
+	return snmpTranslateCall(oid)
+}
+
+// TranslateMIB resolves the given OID from the loaded MIBs only, without
+// running snmptranslate, ok being false when they don't define it.
+func TranslateMIB(oid string) (mibName string, oidNum string, oidText string, conversion string, ok bool) {
+	return mibs.translate(oid)
+}
+
 // snmpTranslate resolves the given OID.
 func snmpTranslate(oid string) (mibName string, oidNum string, oidText string, conversion string, err error) {
 	snmpTranslateCachesLock.Lock()
@@ -919,6 +979,10 @@ func snmpTranslate(oid string) (mibName string, oidNum string, oidText string, c
 }
 
 func snmpTranslateCall(oid string) (mibName string, oidNum string, oidText string, conversion string, err error) {
+	if mibName, oidNum, oidText, conversion, ok := mibs.translate(oid); ok {
+		return mibName, oidNum, oidText, conversion, nil
+	}
+
 	var out []byte
 	if strings.ContainsAny(oid, ":abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") {
 		out, err = execCmd("snmptranslate", "-Td", "-Ob", oid)
@@ -962,12 +1026,7 @@ func snmpTranslateCall(oid string) (mibName string, oidNum string, oidText strin
 			return "", "", "", "", Errorf(err, "getting textual convention")
 		}
 		tc = tc[:len(tc)-1]
-		switch tc {
-		case "MacAddress", "PhysAddress":
-			conversion = "hwaddr"
-		case "InetAddressIPv4", "InetAddressIPv6", "InetAddress":
-			conversion = "ipaddr"
-		}
+		conversion = textualConventionConversion(tc)
 	}
 
 	i = bytes.Index(bb.Bytes(), []byte("::= { "))
@@ -991,3 +1050,15 @@ func snmpTranslateCall(oid string) (mibName string, oidNum string, oidText strin
 
 	return mibName, oidNum, oidText, conversion, nil
 }
+
+// textualConventionConversion returns the conversion of the values of a
+// textual convention.
+func textualConventionConversion(tc string) string {
+	switch tc {
+	case "MacAddress", "PhysAddress":
+		return "hwaddr"
+	case "InetAddressIPv4", "InetAddressIPv6", "InetAddress":
+		return "ipaddr"
+	}
+	return ""
+}
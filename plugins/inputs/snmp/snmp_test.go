@@ -249,6 +249,46 @@ func TestSnmpInit_noTranslate(t *testing.T) {
 	assert.Equal(t, false, s.Tables[0].Fields[2].IsTag)
 }
 
+func TestSnmpInit_mibDirs(t *testing.T) {
+	// snmptranslate must not be needed
+	defer func(ec func(string, ...string) *exec.Cmd) { execCommand = ec }(execCommand)
+	execCommand = func(_ string, _ ...string) *exec.Cmd {
+		return exec.Command("snmptranslateExecErrNotFound")
+	}
+	defer func(m *mibTree) {
+		mibs = m
+		resetCaches()
+	}(mibs)
+	mibs = &mibTree{}
+
+	s := &Snmp{
+		MibDirs: []string{"testdata"},
+		Tables: []Table{
+			{Oid: "TEST2-MIB::portTable"},
+		},
+		Fields: []Field{
+			{Oid: "TEST2-MIB::portAddress.1"},
+		},
+	}
+
+	err := s.init()
+	require.NoError(t, err)
+
+	assert.Equal(t, "portTable", s.Tables[0].Name)
+	assert.Equal(t, []Field{
+		{Oid: ".1.3.6.1.4.1.999.1.1.1.2", Name: "portName", IsTag: true, initialized: true},
+		{Oid: ".1.3.6.1.4.1.999.1.1.1.3", Name: "portAddress", Conversion: "hwaddr", initialized: true},
+		{Oid: ".1.3.6.1.4.1.999.1.1.1.4", Name: "portStatus", initialized: true},
+	}, s.Tables[0].Fields)
+
+	assert.Equal(t, Field{
+		Oid:         ".1.3.6.1.4.1.999.1.1.1.3.1",
+		Name:        "portAddress.1",
+		Conversion:  "hwaddr",
+		initialized: true,
+	}, s.Fields[0])
+}
+
 func TestGetSNMPConnection_v2(t *testing.T) {
 	s := &Snmp{
 		Timeout:   internal.Duration{Duration: 3 * time.Second},
@@ -0,0 +1,328 @@
+package snmp_trap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ASN.1 BER types used by SNMP, see RFC2578 and RFC3416.
+const (
+	tagInteger        byte = 0x02
+	tagOctetString    byte = 0x04
+	tagNull           byte = 0x05
+	tagObjectID       byte = 0x06
+	tagSequence       byte = 0x30
+	tagIPAddress      byte = 0x40
+	tagCounter32      byte = 0x41
+	tagGauge32        byte = 0x42
+	tagTimeTicks      byte = 0x43
+	tagOpaque         byte = 0x44
+	tagCounter64      byte = 0x46
+	tagNoSuchObject   byte = 0x80
+	tagNoSuchInstance byte = 0x81
+	tagEndOfMibView   byte = 0x82
+
+	pduResponse byte = 0xa2
+	pduTrapV1   byte = 0xa4
+	pduInform   byte = 0xa6
+	pduTrapV2   byte = 0xa7
+	pduReport   byte = 0xa8
+)
+
+var errTruncated = errors.New("truncated BER value")
+
+// berReader reads BER encoded values from a buffer.
+type berReader struct {
+	buf []byte
+}
+
+// next reads the next tag-length-value, returning its tag and value.
+func (r *berReader) next() (byte, []byte, error) {
+	if len(r.buf) < 2 {
+		return 0, nil, errTruncated
+	}
+	tag := r.buf[0]
+	length := int(r.buf[1])
+	offset := 2
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(r.buf) < 2+n {
+			return 0, nil, fmt.Errorf("invalid BER length")
+		}
+		length = 0
+		for _, b := range r.buf[2 : 2+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if length < 0 || len(r.buf) < offset+length {
+		return 0, nil, errTruncated
+	}
+	value := r.buf[offset : offset+length]
+	r.buf = r.buf[offset+length:]
+	return tag, value, nil
+}
+
+// expect reads the next value, checking its tag.
+func (r *berReader) expect(tag byte) ([]byte, error) {
+	t, value, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if t != tag {
+		return nil, fmt.Errorf("unexpected BER tag 0x%02x, expected 0x%02x", t, tag)
+	}
+	return value, nil
+}
+
+func (r *berReader) readInt() (int64, error) {
+	value, err := r.expect(tagInteger)
+	if err != nil {
+		return 0, err
+	}
+	return decodeInt(value)
+}
+
+func (r *berReader) readOctetString() ([]byte, error) {
+	return r.expect(tagOctetString)
+}
+
+func (r *berReader) readOID() (string, error) {
+	value, err := r.expect(tagObjectID)
+	if err != nil {
+		return "", err
+	}
+	return decodeOID(value)
+}
+
+func decodeInt(b []byte) (int64, error) {
+	if len(b) == 0 || len(b) > 8 {
+		return 0, fmt.Errorf("invalid BER integer length %d", len(b))
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v, nil
+}
+
+func decodeUint(b []byte) (uint64, error) {
+	if len(b) == 0 || len(b) > 9 || (len(b) == 9 && b[0] != 0) {
+		return 0, fmt.Errorf("invalid BER unsigned length %d", len(b))
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// decodeOID decodes an object identifier into its dotted form, with a
+// leading dot, as in ".1.3.6.1".
+func decodeOID(b []byte) (string, error) {
+	if len(b) == 0 {
+		return "", fmt.Errorf("empty object identifier")
+	}
+
+	var buf bytes.Buffer
+	var sub uint64
+	first := true
+	for i, c := range b {
+		sub = sub<<7 | uint64(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return "", fmt.Errorf("truncated object identifier")
+			}
+			continue
+		}
+		if first {
+			// the first sub-identifier encodes the first two components
+			x := sub / 40
+			if x > 2 {
+				x = 2
+			}
+			buf.WriteString("." + strconv.FormatUint(x, 10))
+			sub -= x * 40
+			first = false
+		}
+		buf.WriteString("." + strconv.FormatUint(sub, 10))
+		sub = 0
+	}
+	return buf.String(), nil
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func encodeTLV(tag byte, value []byte) []byte {
+	b := append([]byte{tag}, encodeLength(len(value))...)
+	return append(b, value...)
+}
+
+func encodeSequence(tag byte, values ...[]byte) []byte {
+	return encodeTLV(tag, bytes.Join(values, nil))
+}
+
+func encodeInt(tag byte, v int64) []byte {
+	b := []byte{byte(v)}
+	for v > 127 || v < -128 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return encodeTLV(tag, b)
+}
+
+func encodeUint(tag byte, v uint64) []byte {
+	b := []byte{byte(v)}
+	for v > 0xff {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return encodeTLV(tag, b)
+}
+
+func encodeOID(oid string) ([]byte, error) {
+	parts := strings.Split(strings.TrimPrefix(oid, "."), ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid object identifier %q", oid)
+	}
+	subs := make([]uint64, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid object identifier %q", oid)
+		}
+		subs[i] = v
+	}
+
+	var b []byte
+	subs = append([]uint64{subs[0]*40 + subs[1]}, subs[2:]...)
+	for _, sub := range subs {
+		enc := []byte{byte(sub & 0x7f)}
+		for sub >>= 7; sub > 0; sub >>= 7 {
+			enc = append([]byte{byte(sub&0x7f) | 0x80}, enc...)
+		}
+		b = append(b, enc...)
+	}
+	return encodeTLV(tagObjectID, b), nil
+}
+
+// varbind is a variable binding of a PDU, its value is kept as encoded so
+// that it can be sent back unchanged in the response to an inform.
+type varbind struct {
+	oid   string
+	tag   byte
+	value []byte
+}
+
+// pdu is an SNMP protocol data unit.
+type pdu struct {
+	kind byte
+
+	requestID   int64
+	errorStatus int64
+	errorIndex  int64
+
+	// SNMPv1 Trap-PDU fields
+	enterprise   string
+	agentAddress []byte
+	genericTrap  int64
+	specificTrap int64
+	timestamp    uint64
+
+	varbinds []varbind
+}
+
+func decodePDU(tag byte, value []byte) (*pdu, error) {
+	p := &pdu{kind: tag}
+	r := &berReader{buf: value}
+	var err error
+
+	if tag == pduTrapV1 {
+		if p.enterprise, err = r.readOID(); err != nil {
+			return nil, err
+		}
+		if p.agentAddress, err = r.expect(tagIPAddress); err != nil {
+			return nil, err
+		}
+		if p.genericTrap, err = r.readInt(); err != nil {
+			return nil, err
+		}
+		if p.specificTrap, err = r.readInt(); err != nil {
+			return nil, err
+		}
+		ticks, err := r.expect(tagTimeTicks)
+		if err != nil {
+			return nil, err
+		}
+		if p.timestamp, err = decodeUint(ticks); err != nil {
+			return nil, err
+		}
+	} else {
+		if p.requestID, err = r.readInt(); err != nil {
+			return nil, err
+		}
+		if p.errorStatus, err = r.readInt(); err != nil {
+			return nil, err
+		}
+		if p.errorIndex, err = r.readInt(); err != nil {
+			return nil, err
+		}
+	}
+
+	list, err := r.expect(tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	lr := &berReader{buf: list}
+	for len(lr.buf) > 0 {
+		vb, err := lr.expect(tagSequence)
+		if err != nil {
+			return nil, err
+		}
+		vr := &berReader{buf: vb}
+		oid, err := vr.readOID()
+		if err != nil {
+			return nil, err
+		}
+		vtag, vvalue, err := vr.next()
+		if err != nil {
+			return nil, err
+		}
+		p.varbinds = append(p.varbinds, varbind{oid: oid, tag: vtag, value: vvalue})
+	}
+
+	return p, nil
+}
+
+func (p *pdu) encode() ([]byte, error) {
+	var list [][]byte
+	for _, vb := range p.varbinds {
+		oid, err := encodeOID(vb.oid)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, encodeSequence(tagSequence, oid, encodeTLV(vb.tag, vb.value)))
+	}
+
+	return encodeSequence(p.kind,
+		encodeInt(tagInteger, p.requestID),
+		encodeInt(tagInteger, p.errorStatus),
+		encodeInt(tagInteger, p.errorIndex),
+		encodeSequence(tagSequence, list...),
+	), nil
+}
@@ -0,0 +1,393 @@
+package snmp_trap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+	"github.com/influxdata/telegraf/plugins/inputs/snmp"
+)
+
+// snmpTrapOID is the varbind holding the trap OID of SNMPv2 notifications,
+// sysUpTime the one holding the uptime of the sender.
+const (
+	sysUpTimeOID   = ".1.3.6.1.2.1.1.3.0"
+	snmpTrapOID    = ".1.3.6.1.6.3.1.1.4.1.0"
+	genericTrapOID = ".1.3.6.1.6.3.1.1.5"
+)
+
+// snmpTranslate and snmpTranslateMIB are so tests can mock out the
+// translation of OIDs.
+var (
+	snmpTranslate    = snmp.Translate
+	snmpTranslateMIB = snmp.TranslateMIB
+)
+
+// translateCacheSize is the number of OIDs translated with snmptranslate
+// which are cached.
+const translateCacheSize = 1000
+
+type SnmpTrap struct {
+	ServiceAddress string `toml:"service_address"`
+
+	// Parameters for Version 1 & 2, only traps sent with this community are
+	// accepted when set.
+	Community string
+
+	// Parameters for Version 3
+	// Values: "noAuthNoPriv", "authNoPriv", "authPriv"
+	SecLevel string
+	SecName  string
+	// Values: "MD5", "SHA", "". Default: ""
+	AuthProtocol string
+	AuthPassword string
+	// Values: "DES", "AES", "". Default: ""
+	PrivProtocol string
+	PrivPassword string
+	// Engine ID in hexadecimal, authoritative for the informs received.
+	EngineID string
+
+	// Directories to load MIB files from.
+	MibDirs []string
+
+	acc  telegraf.Accumulator
+	conn net.PacketConn
+	usm  *usm
+	wg   sync.WaitGroup
+
+	translateCache map[string]translation
+}
+
+type translation struct {
+	mibName string
+	oidText string
+}
+
+var sampleConfig = `
+  ## Address to listen on for traps and informs, port 162 requires root
+  ## privileges.
+  # service_address = "udp://:162"
+
+  ## Only accept SNMPv1 and v2c traps sent with this community, when set.
+  # community = "public"
+
+  ## Directories to load MIB files from, shared with the snmp input. OIDs
+  ## which can't be resolved from these MIBs are looked up with
+  ## snmptranslate.
+  # mib_dirs = ["/usr/share/snmp/mibs"]
+
+  ## SNMPv3 USM parameters of the user sending traps and informs.
+  #sec_name = "myuser"
+  #auth_protocol = "md5"      # Values: "MD5", "SHA", ""
+  #auth_password = "pass"
+  #sec_level = "authNoPriv"   # Values: "noAuthNoPriv", "authNoPriv", "authPriv"
+  #priv_protocol = ""         # Values: "DES", "AES", ""
+  #priv_password = ""
+  ## Engine ID in hexadecimal, senders of informs need it to localize their
+  ## keys. A random engine ID is used when empty.
+  #engine_id = "80001f8880e0d5fa1b6b000000"
+`
+
+func (s *SnmpTrap) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SnmpTrap) Description() string {
+	return "Receive SNMP traps and informs"
+}
+
+func (s *SnmpTrap) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+func (s *SnmpTrap) Start(acc telegraf.Accumulator) error {
+	s.acc = acc
+
+	if err := snmp.LoadMibs(s.MibDirs); err != nil {
+		return err
+	}
+
+	engineID, err := hex.DecodeString(s.EngineID)
+	if err != nil {
+		return fmt.Errorf("invalid engine_id: %s", err)
+	}
+	s.usm, err = newUSM(s.SecName, s.SecLevel, s.AuthProtocol, s.AuthPassword,
+		s.PrivProtocol, s.PrivPassword, engineID)
+	if err != nil {
+		return err
+	}
+
+	spl := strings.SplitN(s.ServiceAddress, "://", 2)
+	if len(spl) != 2 {
+		return fmt.Errorf("invalid service address: %s", s.ServiceAddress)
+	}
+	switch spl[0] {
+	case "udp", "udp4", "udp6":
+	default:
+		return fmt.Errorf("unknown protocol '%s' in '%s'", spl[0], s.ServiceAddress)
+	}
+
+	s.conn, err = net.ListenPacket(spl[0], spl[1])
+	if err != nil {
+		return err
+	}
+	s.translateCache = map[string]translation{}
+
+	s.wg.Add(1)
+	go s.listen()
+	return nil
+}
+
+func (s *SnmpTrap) Stop() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.wg.Wait()
+}
+
+func (s *SnmpTrap) listen() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
+				s.acc.AddError(err)
+			}
+			return
+		}
+
+		if err := s.handle(buf[:n], addr); err != nil {
+			s.acc.AddError(fmt.Errorf("SNMP trap from %s: %s", addr, err))
+		}
+	}
+}
+
+// handle decodes an SNMP message, acknowledges informs and adds traps to the
+// accumulator.
+func (s *SnmpTrap) handle(buf []byte, addr net.Addr) error {
+	outer := &berReader{buf: buf}
+	msg, err := outer.expect(tagSequence)
+	if err != nil {
+		return err
+	}
+	whole := buf[:len(buf)-len(outer.buf)]
+	r := &berReader{buf: msg}
+	version, err := r.readInt()
+	if err != nil {
+		return err
+	}
+
+	var p *pdu
+	var response []byte
+	switch version {
+	case 0, 1:
+		community, err := r.readOctetString()
+		if err != nil {
+			return err
+		}
+		if s.Community != "" && string(community) != s.Community {
+			return fmt.Errorf("unknown community %q", community)
+		}
+		tag, value, err := r.next()
+		if err != nil {
+			return err
+		}
+		if p, err = decodePDU(tag, value); err != nil {
+			return err
+		}
+		if p.kind == pduInform {
+			rp := &pdu{kind: pduResponse, requestID: p.requestID, varbinds: p.varbinds}
+			encoded, err := rp.encode()
+			if err != nil {
+				return err
+			}
+			response = encodeSequence(tagSequence,
+				encodeInt(tagInteger, version),
+				encodeTLV(tagOctetString, community),
+				encoded,
+			)
+		}
+	case 3:
+		m, report, err := s.usm.process(whole, r)
+		if err != nil {
+			return err
+		}
+		if report != nil {
+			_, err := s.conn.WriteTo(report, addr)
+			return err
+		}
+		p = m.pdu
+		if p.kind == pduInform {
+			rp := &pdu{kind: pduResponse, requestID: p.requestID, varbinds: p.varbinds}
+			flags := m.flags &^ flagReportable
+			if response, err = s.usm.encode(m.msgID, flags, m.userName, m.contextName, rp); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported SNMP version %d", version)
+	}
+
+	switch p.kind {
+	case pduTrapV1, pduTrapV2, pduInform:
+	default:
+		return fmt.Errorf("unexpected PDU type 0x%02x", p.kind)
+	}
+
+	if response != nil {
+		if _, err := s.conn.WriteTo(response, addr); err != nil {
+			return err
+		}
+	}
+
+	s.addTrap(p, version, addr)
+	return nil
+}
+
+// addTrap adds the varbinds of a trap to the accumulator, under the name of
+// its trap OID.
+func (s *SnmpTrap) addTrap(p *pdu, version int64, addr net.Addr) {
+	fields := map[string]interface{}{}
+	tags := map[string]string{}
+
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		tags["source"] = host
+	} else {
+		tags["source"] = addr.String()
+	}
+
+	switch version {
+	case 0:
+		tags["version"] = "1"
+	case 1:
+		tags["version"] = "2c"
+	case 3:
+		tags["version"] = "3"
+	}
+
+	var trapOID string
+	if p.kind == pduTrapV1 {
+		// map the SNMPv1 trap to its SNMPv2 trap OID, see RFC3584
+		if p.genericTrap >= 0 && p.genericTrap < 6 {
+			trapOID = genericTrapOID + "." + strconv.FormatInt(p.genericTrap+1, 10)
+		} else {
+			trapOID = p.enterprise + ".0." + strconv.FormatInt(p.specificTrap, 10)
+		}
+		if len(p.agentAddress) == 4 {
+			tags["agent_address"] = net.IP(p.agentAddress).String()
+		}
+		_, name := s.lookup(sysUpTimeOID)
+		fields[name] = p.timestamp
+	}
+
+	for _, vb := range p.varbinds {
+		if vb.oid == snmpTrapOID {
+			if vb.tag == tagObjectID {
+				trapOID, _ = decodeOID(vb.value)
+			}
+			continue
+		}
+
+		value, err := s.convert(vb)
+		if err != nil {
+			s.acc.AddError(fmt.Errorf("SNMP trap varbind %s: %s", vb.oid, err))
+			continue
+		}
+		if value == nil {
+			continue
+		}
+
+		_, name := s.lookup(vb.oid)
+		fields[name] = value
+	}
+
+	if trapOID != "" {
+		tags["oid"] = trapOID
+		mibName, name := s.lookup(trapOID)
+		tags["name"] = name
+		if mibName != "" {
+			tags["mib"] = mibName
+		}
+	}
+
+	s.acc.AddFields("snmp_trap", fields, tags, time.Now())
+}
+
+// convert converts the value of a varbind, returning nil for varbinds
+// without a value.
+func (s *SnmpTrap) convert(vb varbind) (interface{}, error) {
+	switch vb.tag {
+	case tagInteger:
+		return decodeInt(vb.value)
+	case tagCounter32, tagGauge32, tagTimeTicks, tagCounter64:
+		return decodeUint(vb.value)
+	case tagOctetString, tagOpaque:
+		return string(vb.value), nil
+	case tagIPAddress:
+		if len(vb.value) != 4 {
+			return nil, fmt.Errorf("invalid length (%d) for IpAddress", len(vb.value))
+		}
+		return net.IP(vb.value).String(), nil
+	case tagObjectID:
+		oid, err := decodeOID(vb.value)
+		if err != nil {
+			return nil, err
+		}
+		mibName, name := s.lookup(oid)
+		if mibName == "" {
+			return oid, nil
+		}
+		return mibName + "::" + name, nil
+	case tagNull, tagNoSuchObject, tagNoSuchInstance, tagEndOfMibView:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported type 0x%02x", vb.tag)
+}
+
+// lookup returns the MIB and textual name of a numeric OID, resolved the
+// same way the snmp input does. The OID itself is used as the name when it
+// can't be translated, the error being only reported while it is cached.
+//
+// The loaded MIBs are searched in memory, snmptranslate is only run for the
+// OIDs they don't define. As the OIDs are up to the agents, its results are
+// cached up to translateCacheSize, an arbitrary OID being evicted past it.
+func (s *SnmpTrap) lookup(oid string) (string, string) {
+	if mibName, _, oidText, _, ok := snmpTranslateMIB(oid); ok {
+		return mibName, oidText
+	}
+	if t, ok := s.translateCache[oid]; ok {
+		return t.mibName, t.oidText
+	}
+
+	t := translation{oidText: oid}
+	mibName, _, oidText, _, err := snmpTranslate(oid)
+	if err != nil {
+		s.acc.AddError(fmt.Errorf("translating %s: %s", oid, err))
+	} else if mibName != "" {
+		t = translation{mibName: mibName, oidText: oidText}
+	}
+	if len(s.translateCache) >= translateCacheSize {
+		for k := range s.translateCache {
+			delete(s.translateCache, k)
+			break
+		}
+	}
+	s.translateCache[oid] = t
+	return t.mibName, t.oidText
+}
+
+func init() {
+	inputs.Add("snmp_trap", func() telegraf.Input {
+		return &SnmpTrap{
+			ServiceAddress: "udp://:162",
+		}
+	})
+}
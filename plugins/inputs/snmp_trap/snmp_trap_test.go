@@ -0,0 +1,372 @@
+package snmp_trap
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var mockedTranslations = map[string]string{
+	".1.3.6.1.6.3.1.1.5.1":   "SNMPv2-MIB::coldStart",
+	".1.3.6.1.6.3.1.1.5.3":   "IF-MIB::linkDown",
+	".1.3.6.1.2.1.1.3.0":     "DISMAN-EVENT-MIB::sysUpTimeInstance",
+	".1.3.6.1.2.1.2.2.1.1.2": "IF-MIB::ifIndex.2",
+	".1.3.6.1.2.1.2.2.1.2.2": "IF-MIB::ifDescr.2",
+}
+
+var errTranslation = errors.New("snmptranslate failed")
+
+func mockTranslate(oid string) (string, string, string, string, error) {
+	if oid == ".1.3.6.1.4.1.99999" {
+		return "", "", "", "", errTranslation
+	}
+	if text, ok := mockedTranslations[oid]; ok {
+		i := strings.Index(text, "::")
+		return text[:i], oid, text[i+2:], "", nil
+	}
+	// OIDs missing from the MIBs are returned numerically
+	return "", oid, oid, "", nil
+}
+
+func mockTranslateMIB(oid string) (string, string, string, string, bool) {
+	return "", "", "", "", false
+}
+
+func init() {
+	snmpTranslate = mockTranslate
+	snmpTranslateMIB = mockTranslateMIB
+}
+
+func newTestSnmpTrap(t *testing.T, s *SnmpTrap) (*testutil.Accumulator, net.Conn) {
+	s.ServiceAddress = "udp://127.0.0.1:0"
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+
+	client, err := net.Dial("udp", s.conn.LocalAddr().String())
+	require.NoError(t, err)
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return acc, client
+}
+
+func waitMetric(t *testing.T, acc *testutil.Accumulator) *testutil.Metric {
+	acc.Lock()
+	defer acc.Unlock()
+	for len(acc.Metrics) < 1 {
+		acc.Wait()
+	}
+	return acc.Metrics[0]
+}
+
+func mustOID(t *testing.T, oid string) []byte {
+	b, err := encodeOID(oid)
+	require.NoError(t, err)
+	return b
+}
+
+func TestSnmpTrap_v1(t *testing.T) {
+	s := &SnmpTrap{Community: "public"}
+	acc, client := newTestSnmpTrap(t, s)
+	defer s.Stop()
+	defer client.Close()
+
+	trap := encodeSequence(tagSequence,
+		encodeInt(tagInteger, 0),
+		encodeTLV(tagOctetString, []byte("public")),
+		encodeSequence(pduTrapV1,
+			mustOID(t, ".1.3.6.1.4.1.8072.3.2.10"),
+			encodeTLV(tagIPAddress, []byte{10, 0, 0, 1}),
+			encodeInt(tagInteger, 2),
+			encodeInt(tagInteger, 0),
+			encodeUint(tagTimeTicks, 1234),
+			encodeSequence(tagSequence,
+				encodeSequence(tagSequence,
+					mustOID(t, ".1.3.6.1.2.1.2.2.1.1.2"),
+					encodeInt(tagInteger, 2),
+				),
+			),
+		),
+	)
+	_, err := client.Write(trap)
+	require.NoError(t, err)
+
+	m := waitMetric(t, acc)
+	assert.Equal(t, "snmp_trap", m.Measurement)
+	assert.Equal(t, map[string]string{
+		"source":        "127.0.0.1",
+		"version":       "1",
+		"agent_address": "10.0.0.1",
+		"oid":           ".1.3.6.1.6.3.1.1.5.3",
+		"name":          "linkDown",
+		"mib":           "IF-MIB",
+	}, m.Tags)
+	assert.Equal(t, map[string]interface{}{
+		"sysUpTimeInstance": uint64(1234),
+		"ifIndex.2":         int64(2),
+	}, m.Fields)
+}
+
+func TestSnmpTrap_v2cCommunity(t *testing.T) {
+	s := &SnmpTrap{Community: "public"}
+	acc, client := newTestSnmpTrap(t, s)
+	defer s.Stop()
+	defer client.Close()
+
+	trap := func(community string) []byte {
+		return encodeSequence(tagSequence,
+			encodeInt(tagInteger, 1),
+			encodeTLV(tagOctetString, []byte(community)),
+			encodeSequence(pduTrapV2,
+				encodeInt(tagInteger, 1),
+				encodeInt(tagInteger, 0),
+				encodeInt(tagInteger, 0),
+				encodeSequence(tagSequence,
+					encodeSequence(tagSequence,
+						mustOID(t, sysUpTimeOID),
+						encodeUint(tagTimeTicks, 100),
+					),
+					encodeSequence(tagSequence,
+						mustOID(t, snmpTrapOID),
+						mustOID(t, ".1.3.6.1.6.3.1.1.5.1"),
+					),
+					encodeSequence(tagSequence,
+						mustOID(t, ".1.3.6.1.2.1.2.2.1.2.2"),
+						encodeTLV(tagOctetString, []byte("eth0")),
+					),
+				),
+			),
+		)
+	}
+
+	// traps with an other community are ignored
+	_, err := client.Write(trap("private"))
+	require.NoError(t, err)
+	_, err = client.Write(trap("public"))
+	require.NoError(t, err)
+
+	m := waitMetric(t, acc)
+	assert.Equal(t, map[string]string{
+		"source":  "127.0.0.1",
+		"version": "2c",
+		"oid":     ".1.3.6.1.6.3.1.1.5.1",
+		"name":    "coldStart",
+		"mib":     "SNMPv2-MIB",
+	}, m.Tags)
+	assert.Equal(t, map[string]interface{}{
+		"sysUpTimeInstance": uint64(100),
+		"ifDescr.2":         "eth0",
+	}, m.Fields)
+
+	acc.Lock()
+	assert.Len(t, acc.Errors, 1)
+	acc.Unlock()
+}
+
+func TestSnmpTrap_v2cInform(t *testing.T) {
+	s := &SnmpTrap{}
+	acc, client := newTestSnmpTrap(t, s)
+	defer s.Stop()
+	defer client.Close()
+
+	message := func(kind byte) []byte {
+		return encodeSequence(tagSequence,
+			encodeInt(tagInteger, 1),
+			encodeTLV(tagOctetString, []byte("public")),
+			encodeSequence(kind,
+				encodeInt(tagInteger, 1234),
+				encodeInt(tagInteger, 0),
+				encodeInt(tagInteger, 0),
+				encodeSequence(tagSequence,
+					encodeSequence(tagSequence,
+						mustOID(t, sysUpTimeOID),
+						encodeUint(tagTimeTicks, 100),
+					),
+					encodeSequence(tagSequence,
+						mustOID(t, snmpTrapOID),
+						mustOID(t, ".1.3.6.1.6.3.1.1.5.1"),
+					),
+				),
+			),
+		)
+	}
+	_, err := client.Write(message(pduInform))
+	require.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, message(pduResponse), buf[:n])
+
+	m := waitMetric(t, acc)
+	assert.Equal(t, "coldStart", m.Tags["name"])
+	assert.Equal(t, uint64(100), m.Fields["sysUpTimeInstance"])
+}
+
+func TestSnmpTrap_v3Inform(t *testing.T) {
+	s := &SnmpTrap{
+		SecName:      "user",
+		SecLevel:     "authPriv",
+		AuthProtocol: "MD5",
+		AuthPassword: "authpass",
+		PrivProtocol: "AES",
+		PrivPassword: "privpass",
+	}
+	acc, client := newTestSnmpTrap(t, s)
+	defer s.Stop()
+	defer client.Close()
+
+	buf := make([]byte, 65535)
+
+	// discover the engine ID of the receiver
+	discovery, err := newUSM("", "noAuthNoPriv", "", "", "", "", nil)
+	require.NoError(t, err)
+	discovery.engineID = nil
+	probe, err := discovery.encode(1, flagReportable, nil, nil, &pdu{kind: 0xa0, requestID: 1})
+	require.NoError(t, err)
+	_, err = client.Write(probe)
+	require.NoError(t, err)
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+	report, _, err := discovery.process(buf[:n], v3Reader(t, buf[:n]))
+	require.NoError(t, err)
+	assert.Equal(t, pduReport, report.pdu.kind)
+	assert.Equal(t, usmStatsUnknownEngineIDs, report.pdu.varbinds[0].oid)
+	assert.Equal(t, s.usm.engineID, report.engineID)
+
+	// send an authenticated and encrypted inform
+	sender, err := newUSM("user", "authPriv", "MD5", "authpass", "AES", "privpass", report.engineID)
+	require.NoError(t, err)
+	inform, err := sender.encode(2, flagAuth|flagPriv|flagReportable, []byte("user"), nil, &pdu{
+		kind:      pduInform,
+		requestID: 2,
+		varbinds: []varbind{
+			{oid: snmpTrapOID, tag: tagObjectID, value: mustOID(t, ".1.3.6.1.6.3.1.1.5.1")[2:]},
+			{oid: ".1.3.6.1.2.1.2.2.1.2.2", tag: tagOctetString, value: []byte("eth0")},
+		},
+	})
+	require.NoError(t, err)
+	_, err = client.Write(inform)
+	require.NoError(t, err)
+
+	n, err = client.Read(buf)
+	require.NoError(t, err)
+	response, _, err := sender.process(buf[:n], v3Reader(t, buf[:n]))
+	require.NoError(t, err)
+	assert.Equal(t, pduResponse, response.pdu.kind)
+	assert.EqualValues(t, 2, response.msgID)
+	assert.EqualValues(t, 2, response.pdu.requestID)
+	assert.Equal(t, flagAuth|flagPriv, response.flags)
+
+	m := waitMetric(t, acc)
+	assert.Equal(t, map[string]string{
+		"source":  "127.0.0.1",
+		"version": "3",
+		"oid":     ".1.3.6.1.6.3.1.1.5.1",
+		"name":    "coldStart",
+		"mib":     "SNMPv2-MIB",
+	}, m.Tags)
+	assert.Equal(t, map[string]interface{}{
+		"ifDescr.2": "eth0",
+	}, m.Fields)
+}
+
+func TestSnmpTrap_v3WrongPassword(t *testing.T) {
+	s := &SnmpTrap{
+		SecName:      "user",
+		SecLevel:     "authNoPriv",
+		AuthProtocol: "SHA",
+		AuthPassword: "authpass",
+	}
+	acc, client := newTestSnmpTrap(t, s)
+	defer s.Stop()
+	defer client.Close()
+
+	// traps are authenticated with the engine ID of their sender
+	for _, password := range []string{"wrongpass", "authpass"} {
+		sender, err := newUSM("user", "authNoPriv", "SHA", password, "", "", nil)
+		require.NoError(t, err)
+		trap, err := sender.encode(1, flagAuth, []byte("user"), nil, &pdu{
+			kind:      pduTrapV2,
+			requestID: 1,
+			varbinds: []varbind{
+				{oid: sysUpTimeOID, tag: tagTimeTicks, value: []byte{100}},
+			},
+		})
+		require.NoError(t, err)
+		_, err = client.Write(trap)
+		require.NoError(t, err)
+	}
+
+	waitMetric(t, acc)
+	acc.Lock()
+	assert.Len(t, acc.Metrics, 1)
+	require.Len(t, acc.Errors, 1)
+	assert.Contains(t, acc.Errors[0].Error(), "authentication failure")
+	acc.Unlock()
+}
+
+func TestSnmpTrap_lookupError(t *testing.T) {
+	acc := &testutil.Accumulator{}
+	s := &SnmpTrap{acc: acc, translateCache: map[string]translation{}}
+
+	for i := 0; i < 2; i++ {
+		mibName, name := s.lookup(".1.3.6.1.4.1.99999")
+		assert.Equal(t, "", mibName)
+		assert.Equal(t, ".1.3.6.1.4.1.99999", name)
+	}
+	// the error is only reported once
+	assert.Len(t, acc.Errors, 1)
+
+	mibName, name := s.lookup(".1.3.6.1.2.1.2.2.1.2.2")
+	assert.Equal(t, "IF-MIB", mibName)
+	assert.Equal(t, "ifDescr.2", name)
+}
+
+func TestSnmpTrap_lookupCache(t *testing.T) {
+	defer func() {
+		snmpTranslate = mockTranslate
+		snmpTranslateMIB = mockTranslateMIB
+	}()
+	calls := 0
+	snmpTranslate = func(oid string) (string, string, string, string, error) {
+		calls++
+		return mockTranslate(oid)
+	}
+	snmpTranslateMIB = func(oid string) (string, string, string, string, bool) {
+		if oid == ".1.3.6.1.6.3.1.1.5.1" {
+			return "SNMPv2-MIB", oid, "coldStart", "", true
+		}
+		return "", "", "", "", false
+	}
+
+	acc := &testutil.Accumulator{}
+	s := &SnmpTrap{acc: acc, translateCache: map[string]translation{}}
+
+	// OIDs defined by the loaded MIBs don't run snmptranslate
+	mibName, name := s.lookup(".1.3.6.1.6.3.1.1.5.1")
+	assert.Equal(t, "SNMPv2-MIB", mibName)
+	assert.Equal(t, "coldStart", name)
+	assert.Equal(t, 0, calls)
+	assert.Empty(t, s.translateCache)
+
+	// the others run it once while cached
+	for i := 0; i < 2; i++ {
+		mibName, name = s.lookup(".1.3.6.1.6.3.1.1.5.3")
+		assert.Equal(t, "IF-MIB", mibName)
+		assert.Equal(t, "linkDown", name)
+	}
+	assert.Equal(t, 1, calls)
+
+	// and the cache is bounded
+	for i := 0; i < 2*translateCacheSize; i++ {
+		s.lookup(fmt.Sprintf(".1.3.6.1.4.1.8072.%d", i))
+	}
+	assert.Len(t, s.translateCache, translateCacheSize)
+	assert.Empty(t, acc.Errors)
+}
@@ -0,0 +1,581 @@
+package snmp_trap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SNMPv3 message flags, see RFC3412.
+const (
+	flagAuth       byte = 0x01
+	flagPriv       byte = 0x02
+	flagReportable byte = 0x04
+)
+
+const (
+	securityModelUSM = 3
+	maxMessageSize   = 65507
+	authParamsLength = 12
+)
+
+// usmStatsUnknownEngineIDs is reported to senders of informs that don't
+// know our engine ID yet, usmStatsNotInTimeWindows to the ones whose engine
+// boots and time are off, see RFC3414 section 4.
+const (
+	usmStatsUnknownEngineIDs = ".1.3.6.1.6.3.15.1.1.4.0"
+	usmStatsNotInTimeWindows = ".1.3.6.1.6.3.15.1.1.2.0"
+)
+
+// timeWindow is how many seconds the engine time of an authenticated message
+// may differ from the one of its authoritative engine, maxEngineBoots the
+// boots after which an engine has to be reconfigured, see RFC3414 section
+// 2.2.3.
+const (
+	timeWindow     = 150
+	maxEngineBoots = 2147483647
+)
+
+var (
+	errNotInitialized  = errors.New("no engine ID")
+	errNotInTimeWindow = errors.New("not in time window")
+)
+
+// usm implements the User-based Security Model of RFC3414 for a single
+// user.
+type usm struct {
+	secName      string
+	secLevel     byte
+	authHash     func() hash.Hash
+	privProtocol string
+
+	// master keys derived from the passwords, localized to the
+	// authoritative engine ID of each message.
+	authKey []byte
+	privKey []byte
+
+	// engineID is our own engine ID, authoritative for the informs we
+	// receive.
+	engineID []byte
+	boots    int64
+	start    time.Time
+	now      func() time.Time
+
+	sync.Mutex
+	salt            uint64
+	unknownEngine   int64
+	notInTimeWindow int64
+	// engines holds the boots and time of the other authoritative engines,
+	// the ones of the senders of traps.
+	engines map[string]*remoteEngine
+}
+
+// remoteEngine is the boots and time last received from an engine, at the
+// local time they were received.
+type remoteEngine struct {
+	boots int64
+	time  int64
+	at    time.Time
+}
+
+// newUSM validates the USM parameters and derives the master keys from the
+// passwords.
+func newUSM(secName, secLevel, authProtocol, authPassword, privProtocol, privPassword string, engineID []byte) (*usm, error) {
+	u := &usm{
+		secName:  secName,
+		engineID: engineID,
+		boots:    1,
+		start:    time.Now(),
+		now:      time.Now,
+		engines:  map[string]*remoteEngine{},
+	}
+
+	switch strings.ToLower(secLevel) {
+	case "noauthnopriv", "":
+		u.secLevel = 0
+	case "authnopriv":
+		u.secLevel = flagAuth
+	case "authpriv":
+		u.secLevel = flagAuth | flagPriv
+	default:
+		return nil, fmt.Errorf("invalid secLevel")
+	}
+
+	switch strings.ToLower(authProtocol) {
+	case "md5":
+		u.authHash = md5.New
+	case "sha":
+		u.authHash = sha1.New
+	case "":
+	default:
+		return nil, fmt.Errorf("invalid authProtocol")
+	}
+
+	switch strings.ToLower(privProtocol) {
+	case "des", "aes":
+		u.privProtocol = strings.ToLower(privProtocol)
+	case "":
+	default:
+		return nil, fmt.Errorf("invalid privProtocol")
+	}
+
+	if u.secLevel&flagAuth != 0 && u.authHash == nil {
+		return nil, fmt.Errorf("authProtocol is required with secLevel %s", secLevel)
+	}
+	if u.secLevel&flagPriv != 0 && u.privProtocol == "" {
+		return nil, fmt.Errorf("privProtocol is required with secLevel %s", secLevel)
+	}
+
+	if u.authHash != nil {
+		u.authKey = passwordToKey(authPassword, u.authHash)
+		if u.privProtocol != "" {
+			u.privKey = passwordToKey(privPassword, u.authHash)
+		}
+	}
+
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	u.salt = binary.BigEndian.Uint64(b[:])
+
+	if len(u.engineID) == 0 {
+		// random engine ID, in the net-snmp enterprise format
+		u.engineID = append([]byte{0x80, 0x00, 0x1f, 0x88, 0x80}, b[:]...)
+	}
+
+	return u, nil
+}
+
+// passwordToKey derives a key from a password, see RFC3414 appendix A.2.
+func passwordToKey(password string, h func() hash.Hash) []byte {
+	hasher := h()
+	if len(password) == 0 {
+		return hasher.Sum(nil)
+	}
+
+	pw := []byte(password)
+	buf := make([]byte, 64)
+	idx := 0
+	for count := 0; count < 1048576; count += 64 {
+		for i := range buf {
+			buf[i] = pw[idx%len(pw)]
+			idx++
+		}
+		hasher.Write(buf)
+	}
+	return hasher.Sum(nil)
+}
+
+// localizeKey localizes a key to an engine ID, see RFC3414 section 2.6.
+func localizeKey(key, engineID []byte, h func() hash.Hash) []byte {
+	hasher := h()
+	hasher.Write(key)
+	hasher.Write(engineID)
+	hasher.Write(key)
+	return hasher.Sum(nil)
+}
+
+func (u *usm) engineTime() int64 {
+	return int64(u.now().Sub(u.start) / time.Second)
+}
+
+// checkTime rejects the authenticated messages outside of the time window of
+// their authoritative engine, so they can't be replayed, see RFC3414 section
+// 3.2 step 7.
+func (u *usm) checkTime(m *v3Message) error {
+	if string(m.engineID) == string(u.engineID) {
+		t := u.engineTime()
+		if u.boots == maxEngineBoots || m.boots != u.boots ||
+			m.time < t-timeWindow || m.time > t+timeWindow {
+			return errNotInTimeWindow
+		}
+		return nil
+	}
+
+	u.Lock()
+	defer u.Unlock()
+	now := u.now()
+	e, ok := u.engines[string(m.engineID)]
+	if !ok {
+		// the first message of an engine is trusted, as it is authenticated
+		u.engines[string(m.engineID)] = &remoteEngine{boots: m.boots, time: m.time, at: now}
+		return nil
+	}
+
+	t := e.time + int64(now.Sub(e.at)/time.Second)
+	if e.boots == maxEngineBoots || m.boots < e.boots ||
+		(m.boots == e.boots && m.time < t-timeWindow) {
+		return errNotInTimeWindow
+	}
+	if m.boots > e.boots || m.time > e.time {
+		*e = remoteEngine{boots: m.boots, time: m.time, at: now}
+	}
+	return nil
+}
+
+// v3Message is a decoded SNMPv3 message.
+type v3Message struct {
+	msgID int64
+	flags byte
+
+	engineID   []byte
+	boots      int64
+	time       int64
+	userName   []byte
+	authParams []byte
+	privParams []byte
+
+	contextEngineID []byte
+	contextName     []byte
+	pdu             *pdu
+}
+
+// process decodes, authenticates and decrypts an SNMPv3 message. whole is
+// the complete message, r reads what follows the version.
+// When the sender has to learn our engine ID first, a report to send back
+// is returned instead of a message.
+func (u *usm) process(whole []byte, r *berReader) (*v3Message, []byte, error) {
+	m := &v3Message{}
+
+	global, err := r.expect(tagSequence)
+	if err != nil {
+		return nil, nil, err
+	}
+	gr := &berReader{buf: global}
+	if m.msgID, err = gr.readInt(); err != nil {
+		return nil, nil, err
+	}
+	if _, err = gr.readInt(); err != nil {
+		return nil, nil, err
+	}
+	flags, err := gr.readOctetString()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(flags) != 1 {
+		return nil, nil, fmt.Errorf("invalid msgFlags")
+	}
+	m.flags = flags[0]
+	model, err := gr.readInt()
+	if err != nil {
+		return nil, nil, err
+	}
+	if model != securityModelUSM {
+		return nil, nil, fmt.Errorf("unsupported security model %d", model)
+	}
+
+	params, err := r.readOctetString()
+	if err != nil {
+		return nil, nil, err
+	}
+	seq, err := (&berReader{buf: params}).expect(tagSequence)
+	if err != nil {
+		return nil, nil, err
+	}
+	pr := &berReader{buf: seq}
+	if m.engineID, err = pr.readOctetString(); err != nil {
+		return nil, nil, err
+	}
+	if m.boots, err = pr.readInt(); err != nil {
+		return nil, nil, err
+	}
+	if m.time, err = pr.readInt(); err != nil {
+		return nil, nil, err
+	}
+	if m.userName, err = pr.readOctetString(); err != nil {
+		return nil, nil, err
+	}
+	if m.authParams, err = pr.readOctetString(); err != nil {
+		return nil, nil, err
+	}
+	if m.privParams, err = pr.readOctetString(); err != nil {
+		return nil, nil, err
+	}
+
+	dataTag, data, err := r.next()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(m.engineID) == 0 {
+		// discovery of our engine ID, the request itself is unauthenticated
+		if m.flags&flagReportable == 0 {
+			return nil, nil, errNotInitialized
+		}
+		var requestID int64
+		if dataTag == tagSequence {
+			if err := m.decodeScopedPDU(data); err == nil {
+				requestID = m.pdu.requestID
+			}
+		}
+		report, err := u.report(m, requestID, usmStatsUnknownEngineIDs, &u.unknownEngine, 0)
+		return nil, report, err
+	}
+
+	if string(m.userName) != u.secName {
+		return nil, nil, fmt.Errorf("unknown user %q", m.userName)
+	}
+	if m.flags&(flagAuth|flagPriv) < u.secLevel {
+		return nil, nil, fmt.Errorf("unsupported security level")
+	}
+
+	if m.flags&flagAuth != 0 {
+		if u.authHash == nil {
+			return nil, nil, fmt.Errorf("authentication is not configured")
+		}
+		if len(m.authParams) != authParamsLength {
+			return nil, nil, fmt.Errorf("invalid authentication parameters")
+		}
+		// m.authParams is a slice of whole, find where it starts
+		offset := cap(whole) - cap(m.authParams)
+		zeroed := make([]byte, len(whole))
+		copy(zeroed, whole)
+		copy(zeroed[offset:offset+authParamsLength], make([]byte, authParamsLength))
+		if !hmac.Equal(m.authParams, u.sign(zeroed, m.engineID)) {
+			return nil, nil, fmt.Errorf("authentication failure")
+		}
+	}
+
+	if m.flags&flagPriv != 0 {
+		if m.flags&flagAuth == 0 || u.privProtocol == "" {
+			return nil, nil, fmt.Errorf("privacy is not configured")
+		}
+		if dataTag != tagOctetString {
+			return nil, nil, fmt.Errorf("encrypted PDU expected")
+		}
+		if data, err = u.decrypt(data, m); err != nil {
+			return nil, nil, err
+		}
+		r := &berReader{buf: data}
+		if data, err = r.expect(tagSequence); err != nil {
+			return nil, nil, err
+		}
+	} else if dataTag != tagSequence {
+		return nil, nil, fmt.Errorf("unexpected BER tag 0x%02x for scoped PDU", dataTag)
+	}
+
+	if err := m.decodeScopedPDU(data); err != nil {
+		return nil, nil, err
+	}
+
+	if m.pdu.kind == pduInform && string(m.engineID) != string(u.engineID) {
+		// informs are sent to us as the authoritative engine
+		if m.flags&flagReportable == 0 {
+			return nil, nil, fmt.Errorf("unknown engine ID %x", m.engineID)
+		}
+		report, err := u.report(m, m.pdu.requestID, usmStatsUnknownEngineIDs, &u.unknownEngine, 0)
+		return nil, report, err
+	}
+
+	// the time is checked once the PDU is decrypted, so reports carry its
+	// request ID
+	if m.flags&flagAuth != 0 {
+		if err := u.checkTime(m); err != nil {
+			if m.flags&flagReportable == 0 || string(m.engineID) != string(u.engineID) {
+				return nil, nil, err
+			}
+			// the report is authenticated, for the sender to trust our
+			// boots and time
+			report, err := u.report(m, m.pdu.requestID, usmStatsNotInTimeWindows, &u.notInTimeWindow, flagAuth)
+			return nil, report, err
+		}
+	}
+
+	return m, nil, nil
+}
+
+func (m *v3Message) decodeScopedPDU(data []byte) error {
+	r := &berReader{buf: data}
+	var err error
+	if m.contextEngineID, err = r.readOctetString(); err != nil {
+		return err
+	}
+	if m.contextName, err = r.readOctetString(); err != nil {
+		return err
+	}
+	tag, value, err := r.next()
+	if err != nil {
+		return err
+	}
+	m.pdu, err = decodePDU(tag, value)
+	return err
+}
+
+// report builds the report of our engine ID, boots and time, as an answer
+// to a request using an unknown engine ID or out of the time window. counter
+// is the statistic of oid, incremented with each report.
+func (u *usm) report(m *v3Message, requestID int64, oid string, counter *int64, flags byte) ([]byte, error) {
+	u.Lock()
+	*counter++
+	count := *counter
+	u.Unlock()
+
+	p := &pdu{
+		kind:      pduReport,
+		requestID: requestID,
+		varbinds: []varbind{{
+			oid:   oid,
+			tag:   tagCounter32,
+			value: encodeUint(tagCounter32, uint64(count))[2:],
+		}},
+	}
+	return u.encode(m.msgID, flags, m.userName, m.contextName, p)
+}
+
+// sign computes the HMAC-MD5-96 or HMAC-SHA-96 of a message.
+func (u *usm) sign(msg, engineID []byte) []byte {
+	mac := hmac.New(u.authHash, localizeKey(u.authKey, engineID, u.authHash))
+	mac.Write(msg)
+	return mac.Sum(nil)[:authParamsLength]
+}
+
+func (u *usm) decrypt(data []byte, m *v3Message) ([]byte, error) {
+	key := localizeKey(u.privKey, m.engineID, u.authHash)
+	if len(m.privParams) != 8 {
+		return nil, fmt.Errorf("invalid privacy parameters")
+	}
+
+	plain := make([]byte, len(data))
+	switch u.privProtocol {
+	case "des":
+		if len(data)%des.BlockSize != 0 {
+			return nil, fmt.Errorf("invalid DES encrypted PDU length %d", len(data))
+		}
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, err
+		}
+		iv := make([]byte, 8)
+		for i := range iv {
+			iv[i] = key[8+i] ^ m.privParams[i]
+		}
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+	case "aes":
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, err
+		}
+		cipher.NewCFBDecrypter(block, aesIV(m.boots, m.time, m.privParams)).XORKeyStream(plain, data)
+	}
+	return plain, nil
+}
+
+func (u *usm) encrypt(data []byte, boots, engineTime int64) ([]byte, []byte, error) {
+	key := localizeKey(u.privKey, u.engineID, u.authHash)
+
+	u.Lock()
+	u.salt++
+	salt := u.salt
+	u.Unlock()
+
+	privParams := make([]byte, 8)
+	switch u.privProtocol {
+	case "des":
+		binary.BigEndian.PutUint32(privParams, uint32(boots))
+		binary.BigEndian.PutUint32(privParams[4:], uint32(salt))
+		if n := len(data) % des.BlockSize; n != 0 {
+			data = append(data, make([]byte, des.BlockSize-n)...)
+		}
+		block, err := des.NewCipher(key[:8])
+		if err != nil {
+			return nil, nil, err
+		}
+		iv := make([]byte, 8)
+		for i := range iv {
+			iv[i] = key[8+i] ^ privParams[i]
+		}
+		encrypted := make([]byte, len(data))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, data)
+		return encrypted, privParams, nil
+	case "aes":
+		binary.BigEndian.PutUint64(privParams, salt)
+		block, err := aes.NewCipher(key[:16])
+		if err != nil {
+			return nil, nil, err
+		}
+		encrypted := make([]byte, len(data))
+		cipher.NewCFBEncrypter(block, aesIV(boots, engineTime, privParams)).XORKeyStream(encrypted, data)
+		return encrypted, privParams, nil
+	}
+	return nil, nil, fmt.Errorf("invalid privProtocol")
+}
+
+// aesIV builds the initialization vector of AES-CFB128, see RFC3826.
+func aesIV(boots, engineTime int64, salt []byte) []byte {
+	iv := make([]byte, 16)
+	binary.BigEndian.PutUint32(iv, uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(engineTime))
+	copy(iv[8:], salt)
+	return iv
+}
+
+// encode builds an SNMPv3 message from our engine, authenticated and
+// encrypted according to flags.
+func (u *usm) encode(msgID int64, flags byte, userName, contextName []byte, p *pdu) ([]byte, error) {
+	boots, engineTime := u.boots, u.engineTime()
+
+	encoded, err := p.encode()
+	if err != nil {
+		return nil, err
+	}
+	data := encodeSequence(tagSequence,
+		encodeTLV(tagOctetString, u.engineID),
+		encodeTLV(tagOctetString, contextName),
+		encoded,
+	)
+
+	var privParams []byte
+	if flags&flagPriv != 0 {
+		var encrypted []byte
+		if encrypted, privParams, err = u.encrypt(data, boots, engineTime); err != nil {
+			return nil, err
+		}
+		data = encodeTLV(tagOctetString, encrypted)
+	}
+
+	var authParams []byte
+	if flags&flagAuth != 0 {
+		authParams = make([]byte, authParamsLength)
+	}
+	privTLV := encodeTLV(tagOctetString, privParams)
+	params := encodeSequence(tagSequence,
+		encodeTLV(tagOctetString, u.engineID),
+		encodeInt(tagInteger, boots),
+		encodeInt(tagInteger, engineTime),
+		encodeTLV(tagOctetString, userName),
+		encodeTLV(tagOctetString, authParams),
+		privTLV,
+	)
+
+	msg := encodeSequence(tagSequence,
+		encodeInt(tagInteger, 3),
+		encodeSequence(tagSequence,
+			encodeInt(tagInteger, msgID),
+			encodeInt(tagInteger, maxMessageSize),
+			encodeTLV(tagOctetString, []byte{flags}),
+			encodeInt(tagInteger, securityModelUSM),
+		),
+		encodeTLV(tagOctetString, params),
+		data,
+	)
+
+	if flags&flagAuth != 0 {
+		// the authentication parameters come right before the privacy
+		// parameters, which end the security parameters
+		offset := len(msg) - len(data) - len(privTLV) - authParamsLength
+		copy(msg[offset:], u.sign(msg, u.engineID))
+	}
+	return msg, nil
+}
@@ -0,0 +1,151 @@
+package snmp_trap
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors of RFC3414 appendix A.3
+func TestLocalizeKey(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+
+	key := passwordToKey("maplesyrup", md5.New)
+	assert.Equal(t, "9faf3283884e92834ebc9847d8edd963", hex.EncodeToString(key))
+	assert.Equal(t, "526f5eed9fcce26f8964c2930787d82b",
+		hex.EncodeToString(localizeKey(key, engineID, md5.New)))
+
+	key = passwordToKey("maplesyrup", sha1.New)
+	assert.Equal(t, "9fb5cc0381497b3793528939ff788d5d79145211", hex.EncodeToString(key))
+	assert.Equal(t, "6695febc9288e36282235fc7151f128497b38f3f",
+		hex.EncodeToString(localizeKey(key, engineID, sha1.New)))
+}
+
+func TestUSMRoundTrip(t *testing.T) {
+	for _, priv := range []string{"DES", "AES"} {
+		u, err := newUSM("user", "authPriv", "SHA", "authpass", priv, "privpass", nil)
+		require.NoError(t, err)
+
+		p := &pdu{
+			kind:      pduInform,
+			requestID: 42,
+			varbinds: []varbind{
+				{oid: ".1.3.6.1.2.1.1.5.0", tag: tagOctetString, value: []byte("host")},
+			},
+		}
+		msg, err := u.encode(7, flagAuth|flagPriv|flagReportable, []byte("user"), nil, p)
+		require.NoError(t, err)
+
+		m, report, err := u.process(msg, v3Reader(t, msg))
+		require.NoError(t, err, priv)
+		assert.Nil(t, report)
+		assert.EqualValues(t, 7, m.msgID)
+		assert.EqualValues(t, 42, m.pdu.requestID)
+		assert.Equal(t, p.varbinds, m.pdu.varbinds)
+
+		// tampering with the message fails authentication
+		msg[len(msg)-1] ^= 0xff
+		_, _, err = u.process(msg, v3Reader(t, msg))
+		assert.Error(t, err, priv)
+	}
+}
+
+func TestUSMTimeWindowAuthoritative(t *testing.T) {
+	u, err := newUSM("user", "authNoPriv", "MD5", "authpass", "", "", nil)
+	require.NoError(t, err)
+	now := u.start
+	u.now = func() time.Time { return now }
+
+	p := &pdu{kind: pduInform, requestID: 42}
+	msg, err := u.encode(7, flagAuth|flagReportable, []byte("user"), nil, p)
+	require.NoError(t, err)
+	_, _, err = u.process(msg, v3Reader(t, msg))
+	require.NoError(t, err)
+
+	// replaying the inform out of the time window is reported
+	now = now.Add(151 * time.Second)
+	m, report, err := u.process(msg, v3Reader(t, msg))
+	require.NoError(t, err)
+	assert.Nil(t, m)
+	require.NotNil(t, report)
+
+	m, _, err = u.process(report, v3Reader(t, report))
+	require.NoError(t, err)
+	assert.Equal(t, pduReport, m.pdu.kind)
+	assert.EqualValues(t, 42, m.pdu.requestID)
+	assert.Equal(t, usmStatsNotInTimeWindows, m.pdu.varbinds[0].oid)
+	assert.EqualValues(t, 151, m.time)
+}
+
+func TestUSMTimeWindowNonAuthoritative(t *testing.T) {
+	u, err := newUSM("user", "authNoPriv", "SHA", "authpass", "", "", nil)
+	require.NoError(t, err)
+	now := u.start
+	u.now = func() time.Time { return now }
+
+	engineID, _ := hex.DecodeString("80001f888001020304")
+	sender, err := newUSM("user", "authNoPriv", "SHA", "authpass", "", "", engineID)
+	require.NoError(t, err)
+	sender.start = now.Add(-1000 * time.Second)
+	sender.now = func() time.Time { return now }
+
+	trap := func() []byte {
+		msg, err := sender.encode(7, flagAuth, []byte("user"), nil, &pdu{kind: pduTrapV2})
+		require.NoError(t, err)
+		return msg
+	}
+
+	old := trap()
+	_, _, err = u.process(old, v3Reader(t, old))
+	require.NoError(t, err)
+
+	now = now.Add(100 * time.Second)
+	msg := trap()
+	_, _, err = u.process(msg, v3Reader(t, msg))
+	require.NoError(t, err)
+	_, _, err = u.process(old, v3Reader(t, old))
+	require.NoError(t, err)
+
+	// traps older than the time window are replays
+	now = now.Add(100 * time.Second)
+	_, report, err := u.process(old, v3Reader(t, old))
+	assert.Equal(t, errNotInTimeWindow, err)
+	assert.Nil(t, report)
+
+	// so are the ones of previous boots
+	sender.boots = 2
+	msg = trap()
+	_, _, err = u.process(msg, v3Reader(t, msg))
+	require.NoError(t, err)
+	sender.boots = 1
+	msg = trap()
+	_, _, err = u.process(msg, v3Reader(t, msg))
+	assert.Equal(t, errNotInTimeWindow, err)
+}
+
+// v3Reader returns a reader of msg, positioned after the version.
+func v3Reader(t *testing.T, msg []byte) *berReader {
+	body, err := (&berReader{buf: msg}).expect(tagSequence)
+	require.NoError(t, err)
+	r := &berReader{buf: body}
+	version, err := r.readInt()
+	require.NoError(t, err)
+	require.EqualValues(t, 3, version)
+	return r
+}
+
+func TestNewUSMInvalid(t *testing.T) {
+	_, err := newUSM("user", "bogus", "", "", "", "", nil)
+	assert.Error(t, err)
+	_, err = newUSM("user", "authNoPriv", "", "", "", "", nil)
+	assert.Error(t, err)
+	_, err = newUSM("user", "authPriv", "MD5", "authpass", "", "", nil)
+	assert.Error(t, err)
+	_, err = newUSM("user", "authNoPriv", "SHA256", "authpass", "", "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package statsd
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Measurements of the DogStatsD events and service checks, see
+// http://docs.datadoghq.com/guides/dogstatsd/#datagram-format
+const (
+	eventMeasurement        = "statsd_events"
+	serviceCheckMeasurement = "statsd_service_checks"
+)
+
+// cachedevent is a DogStatsD event or service check, waiting to be reported
+// on the next call to Gather.
+type cachedevent struct {
+	name      string
+	fields    map[string]interface{}
+	tags      map[string]string
+	timestamp time.Time
+}
+
+// parseEventLine parses a DogStatsD event:
+//   _e{<title length>,<text length>}:<title>|<text>|d:<timestamp>|h:<hostname>
+//   |p:<priority>|t:<alert type>|k:<aggregation key>|s:<source type name>
+//   |#<tag1>:<value1>,<tag2>
+// Only the title and text are required.
+// Assumes s's lock is held!
+func (s *Statsd) parseEventLine(line string) error {
+	header := strings.Index(line, "}:")
+	if header < 0 {
+		log.Printf("E! Error: Unable to parse event, missing '}:': %s\n", line)
+		return errors.New("Error Parsing statsd event")
+	}
+	lengths := strings.Split(line[len("_e{"):header], ",")
+	if len(lengths) != 2 {
+		log.Printf("E! Error: Unable to parse event lengths: %s\n", line)
+		return errors.New("Error Parsing statsd event")
+	}
+	titleLen, err := strconv.Atoi(lengths[0])
+	if err != nil || titleLen <= 0 {
+		log.Printf("E! Error: Unable to parse event title length: %s\n", line)
+		return errors.New("Error Parsing statsd event")
+	}
+	textLen, err := strconv.Atoi(lengths[1])
+	if err != nil || textLen < 0 {
+		log.Printf("E! Error: Unable to parse event text length: %s\n", line)
+		return errors.New("Error Parsing statsd event")
+	}
+
+	rest := line[header+2:]
+	if len(rest) < titleLen+1+textLen || rest[titleLen] != '|' {
+		log.Printf("E! Error: Event title and text don't match their lengths: %s\n", line)
+		return errors.New("Error Parsing statsd event")
+	}
+	title := rest[:titleLen]
+	text := rest[titleLen+1 : titleLen+1+textLen]
+	rest = rest[titleLen+1+textLen:]
+
+	event := cachedevent{
+		name: eventMeasurement,
+		fields: map[string]interface{}{
+			"title": title,
+			"text":  strings.Replace(text, "\\n", "\n", -1),
+		},
+		tags: map[string]string{
+			"priority":   "normal",
+			"alert_type": "info",
+		},
+	}
+
+	for _, segment := range strings.Split(rest, "|") {
+		if len(segment) == 0 {
+			continue
+		}
+		if segment[0] == '#' {
+			parseDataDogTags(segment[1:], event.tags)
+			continue
+		}
+		if len(segment) < 3 || segment[1] != ':' {
+			log.Printf("E! Error: Unable to parse event metadata %q: %s\n",
+				segment, line)
+			return errors.New("Error Parsing statsd event")
+		}
+
+		value := segment[2:]
+		switch segment[0] {
+		case 'd':
+			timestamp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				log.Printf("E! Error: Unable to parse event timestamp: %s\n", line)
+				return errors.New("Error Parsing statsd event")
+			}
+			event.timestamp = time.Unix(timestamp, 0)
+		case 'h':
+			event.tags["source"] = value
+		case 'p':
+			event.tags["priority"] = value
+		case 't':
+			event.tags["alert_type"] = value
+		case 'k':
+			event.tags["aggregation_key"] = value
+		case 's':
+			event.tags["source_type_name"] = value
+		default:
+			log.Printf("E! Error: Unknown event metadata %q: %s\n", segment, line)
+			return errors.New("Error Parsing statsd event")
+		}
+	}
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+// parseServiceCheckLine parses a DogStatsD service check:
+//   _sc|<name>|<status>|d:<timestamp>|h:<hostname>|#<tag1>:<value1>,<tag2>
+//   |m:<message>
+// The status is one of 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN), the
+// message always comes last.
+// Assumes s's lock is held!
+func (s *Statsd) parseServiceCheckLine(line string) error {
+	var message string
+	hasMessage := false
+	if i := strings.Index(line, "|m:"); i >= 0 {
+		message = strings.Replace(line[i+3:], "m\\:", "m:", -1)
+		hasMessage = true
+		line = line[:i]
+	}
+
+	segments := strings.Split(line, "|")
+	if len(segments) < 3 || segments[1] == "" {
+		log.Printf("E! Error: Unable to parse service check: %s\n", line)
+		return errors.New("Error Parsing statsd service check")
+	}
+
+	status, err := strconv.ParseInt(segments[2], 10, 64)
+	if err != nil || status < 0 || status > 3 {
+		log.Printf("E! Error: Invalid service check status %q: %s\n",
+			segments[2], line)
+		return errors.New("Error Parsing statsd service check")
+	}
+
+	check := cachedevent{
+		name: serviceCheckMeasurement,
+		fields: map[string]interface{}{
+			"status": status,
+		},
+		tags: map[string]string{
+			"check": segments[1],
+		},
+	}
+	if hasMessage {
+		check.fields["message"] = message
+	}
+
+	for _, segment := range segments[3:] {
+		if len(segment) == 0 {
+			continue
+		}
+		if segment[0] == '#' {
+			parseDataDogTags(segment[1:], check.tags)
+			continue
+		}
+		if len(segment) < 3 || segment[1] != ':' {
+			log.Printf("E! Error: Unable to parse service check metadata %q: %s\n",
+				segment, line)
+			return errors.New("Error Parsing statsd service check")
+		}
+
+		value := segment[2:]
+		switch segment[0] {
+		case 'd':
+			timestamp, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				log.Printf("E! Error: Unable to parse service check timestamp: %s\n", line)
+				return errors.New("Error Parsing statsd service check")
+			}
+			check.timestamp = time.Unix(timestamp, 0)
+		case 'h':
+			check.tags["source"] = value
+		default:
+			log.Printf("E! Error: Unknown service check metadata %q: %s\n",
+				segment, line)
+			return errors.New("Error Parsing statsd service check")
+		}
+	}
+
+	s.events = append(s.events, check)
+	return nil
+}
+
+// parseDataDogTags parses comma separated datadog tags, either "key:value"
+// or "key" alone, into tags.
+func parseDataDogTags(tagstr string, tags map[string]string) {
+	for _, tag := range strings.Split(tagstr, ",") {
+		ts := strings.SplitN(tag, ":", 2)
+		var k, v string
+		switch len(ts) {
+		case 1:
+			// just a tag
+			k = ts[0]
+			v = ""
+		case 2:
+			k = ts[0]
+			v = ts[1]
+		}
+		if k != "" {
+			tags[k] = v
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package statsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestParse_DataDogEvent(t *testing.T) {
+	s := NewTestStatsd()
+
+	err := s.parseStatsdLine("_e{14,15}:deploy started|version 1.2\\nok|d:1493805600|h:web01|p:low|t:warning|k:deploys|s:jenkins|#env:prod,canary")
+	if err != nil {
+		t.Fatalf("Parsing event should not have failed: %s", err)
+	}
+
+	acc := &testutil.Accumulator{}
+	s.Gather(acc)
+
+	acc.AssertContainsTaggedFields(t, "statsd_events",
+		map[string]interface{}{
+			"title": "deploy started",
+			"text":  "version 1.2\nok",
+		},
+		map[string]string{
+			"source":           "web01",
+			"priority":         "low",
+			"alert_type":       "warning",
+			"aggregation_key":  "deploys",
+			"source_type_name": "jenkins",
+			"env":              "prod",
+			"canary":           "",
+		})
+	m, _ := acc.Get("statsd_events")
+	if !m.Time.Equal(time.Unix(1493805600, 0)) {
+		t.Errorf("Expected the event timestamp, got %s", m.Time)
+	}
+
+	// events are reported only once
+	acc = &testutil.Accumulator{}
+	s.Gather(acc)
+	if acc.HasMeasurement("statsd_events") {
+		t.Error("Events should be reported only once")
+	}
+}
+
+func TestParse_DataDogEventDefaults(t *testing.T) {
+	s := NewTestStatsd()
+
+	if err := s.parseStatsdLine("_e{5,0}:title|"); err != nil {
+		t.Fatalf("Parsing event should not have failed: %s", err)
+	}
+
+	acc := &testutil.Accumulator{}
+	s.Gather(acc)
+
+	acc.AssertContainsTaggedFields(t, "statsd_events",
+		map[string]interface{}{
+			"title": "title",
+			"text":  "",
+		},
+		map[string]string{
+			"priority":   "normal",
+			"alert_type": "info",
+		})
+}
+
+func TestParse_DataDogServiceCheck(t *testing.T) {
+	s := NewTestStatsd()
+
+	err := s.parseStatsdLine("_sc|db.connection|2|d:1493805600|h:db01|#env:prod|m:connection m\\:refused")
+	if err != nil {
+		t.Fatalf("Parsing service check should not have failed: %s", err)
+	}
+
+	acc := &testutil.Accumulator{}
+	s.Gather(acc)
+
+	acc.AssertContainsTaggedFields(t, "statsd_service_checks",
+		map[string]interface{}{
+			"status":  int64(2),
+			"message": "connection m:refused",
+		},
+		map[string]string{
+			"check":  "db.connection",
+			"source": "db01",
+			"env":    "prod",
+		})
+}
+
+func TestParse_DataDogInvalidLines(t *testing.T) {
+	invalidLines := []string{
+		"_e{5,4:title|text",
+		"_e{5}:title|text",
+		"_e{a,4}:title|text",
+		"_e{10,4}:title|text",
+		"_e{5,4}:title|text|x:unknown",
+		"_e{5,4}:title|text|d:notatime",
+		"_sc|name",
+		"_sc|name|4",
+		"_sc||0",
+		"_sc|name|0|x:unknown",
+	}
+	s := NewTestStatsd()
+	for _, line := range invalidLines {
+		if err := s.parseStatsdLine(line); err == nil {
+			t.Errorf("Parsing line %s should have resulted in an error\n", line)
+		}
+	}
+}
+
+func TestParse_Distributions(t *testing.T) {
+	s := NewTestStatsd()
+	s.Percentiles = []int{90}
+	s.DistributionPercentiles = []int{50}
+
+	lines := []string{
+		"test.distribution:1|d",
+		"test.distribution:2|d",
+		"test.distribution:3|d",
+		"test.timing:1|ms",
+	}
+	for _, line := range lines {
+		if err := s.parseStatsdLine(line); err != nil {
+			t.Fatalf("Parsing line %s should not have resulted in an error\n", line)
+		}
+	}
+
+	acc := &testutil.Accumulator{}
+	s.Gather(acc)
+
+	acc.AssertContainsTaggedFields(t, "test_distribution",
+		map[string]interface{}{
+			"50_percentile": float64(2),
+			"count":         int64(3),
+			"lower":         float64(1),
+			"mean":          float64(2),
+			"stddev":        float64(0.816496580927726),
+			"upper":         float64(3),
+		},
+		map[string]string{
+			"metric_type": "distribution",
+		})
+
+	if !acc.HasFloatField("test_timing", "90_percentile") {
+		t.Error("Timings should use the timing percentiles")
+	}
+}
@@ -1,6 +1,7 @@
 package statsd
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"log"
@@ -14,6 +15,7 @@ import (
 	"github.com/influxdata/telegraf/plugins/parsers/graphite"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
@@ -26,6 +28,8 @@ const (
 
 	defaultSeparator           = "_"
 	defaultAllowPendingMessage = 10000
+	defaultProtocol            = "udp"
+	defaultMaxTCPConnections   = 250
 )
 
 var dropwarn = "E! Error: statsd message queue full. " +
@@ -33,9 +37,21 @@ var dropwarn = "E! Error: statsd message queue full. " +
 	"You may want to increase allowed_pending_messages in the config\n"
 
 type Statsd struct {
-	// Address & Port to serve from
+	// Protocol used on the listener: udp, tcp, unixgram or unix
+	Protocol string `toml:"protocol"`
+
+	// Address & Port to serve from, or path of the unix socket
 	ServiceAddress string
 
+	// MaxTCPConnections caps the number of concurrent connections on stream
+	// sockets (tcp and unix). 0 is unlimited.
+	MaxTCPConnections int `toml:"max_tcp_connections"`
+
+	// TCPKeepAlive enables keep-alive probes on tcp connections, sent every
+	// TCPKeepAlivePeriod, or at the OS default period if unset.
+	TCPKeepAlive       bool               `toml:"tcp_keep_alive"`
+	TCPKeepAlivePeriod *internal.Duration `toml:"tcp_keep_alive_period"`
+
 	// Number of messages allowed to queue up in between calls to Gather. If this
 	// fills up, packets will get dropped until the next Gather interval is ran.
 	AllowedPendingMessages int
@@ -44,6 +60,9 @@ type Statsd struct {
 	// and histogram stats.
 	Percentiles     []int
 	PercentileLimit int
+	// DistributionPercentiles specifies the percentiles that will be
+	// calculated for distribution stats, defaults to Percentiles.
+	DistributionPercentiles []int `toml:"distribution_percentiles"`
 
 	DeleteGauges   bool
 	DeleteCounters bool
@@ -74,16 +93,28 @@ type Statsd struct {
 
 	// Cache gauges, counters & sets so they can be aggregated as they arrive
 	// gauges and counters map measurement/tags hash -> field name -> metrics
-	// sets and timings map measurement/tags hash -> metrics
-	gauges   map[string]cachedgauge
-	counters map[string]cachedcounter
-	sets     map[string]cachedset
-	timings  map[string]cachedtimings
+	// sets, timings and distributions map measurement/tags hash -> metrics
+	gauges        map[string]cachedgauge
+	counters      map[string]cachedcounter
+	sets          map[string]cachedset
+	timings       map[string]cachedtimings
+	distributions map[string]cachedtimings
+
+	// DogStatsD events and service checks are not aggregated, they are all
+	// reported on the next call to Gather
+	events []cachedevent
 
 	// bucket -> influx templates
 	Templates []string
 
-	listener *net.UDPConn
+	// conn is the listener of packet protocols (udp and unixgram)
+	conn net.PacketConn
+	// listener is the listener of stream protocols (tcp and unix)
+	listener net.Listener
+
+	// conns tracks the current connections of stream protocols
+	conns    map[string]net.Conn
+	connsMtx sync.Mutex
 
 	graphiteParser *graphite.GraphiteParser
 }
@@ -132,9 +163,23 @@ func (_ *Statsd) Description() string {
 }
 
 const sampleConfig = `
-  ## Address and port to host UDP listener on
+  ## Protocol, must be "udp" (default), "tcp", "unixgram" or "unix"
+  protocol = "udp"
+
+  ## Address and port to host the listener on, or path of the unix socket
   service_address = ":8125"
 
+  ## Maximum number of concurrent tcp or unix connections, 0 is unlimited.
+  max_tcp_connections = 250
+
+  ## Enable TCP keep alive probes (default=false)
+  tcp_keep_alive = false
+
+  ## Specifies the keep-alive period for an active network connection.
+  ## Only applies to TCP sockets and will be ignored if tcp_keep_alive is false.
+  ## Defaults to the OS configuration.
+  # tcp_keep_alive_period = "2h"
+
   ## The following configuration options control when telegraf clears it's cache
   ## of previous values. If set to false, then telegraf will only clear it's
   ## cache when the daemon is restarted.
@@ -144,11 +189,13 @@ const sampleConfig = `
   delete_counters = true
   ## Reset sets every interval (default=true)
   delete_sets = true
-  ## Reset timings & histograms every interval (default=true)
+  ## Reset timings, histograms & distributions every interval (default=true)
   delete_timings = true
 
   ## Percentiles to calculate for timing & histogram stats
   percentiles = [90]
+  ## Percentiles to calculate for distribution stats, defaults to percentiles
+  # distribution_percentiles = [50, 90, 99]
 
   ## separator to use between elements of a statsd metric
   metric_separator = "_"
@@ -163,7 +210,7 @@ const sampleConfig = `
   #     "cpu.* measurement*"
   # ]
 
-  ## Number of UDP messages allowed to queue up, once filled,
+  ## Number of messages allowed to queue up, once filled,
   ## the statsd server will start dropping packets
   allowed_pending_messages = 10000
 
@@ -182,31 +229,18 @@ func (s *Statsd) Gather(acc telegraf.Accumulator) error {
 	defer s.Unlock()
 	now := time.Now()
 
-	for _, metric := range s.timings {
-		// Defining a template to parse field names for timers allows us to split
-		// out multiple fields per timer. In this case we prefix each stat with the
-		// field name and store these all in a single measurement.
-		fields := make(map[string]interface{})
-		for fieldName, stats := range metric.fields {
-			var prefix string
-			if fieldName != defaultFieldName {
-				prefix = fieldName + "_"
-			}
-			fields[prefix+"mean"] = stats.Mean()
-			fields[prefix+"stddev"] = stats.Stddev()
-			fields[prefix+"upper"] = stats.Upper()
-			fields[prefix+"lower"] = stats.Lower()
-			fields[prefix+"count"] = stats.Count()
-			for _, percentile := range s.Percentiles {
-				name := fmt.Sprintf("%s%v_percentile", prefix, percentile)
-				fields[name] = stats.Percentile(percentile)
-			}
-		}
+	s.gatherTimings(acc, s.timings, s.Percentiles, now)
+	if s.DeleteTimings {
+		s.timings = make(map[string]cachedtimings)
+	}
 
-		acc.AddFields(metric.name, fields, metric.tags, now)
+	percentiles := s.DistributionPercentiles
+	if len(percentiles) == 0 {
+		percentiles = s.Percentiles
 	}
+	s.gatherTimings(acc, s.distributions, percentiles, now)
 	if s.DeleteTimings {
-		s.timings = make(map[string]cachedtimings)
+		s.distributions = make(map[string]cachedtimings)
 	}
 
 	for _, metric := range s.gauges {
@@ -234,9 +268,51 @@ func (s *Statsd) Gather(acc telegraf.Accumulator) error {
 		s.sets = make(map[string]cachedset)
 	}
 
+	for _, event := range s.events {
+		timestamp := event.timestamp
+		if timestamp.IsZero() {
+			timestamp = now
+		}
+		acc.AddFields(event.name, event.fields, event.tags, timestamp)
+	}
+	s.events = nil
+
 	return nil
 }
 
+// gatherTimings adds the stats of the given timings, histograms or
+// distributions to the accumulator.
+func (s *Statsd) gatherTimings(
+	acc telegraf.Accumulator,
+	timings map[string]cachedtimings,
+	percentiles []int,
+	now time.Time,
+) {
+	for _, metric := range timings {
+		// Defining a template to parse field names for timers allows us to split
+		// out multiple fields per timer. In this case we prefix each stat with the
+		// field name and store these all in a single measurement.
+		fields := make(map[string]interface{})
+		for fieldName, stats := range metric.fields {
+			var prefix string
+			if fieldName != defaultFieldName {
+				prefix = fieldName + "_"
+			}
+			fields[prefix+"mean"] = stats.Mean()
+			fields[prefix+"stddev"] = stats.Stddev()
+			fields[prefix+"upper"] = stats.Upper()
+			fields[prefix+"lower"] = stats.Lower()
+			fields[prefix+"count"] = stats.Count()
+			for _, percentile := range percentiles {
+				name := fmt.Sprintf("%s%v_percentile", prefix, percentile)
+				fields[name] = stats.Percentile(percentile)
+			}
+		}
+
+		acc.AddFields(metric.name, fields, metric.tags, now)
+	}
+}
+
 func (s *Statsd) Start(_ telegraf.Accumulator) error {
 	// Make data structures
 	s.done = make(chan struct{})
@@ -246,6 +322,7 @@ func (s *Statsd) Start(_ telegraf.Accumulator) error {
 	s.counters = make(map[string]cachedcounter)
 	s.sets = make(map[string]cachedset)
 	s.timings = make(map[string]cachedtimings)
+	s.distributions = make(map[string]cachedtimings)
 
 	if s.ConvertNames {
 		log.Printf("I! WARNING statsd: convert_names config option is deprecated," +
@@ -256,49 +333,163 @@ func (s *Statsd) Start(_ telegraf.Accumulator) error {
 		s.MetricSeparator = defaultSeparator
 	}
 
-	s.wg.Add(2)
-	// Start the UDP listener
-	go s.udpListen()
+	if s.Protocol == "" {
+		s.Protocol = defaultProtocol
+	}
+
+	switch s.Protocol {
+	case "udp", "udp4", "udp6", "unixgram":
+		conn, err := net.ListenPacket(s.Protocol, s.ServiceAddress)
+		if err != nil {
+			return fmt.Errorf("statsd: unable to listen on %s://%s: %s",
+				s.Protocol, s.ServiceAddress, err)
+		}
+		s.conn = conn
+		log.Printf("I! Statsd %s listener listening on: %s\n",
+			s.Protocol, conn.LocalAddr().String())
+
+		s.wg.Add(1)
+		go s.packetListen(conn)
+	case "tcp", "tcp4", "tcp6", "unix":
+		listener, err := net.Listen(s.Protocol, s.ServiceAddress)
+		if err != nil {
+			return fmt.Errorf("statsd: unable to listen on %s://%s: %s",
+				s.Protocol, s.ServiceAddress, err)
+		}
+		s.listener = listener
+		s.conns = make(map[string]net.Conn)
+		log.Printf("I! Statsd %s listener listening on: %s\n",
+			s.Protocol, listener.Addr().String())
+
+		s.wg.Add(1)
+		go s.streamListen(listener)
+	default:
+		return fmt.Errorf("statsd: unknown protocol %q", s.Protocol)
+	}
+
+	s.wg.Add(1)
 	// Start the line parser
 	go s.parser()
 	log.Printf("I! Started the statsd service on %s\n", s.ServiceAddress)
 	return nil
 }
 
-// udpListen starts listening for udp packets on the configured port.
-func (s *Statsd) udpListen() error {
+// packetListen reads the packets received on a udp or unixgram socket.
+func (s *Statsd) packetListen(conn net.PacketConn) {
 	defer s.wg.Done()
-	var err error
-	address, _ := net.ResolveUDPAddr("udp", s.ServiceAddress)
-	s.listener, err = net.ListenUDP("udp", address)
-	if err != nil {
-		log.Fatalf("ERROR: ListenUDP - %s", err)
-	}
-	log.Println("I! Statsd listener listening on: ", s.listener.LocalAddr().String())
 
 	buf := make([]byte, UDP_MAX_PACKET_SIZE)
 	for {
 		select {
 		case <-s.done:
-			return nil
+			return
 		default:
-			n, _, err := s.listener.ReadFromUDP(buf)
-			if err != nil && !strings.Contains(err.Error(), "closed network") {
-				log.Printf("E! Error READ: %s\n", err.Error())
-				continue
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				if !strings.Contains(err.Error(), "closed network") {
+					log.Printf("E! Error READ: %s\n", err.Error())
+					continue
+				}
+				return
 			}
 			bufCopy := make([]byte, n)
 			copy(bufCopy, buf[:n])
+			s.enqueue(bufCopy)
+		}
+	}
+}
+
+// streamListen accepts the connections of a tcp or unix socket, and starts
+// a handler for each of them.
+func (s *Statsd) streamListen(listener net.Listener) {
+	defer s.wg.Done()
 
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
 			select {
-			case s.in <- bufCopy:
+			case <-s.done:
+				return
 			default:
-				s.drops++
-				if s.drops == 1 || s.AllowedPendingMessages == 0 || s.drops%s.AllowedPendingMessages == 0 {
-					log.Printf(dropwarn, s.drops)
+			}
+			if !strings.Contains(err.Error(), "closed network") {
+				log.Printf("E! Error accepting statsd connection: %s\n", err)
+				continue
+			}
+			return
+		}
+
+		if s.TCPKeepAlive {
+			if tcpConn, ok := conn.(*net.TCPConn); ok {
+				tcpConn.SetKeepAlive(true)
+				if s.TCPKeepAlivePeriod != nil {
+					tcpConn.SetKeepAlivePeriod(s.TCPKeepAlivePeriod.Duration)
 				}
 			}
 		}
+
+		s.connsMtx.Lock()
+		if s.MaxTCPConnections > 0 && len(s.conns) >= s.MaxTCPConnections {
+			s.connsMtx.Unlock()
+			log.Printf("E! Statsd refused connection from %s, the maximum of "+
+				"%d connections is reached\n",
+				conn.RemoteAddr(), s.MaxTCPConnections)
+			conn.Close()
+			continue
+		}
+		id := conn.RemoteAddr().String() + "-" + internal.RandomString(6)
+		s.conns[id] = conn
+		s.connsMtx.Unlock()
+
+		s.wg.Add(1)
+		go s.handler(conn, id)
+	}
+}
+
+// handler reads the lines sent on a stream connection.
+func (s *Statsd) handler(conn net.Conn, id string) {
+	defer s.wg.Done()
+	defer func() {
+		s.connsMtx.Lock()
+		delete(s.conns, id)
+		s.connsMtx.Unlock()
+		conn.Close()
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		lineCopy := make([]byte, len(line))
+		copy(lineCopy, line)
+		s.enqueue(lineCopy)
+	}
+
+	select {
+	case <-s.done:
+	default:
+		if err := scanner.Err(); err != nil {
+			log.Printf("E! Error reading statsd connection from %s: %s\n",
+				conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// enqueue queues a packet or a line for the parser, dropping it when the
+// queue is full.
+func (s *Statsd) enqueue(buf []byte) {
+	select {
+	case s.in <- buf:
+	default:
+		s.Lock()
+		s.drops++
+		drops := s.drops
+		s.Unlock()
+		if drops == 1 || s.AllowedPendingMessages == 0 || drops%s.AllowedPendingMessages == 0 {
+			log.Printf(dropwarn, drops)
+		}
 	}
 }
 
@@ -330,6 +521,14 @@ func (s *Statsd) parseStatsdLine(line string) error {
 	s.Lock()
 	defer s.Unlock()
 
+	// DogStatsD events and service checks
+	switch {
+	case strings.HasPrefix(line, "_e{"):
+		return s.parseEventLine(line)
+	case strings.HasPrefix(line, "_sc|"):
+		return s.parseServiceCheckLine(line)
+	}
+
 	lineTags := make(map[string]string)
 	if s.ParseDataDogTags {
 		recombinedSegments := make([]string, 0)
@@ -342,24 +541,7 @@ func (s *Statsd) parseStatsdLine(line string) error {
 		for _, segment := range pipesplit {
 			if len(segment) > 0 && segment[0] == '#' {
 				// we have ourselves a tag; they are comma separated
-				tagstr := segment[1:]
-				tags := strings.Split(tagstr, ",")
-				for _, tag := range tags {
-					ts := strings.SplitN(tag, ":", 2)
-					var k, v string
-					switch len(ts) {
-					case 1:
-						// just a tag
-						k = ts[0]
-						v = ""
-					case 2:
-						k = ts[0]
-						v = ts[1]
-					}
-					if k != "" {
-						lineTags[k] = v
-					}
-				}
+				parseDataDogTags(segment[1:], lineTags)
 			} else {
 				recombinedSegments = append(recombinedSegments, segment)
 			}
@@ -407,7 +589,7 @@ func (s *Statsd) parseStatsdLine(line string) error {
 
 		// Validate metric type
 		switch pipesplit[1] {
-		case "g", "c", "s", "ms", "h":
+		case "g", "c", "s", "ms", "h", "d":
 			m.mtype = pipesplit[1]
 		default:
 			log.Printf("E! Error: Statsd Metric type %s unsupported", pipesplit[1])
@@ -424,7 +606,7 @@ func (s *Statsd) parseStatsdLine(line string) error {
 		}
 
 		switch m.mtype {
-		case "g", "ms", "h":
+		case "g", "ms", "h", "d":
 			v, err := strconv.ParseFloat(pipesplit[0], 64)
 			if err != nil {
 				log.Printf("E! Error: parsing value to float64: %s\n", line)
@@ -464,6 +646,8 @@ func (s *Statsd) parseStatsdLine(line string) error {
 			m.tags["metric_type"] = "timing"
 		case "h":
 			m.tags["metric_type"] = "histogram"
+		case "d":
+			m.tags["metric_type"] = "distribution"
 		}
 
 		if len(lineTags) > 0 {
@@ -553,32 +737,9 @@ func parseKeyValue(keyvalue string) (string, string) {
 func (s *Statsd) aggregate(m metric) {
 	switch m.mtype {
 	case "ms", "h":
-		// Check if the measurement exists
-		cached, ok := s.timings[m.hash]
-		if !ok {
-			cached = cachedtimings{
-				name:   m.name,
-				fields: make(map[string]RunningStats),
-				tags:   m.tags,
-			}
-		}
-		// Check if the field exists. If we've not enabled multiple fields per timer
-		// this will be the default field name, eg. "value"
-		field, ok := cached.fields[m.field]
-		if !ok {
-			field = RunningStats{
-				PercLimit: s.PercentileLimit,
-			}
-		}
-		if m.samplerate > 0 {
-			for i := 0; i < int(1.0/m.samplerate); i++ {
-				field.AddValue(m.floatvalue)
-			}
-		} else {
-			field.AddValue(m.floatvalue)
-		}
-		cached.fields[m.field] = field
-		s.timings[m.hash] = cached
+		s.aggregateTiming(s.timings, m)
+	case "d":
+		s.aggregateTiming(s.distributions, m)
 	case "c":
 		// check if the measurement exists
 		_, ok := s.counters[m.hash]
@@ -636,20 +797,70 @@ func (s *Statsd) aggregate(m metric) {
 	}
 }
 
+// aggregateTiming adds the value of a timing, histogram or distribution to
+// the stats cached in timings.
+func (s *Statsd) aggregateTiming(timings map[string]cachedtimings, m metric) {
+	// Check if the measurement exists
+	cached, ok := timings[m.hash]
+	if !ok {
+		cached = cachedtimings{
+			name:   m.name,
+			fields: make(map[string]RunningStats),
+			tags:   m.tags,
+		}
+	}
+	// Check if the field exists. If we've not enabled multiple fields per timer
+	// this will be the default field name, eg. "value"
+	field, ok := cached.fields[m.field]
+	if !ok {
+		field = RunningStats{
+			PercLimit: s.PercentileLimit,
+		}
+	}
+	if m.samplerate > 0 {
+		for i := 0; i < int(1.0/m.samplerate); i++ {
+			field.AddValue(m.floatvalue)
+		}
+	} else {
+		field.AddValue(m.floatvalue)
+	}
+	cached.fields[m.field] = field
+	timings[m.hash] = cached
+}
+
 func (s *Statsd) Stop() {
 	s.Lock()
 	defer s.Unlock()
 	log.Println("I! Stopping the statsd service")
 	close(s.done)
-	s.listener.Close()
+
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.listener != nil {
+		s.listener.Close()
+
+		s.connsMtx.Lock()
+		for _, conn := range s.conns {
+			conn.Close()
+		}
+		s.connsMtx.Unlock()
+	}
+
+	// the parser may be waiting for the lock to cache a line
+	s.Unlock()
 	s.wg.Wait()
+	s.Lock()
+
 	close(s.in)
 }
 
 func init() {
 	inputs.Add("statsd", func() telegraf.Input {
 		return &Statsd{
+			Protocol:               defaultProtocol,
 			ServiceAddress:         ":8125",
+			MaxTCPConnections:      defaultMaxTCPConnections,
 			MetricSeparator:        "_",
 			AllowedPendingMessages: defaultAllowPendingMessage,
 			DeleteCounters:         true,
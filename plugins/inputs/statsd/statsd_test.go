@@ -3,7 +3,10 @@ package statsd
 import (
 	"errors"
 	"fmt"
+	"net"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/influxdata/telegraf/testutil"
 )
@@ -18,12 +21,145 @@ func NewTestStatsd() *Statsd {
 	s.counters = make(map[string]cachedcounter)
 	s.sets = make(map[string]cachedset)
 	s.timings = make(map[string]cachedtimings)
+	s.distributions = make(map[string]cachedtimings)
 
 	s.MetricSeparator = "_"
 
 	return &s
 }
 
+func newTestListener(protocol, address string) *Statsd {
+	return &Statsd{
+		Protocol:               protocol,
+		ServiceAddress:         address,
+		MetricSeparator:        "_",
+		AllowedPendingMessages: defaultAllowPendingMessage,
+	}
+}
+
+// waitForGauge waits for the given gauge to be cached by the listener
+func waitForGauge(s *Statsd, name string, value float64) error {
+	var err error
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		err = test_validate_gauge(name, value, s.gauges)
+		s.Unlock()
+		if err == nil {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return err
+}
+
+func TestListen_TCP(t *testing.T) {
+	s := newTestListener("tcp", "127.0.0.1:0")
+	s.TCPKeepAlive = true
+	if err := s.Start(&testutil.Accumulator{}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("tcp.gauge:1|g\ntcp.other:2|g\n"))
+
+	if err := waitForGauge(s, "tcp_gauge", 1); err != nil {
+		t.Error(err)
+	}
+	if err := waitForGauge(s, "tcp_other", 2); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListen_Unix(t *testing.T) {
+	defer os.Remove("/tmp/telegraf_statsd_test.sock")
+	s := newTestListener("unix", "/tmp/telegraf_statsd_test.sock")
+	if err := s.Start(&testutil.Accumulator{}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial("unix", "/tmp/telegraf_statsd_test.sock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("unix.gauge:3|g\n"))
+
+	if err := waitForGauge(s, "unix_gauge", 3); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListen_UDP(t *testing.T) {
+	s := newTestListener("udp", "127.0.0.1:0")
+	if err := s.Start(&testutil.Accumulator{}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial("udp", s.conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("udp.gauge:4|g"))
+
+	if err := waitForGauge(s, "udp_gauge", 4); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestListen_MaxTCPConnections(t *testing.T) {
+	s := newTestListener("tcp", "127.0.0.1:0")
+	s.MaxTCPConnections = 1
+	if err := s.Start(&testutil.Accumulator{}); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	conn1, err := net.Dial("tcp", s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn1.Close()
+	conn1.Write([]byte("first:1|g\n"))
+	if err := waitForGauge(s, "first", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	conn2, err := net.Dial("tcp", s.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn2.Close()
+
+	// the second connection is closed by the listener
+	conn2.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn2.Read(make([]byte, 1)); err == nil {
+		t.Error("expected the connection over the limit to be closed")
+	}
+}
+
+func TestStart_Errors(t *testing.T) {
+	s := newTestListener("sctp", ":8125")
+	if err := s.Start(&testutil.Accumulator{}); err == nil {
+		t.Error("expected an error for an unknown protocol")
+	}
+
+	s = newTestListener("tcp", "127.0.0.1:notaport")
+	if err := s.Start(&testutil.Accumulator{}); err == nil {
+		t.Error("expected an error for an invalid address")
+	}
+}
+
 // Valid lines should be parsed and their values should be cached
 func TestParse_ValidLines(t *testing.T) {
 	s := NewTestStatsd()
@@ -0,0 +1,255 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const nilValue = "-"
+
+var severities = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+var facilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console",
+	"solaris-cron", "local0", "local1", "local2", "local3", "local4",
+	"local5", "local6", "local7",
+}
+
+// message is a parsed syslog message, either RFC5424 or RFC3164.
+type message struct {
+	facility  int
+	severity  int
+	version   int
+	timestamp time.Time
+	hostname  string
+	appname   string
+	procid    string
+	msgid     string
+	// structuredData maps SD-IDs to their parameters.
+	structuredData map[string]map[string]string
+	message        string
+}
+
+// parse parses a single syslog message, detecting whether it is formatted
+// according to RFC5424 or to RFC3164 from the presence of the version.
+func parse(buf []byte, now time.Time) (*message, error) {
+	buf = bytes.TrimRight(buf, "\r\n\x00")
+
+	pri, rest, err := parsePriority(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &message{
+		facility: pri / 8,
+		severity: pri % 8,
+	}
+
+	// an RFC5424 message carries a version right after the priority, where
+	// an RFC3164 message has its timestamp.
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' &&
+		(rest[1] == ' ' || (rest[1] >= '0' && rest[1] <= '9')) {
+		err = m.parseRFC5424(rest)
+	} else {
+		err = m.parseRFC3164(rest, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func parsePriority(buf []byte) (int, []byte, error) {
+	if len(buf) < 3 || buf[0] != '<' {
+		return 0, nil, fmt.Errorf("missing priority")
+	}
+	end := bytes.IndexByte(buf[:min(len(buf), 5)], '>')
+	if end < 2 {
+		return 0, nil, fmt.Errorf("invalid priority")
+	}
+	pri, err := strconv.Atoi(string(buf[1:end]))
+	if err != nil || pri > 191 {
+		return 0, nil, fmt.Errorf("invalid priority %q", buf[1:end])
+	}
+	return pri, buf[end+1:], nil
+}
+
+// parseRFC5424 parses the part of an RFC5424 message following the priority:
+//   VERSION SP TIMESTAMP SP HOSTNAME SP APP-NAME SP PROCID SP MSGID SP
+//   STRUCTURED-DATA [SP MSG]
+func (m *message) parseRFC5424(buf []byte) error {
+	fields := bytes.SplitN(buf, []byte{' '}, 7)
+	if len(fields) < 7 {
+		return fmt.Errorf("truncated RFC5424 message")
+	}
+
+	version, err := strconv.Atoi(string(fields[0]))
+	if err != nil {
+		return fmt.Errorf("invalid version %q", fields[0])
+	}
+	m.version = version
+
+	if ts := string(fields[1]); ts != nilValue {
+		m.timestamp, err = time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp %q", ts)
+		}
+	}
+
+	m.hostname = nilOrValue(fields[2])
+	m.appname = nilOrValue(fields[3])
+	m.procid = nilOrValue(fields[4])
+	m.msgid = nilOrValue(fields[5])
+
+	rest := fields[6]
+	if bytes.HasPrefix(rest, []byte(nilValue)) {
+		rest = rest[1:]
+	} else {
+		rest, err = m.parseStructuredData(rest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(rest) > 0 {
+		if rest[0] != ' ' {
+			return fmt.Errorf("invalid structured data")
+		}
+		rest = bytes.TrimPrefix(rest[1:], []byte("\xef\xbb\xbf"))
+	}
+	m.message = string(rest)
+	return nil
+}
+
+// parseStructuredData parses the SD-ELEMENTs at the start of buf and returns
+// what follows them.
+func (m *message) parseStructuredData(buf []byte) ([]byte, error) {
+	m.structuredData = make(map[string]map[string]string)
+
+	for len(buf) > 0 && buf[0] == '[' {
+		end := bytes.IndexAny(buf, " ]")
+		if end < 2 {
+			return nil, fmt.Errorf("invalid structured data element")
+		}
+		id := string(buf[1:end])
+		params := make(map[string]string)
+		m.structuredData[id] = params
+		buf = buf[end:]
+
+		for len(buf) > 0 && buf[0] == ' ' {
+			buf = buf[1:]
+			eq := bytes.IndexByte(buf, '=')
+			if eq < 1 || len(buf) < eq+2 || buf[eq+1] != '"' {
+				return nil, fmt.Errorf("invalid structured data parameter in %q", id)
+			}
+			name := string(buf[:eq])
+
+			value, n, err := parseParamValue(buf[eq+2:])
+			if err != nil {
+				return nil, fmt.Errorf("%s in %q", err, id)
+			}
+			params[name] = value
+			buf = buf[eq+2+n:]
+		}
+
+		if len(buf) == 0 || buf[0] != ']' {
+			return nil, fmt.Errorf("unterminated structured data element %q", id)
+		}
+		buf = buf[1:]
+	}
+
+	if len(m.structuredData) == 0 {
+		return nil, fmt.Errorf("invalid structured data")
+	}
+	return buf, nil
+}
+
+// parseParamValue parses a quoted parameter value, starting right after the
+// opening quote, and returns it along with the number of bytes consumed,
+// including the closing quote.
+func parseParamValue(buf []byte) (string, int, error) {
+	var value bytes.Buffer
+	for i := 0; i < len(buf); i++ {
+		switch buf[i] {
+		case '\\':
+			if i+1 < len(buf) {
+				switch buf[i+1] {
+				case '"', '\\', ']':
+					i++
+				}
+			}
+		case '"':
+			return value.String(), i + 1, nil
+		}
+		value.WriteByte(buf[i])
+	}
+	return "", 0, fmt.Errorf("unterminated parameter value")
+}
+
+// parseRFC3164 parses the part of a BSD syslog message following the
+// priority:
+//   Mmm dd hh:mm:ss SP HOSTNAME SP TAG[PID]: MSG
+// As the timestamp has neither year nor timezone, the current year and the
+// local timezone are assumed.
+func (m *message) parseRFC3164(buf []byte, now time.Time) error {
+	const layout = time.Stamp
+	if len(buf) < len(layout)+1 || buf[len(layout)] != ' ' {
+		return fmt.Errorf("invalid RFC3164 timestamp")
+	}
+	ts, err := time.ParseInLocation(layout, string(buf[:len(layout)]), now.Location())
+	if err != nil {
+		return fmt.Errorf("invalid RFC3164 timestamp %q", buf[:len(layout)])
+	}
+	m.timestamp = time.Date(now.Year(), ts.Month(), ts.Day(),
+		ts.Hour(), ts.Minute(), ts.Second(), 0, now.Location())
+	// a message from december received in january
+	if m.timestamp.After(now.AddDate(0, 1, 0)) {
+		m.timestamp = m.timestamp.AddDate(-1, 0, 0)
+	}
+	buf = buf[len(layout)+1:]
+
+	sp := bytes.IndexByte(buf, ' ')
+	if sp < 1 {
+		return fmt.Errorf("missing RFC3164 hostname")
+	}
+	m.hostname = string(buf[:sp])
+	rest := string(buf[sp+1:])
+
+	// the tag is optional, it is the alphanumeric name of the program,
+	// possibly followed by its pid within brackets, and terminated by a colon
+	end := strings.IndexAny(rest, ":[ ")
+	if end > 0 && end <= 32 && rest[end] != ' ' {
+		m.appname = rest[:end]
+		rest = rest[end:]
+		if rest[0] == '[' {
+			if end := strings.IndexByte(rest, ']'); end > 0 {
+				m.procid = rest[1:end]
+				rest = rest[end+1:]
+			}
+		}
+		rest = strings.TrimPrefix(rest, ":")
+		rest = strings.TrimPrefix(rest, " ")
+	}
+	m.message = rest
+	return nil
+}
+
+func nilOrValue(b []byte) string {
+	if s := string(b); s != nilValue {
+		return s
+	}
+	return ""
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,105 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	now := time.Date(2017, 5, 3, 12, 0, 0, 0, time.UTC)
+	msg, err := parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][examplePriority@32473 class="high"] An application event log entry...`), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, 20, msg.facility)
+	assert.Equal(t, 5, msg.severity)
+	assert.Equal(t, 1, msg.version)
+	assert.True(t, time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC).Equal(msg.timestamp))
+	assert.Equal(t, "mymachine.example.com", msg.hostname)
+	assert.Equal(t, "evntslog", msg.appname)
+	assert.Equal(t, "", msg.procid)
+	assert.Equal(t, "ID47", msg.msgid)
+	assert.Equal(t, map[string]map[string]string{
+		"exampleSDID@32473": {
+			"iut":         "3",
+			"eventSource": "Application",
+			"eventID":     "1011",
+		},
+		"examplePriority@32473": {
+			"class": "high",
+		},
+	}, msg.structuredData)
+	assert.Equal(t, "An application event log entry...", msg.message)
+}
+
+func TestParseRFC5424NilValues(t *testing.T) {
+	now := time.Date(2017, 5, 3, 12, 0, 0, 0, time.UTC)
+	msg, err := parse([]byte("<34>1 - - - - - -\n"), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, msg.facility)
+	assert.Equal(t, 2, msg.severity)
+	assert.True(t, msg.timestamp.IsZero())
+	assert.Equal(t, "", msg.hostname)
+	assert.Nil(t, msg.structuredData)
+	assert.Equal(t, "", msg.message)
+}
+
+func TestParseRFC5424EscapedParam(t *testing.T) {
+	now := time.Date(2017, 5, 3, 12, 0, 0, 0, time.UTC)
+	msg, err := parse([]byte(`<13>1 2017-05-03T10:00:00+02:00 host app 1234 - [meta path="c:\\tmp\]" quote="\"x\""] `+"\xef\xbb\xbf"+`hello`), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "1234", msg.procid)
+	assert.Equal(t, map[string]string{
+		"path":  `c:\tmp]`,
+		"quote": `"x"`,
+	}, msg.structuredData["meta"])
+	assert.Equal(t, "hello", msg.message)
+}
+
+func TestParseRFC3164(t *testing.T) {
+	now := time.Date(2017, 5, 3, 12, 0, 0, 0, time.UTC)
+	msg, err := parse([]byte("<34>Oct 11 22:14:15 mymachine su[42]: 'su root' failed for lonvick on /dev/pts/8"), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, msg.facility)
+	assert.Equal(t, 2, msg.severity)
+	assert.Equal(t, 0, msg.version)
+	assert.True(t, time.Date(2016, 10, 11, 22, 14, 15, 0, time.UTC).Equal(msg.timestamp))
+	assert.Equal(t, "mymachine", msg.hostname)
+	assert.Equal(t, "su", msg.appname)
+	assert.Equal(t, "42", msg.procid)
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", msg.message)
+}
+
+func TestParseRFC3164WithoutTag(t *testing.T) {
+	now := time.Date(2017, 5, 3, 12, 0, 0, 0, time.UTC)
+	msg, err := parse([]byte("<13>May  3 11:59:58 host some message"), now)
+	require.NoError(t, err)
+
+	assert.True(t, time.Date(2017, 5, 3, 11, 59, 58, 0, time.UTC).Equal(msg.timestamp))
+	assert.Equal(t, "host", msg.hostname)
+	assert.Equal(t, "", msg.appname)
+	assert.Equal(t, "some message", msg.message)
+}
+
+func TestParseInvalid(t *testing.T) {
+	now := time.Now()
+	for _, buf := range []string{
+		"",
+		"no priority",
+		"<>1 - - - - - -",
+		"<200>1 - - - - - -",
+		"<13>1 - - -",
+		"<13>1 notatime - - - - -",
+		"<13>1 - - - - - [unterminated",
+		`<13>1 - - - - - [id a="b]`,
+		"<13>Mmm 99 99:99:99 host msg",
+	} {
+		_, err := parse([]byte(buf), now)
+		assert.Error(t, err, buf)
+	}
+}
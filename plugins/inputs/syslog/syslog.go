@@ -0,0 +1,375 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+// maxMessageLength caps the length of an octet-counted message, as well as
+// the size of a datagram.
+const maxMessageLength = 64 * 1024
+
+type setReadBufferer interface {
+	SetReadBuffer(bytes int) error
+}
+
+type streamSyslogListener struct {
+	net.Listener
+	*Syslog
+
+	// the connections are keyed by themselves, as the remote address of
+	// unix socket peers is empty
+	connections    map[net.Conn]struct{}
+	connectionsMtx sync.Mutex
+}
+
+func (ssl *streamSyslogListener) listen() {
+	ssl.connections = map[net.Conn]struct{}{}
+
+	for {
+		c, err := ssl.Accept()
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
+				ssl.AddError(err)
+			}
+			break
+		}
+
+		ssl.connectionsMtx.Lock()
+		if ssl.MaxConnections > 0 && len(ssl.connections) >= ssl.MaxConnections {
+			ssl.connectionsMtx.Unlock()
+			c.Close()
+			continue
+		}
+		ssl.connections[c] = struct{}{}
+		ssl.connectionsMtx.Unlock()
+		go ssl.read(c)
+	}
+
+	ssl.connectionsMtx.Lock()
+	for c := range ssl.connections {
+		c.Close()
+	}
+	ssl.connectionsMtx.Unlock()
+}
+
+func (ssl *streamSyslogListener) removeConnection(c net.Conn) {
+	ssl.connectionsMtx.Lock()
+	delete(ssl.connections, c)
+	ssl.connectionsMtx.Unlock()
+}
+
+func (ssl *streamSyslogListener) read(c net.Conn) {
+	defer ssl.removeConnection(c)
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	for {
+		if ssl.ReadTimeout.Duration > 0 {
+			c.SetReadDeadline(time.Now().Add(ssl.ReadTimeout.Duration))
+		}
+
+		frame, err := readFrame(r)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return
+			}
+			if err != io.EOF {
+				ssl.AddError(err)
+			}
+			return
+		}
+		ssl.store(frame)
+	}
+}
+
+// readFrame reads a single message from a stream, framed either by octet
+// counting or by a trailing newline, as described in RFC6587. The framing
+// is detected for every message: octet-counted messages start with their
+// length, where newline terminated messages start with their priority.
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	b, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] < '1' || b[0] > '9' {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) > 0 {
+			// the connection was closed after a message without its
+			// trailing newline
+			err = nil
+		}
+		return line, err
+	}
+
+	msglen, err := r.ReadSlice(' ')
+	if err != nil {
+		return nil, fmt.Errorf("invalid octet-counting frame: %s", err)
+	}
+	n, err := strconv.Atoi(string(msglen[:len(msglen)-1]))
+	if err != nil || n > maxMessageLength {
+		return nil, fmt.Errorf("invalid octet-counting frame length %q", msglen)
+	}
+
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+type packetSyslogListener struct {
+	net.PacketConn
+	*Syslog
+}
+
+func (psl *packetSyslogListener) listen() {
+	buf := make([]byte, maxMessageLength)
+	for {
+		n, _, err := psl.ReadFrom(buf)
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
+				psl.AddError(err)
+			}
+			break
+		}
+
+		psl.store(buf[:n])
+	}
+}
+
+type Syslog struct {
+	Server         string
+	MaxConnections int
+	ReadBufferSize int
+	ReadTimeout    internal.Duration
+
+	// Path to the TLS certificate and key, enables TLS on tcp servers.
+	SSLCert string `toml:"ssl_cert"`
+	SSLKey  string `toml:"ssl_key"`
+	// Path to the CA used to verify the client certificates, when set the
+	// clients are required to present a certificate.
+	SSLCA string `toml:"ssl_ca"`
+
+	// SDParamSeparator separates the SD-ID from the parameter names in the
+	// field names of the structured data.
+	SDParamSeparator string `toml:"sdparam_separator"`
+
+	now func() time.Time
+
+	telegraf.Accumulator
+	io.Closer
+}
+
+func (s *Syslog) Description() string {
+	return "Accepts syslog messages following RFC5424 or RFC3164"
+}
+
+func (s *Syslog) SampleConfig() string {
+	return `
+  ## URL to listen on
+  # server = "tcp://:6514"
+  # server = "tcp4://:6514"
+  # server = "tcp6://:6514"
+  # server = "udp://:514"
+  # server = "unix:///tmp/syslog.sock"
+  # server = "unixgram:///tmp/syslog.sock"
+  server = "tcp://:6514"
+
+  ## TLS certificate and key, enables TLS on tcp servers.
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## CA used to verify client certificates, when set clients are required to
+  ## present a certificate signed by it.
+  # ssl_ca = "/etc/telegraf/ca.pem"
+
+  ## Maximum number of concurrent connections.
+  ## Only applies to stream sockets (e.g. TCP).
+  ## 0 (default) is unlimited.
+  # max_connections = 1024
+
+  ## Close connections that sent no message for this long.
+  ## Only applies to stream sockets (e.g. TCP).
+  ## 0 (default) never closes idle connections.
+  # read_timeout = "0s"
+
+  ## Maximum socket buffer size in bytes.
+  ## For stream sockets, once the buffer fills up, the sender will start backing up.
+  ## For datagram sockets, once the buffer fills up, messages will start dropping.
+  ## Defaults to the OS default.
+  # read_buffer_size = 65535
+
+  ## Separator between the SD-ID and the parameter names of the structured
+  ## data fields.
+  # sdparam_separator = "_"
+`
+}
+
+func (s *Syslog) Gather(_ telegraf.Accumulator) error {
+	return nil
+}
+
+func (s *Syslog) Start(acc telegraf.Accumulator) error {
+	s.Accumulator = acc
+	spl := strings.SplitN(s.Server, "://", 2)
+	if len(spl) != 2 {
+		return fmt.Errorf("invalid server address: %s", s.Server)
+	}
+
+	tlsConfig, err := internal.GetServerTLSConfig(s.SSLCert, s.SSLKey, s.SSLCA)
+	if err != nil {
+		return err
+	}
+
+	switch spl[0] {
+	case "tcp", "tcp4", "tcp6", "unix", "unixpacket":
+		if spl[0] == "unix" || spl[0] == "unixpacket" {
+			if tlsConfig != nil {
+				return fmt.Errorf("TLS is not supported on %s sockets", spl[0])
+			}
+		}
+
+		l, err := net.Listen(spl[0], spl[1])
+		if err != nil {
+			return err
+		}
+
+		if s.ReadBufferSize > 0 {
+			if srb, ok := l.(setReadBufferer); ok {
+				srb.SetReadBuffer(s.ReadBufferSize)
+			} else {
+				log.Printf("W! Unable to set read buffer on a %s socket", spl[0])
+			}
+		}
+
+		if tlsConfig != nil {
+			l = tls.NewListener(l, tlsConfig)
+		}
+
+		ssl := &streamSyslogListener{
+			Listener: l,
+			Syslog:   s,
+		}
+
+		s.Closer = ssl
+		go ssl.listen()
+	case "udp", "udp4", "udp6", "unixgram":
+		if tlsConfig != nil {
+			return fmt.Errorf("TLS is not supported on %s sockets", spl[0])
+		}
+
+		pc, err := net.ListenPacket(spl[0], spl[1])
+		if err != nil {
+			return err
+		}
+
+		if s.ReadBufferSize > 0 {
+			if srb, ok := pc.(setReadBufferer); ok {
+				srb.SetReadBuffer(s.ReadBufferSize)
+			} else {
+				log.Printf("W! Unable to set read buffer on a %s socket", spl[0])
+			}
+		}
+
+		psl := &packetSyslogListener{
+			PacketConn: pc,
+			Syslog:     s,
+		}
+
+		s.Closer = psl
+		go psl.listen()
+	default:
+		return fmt.Errorf("unknown protocol '%s' in '%s'", spl[0], s.Server)
+	}
+
+	return nil
+}
+
+func (s *Syslog) Stop() {
+	if s.Closer != nil {
+		s.Close()
+		s.Closer = nil
+	}
+}
+
+// store parses a syslog message and adds it to the accumulator.
+func (s *Syslog) store(buf []byte) {
+	if len(bytes.TrimSpace(buf)) == 0 {
+		return
+	}
+
+	now := s.now()
+	msg, err := parse(buf, now)
+	if err != nil {
+		s.AddError(fmt.Errorf("unable to parse syslog message: %s", err))
+		return
+	}
+
+	tags := map[string]string{
+		"severity": severities[msg.severity],
+		"facility": facilities[msg.facility],
+	}
+	if msg.hostname != "" {
+		tags["hostname"] = msg.hostname
+	}
+	if msg.appname != "" {
+		tags["appname"] = msg.appname
+	}
+
+	fields := map[string]interface{}{
+		"severity_code": msg.severity,
+		"facility_code": msg.facility,
+		"message":       msg.message,
+	}
+	if msg.version > 0 {
+		fields["version"] = msg.version
+	}
+	if msg.procid != "" {
+		fields["procid"] = msg.procid
+	}
+	if msg.msgid != "" {
+		fields["msgid"] = msg.msgid
+	}
+	for id, params := range msg.structuredData {
+		if len(params) == 0 {
+			fields[id] = true
+			continue
+		}
+		for name, value := range params {
+			fields[id+s.SDParamSeparator+name] = value
+		}
+	}
+
+	timestamp := msg.timestamp
+	if timestamp.IsZero() {
+		timestamp = now
+	}
+
+	s.AddFields("syslog", fields, tags, timestamp)
+}
+
+func newSyslog() *Syslog {
+	return &Syslog{
+		Server:           "tcp://:6514",
+		SDParamSeparator: "_",
+		now:              time.Now,
+	}
+}
+
+func init() {
+	inputs.Add("syslog", func() telegraf.Input { return newSyslog() })
+}
@@ -0,0 +1,177 @@
+package syslog
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultTime = time.Date(2017, 5, 3, 12, 0, 0, 0, time.UTC)
+
+func newTestSyslog(server string) *Syslog {
+	s := newSyslog()
+	s.Server = server
+	s.now = func() time.Time { return defaultTime }
+	return s
+}
+
+func TestSyslog_tcpNewlineFraming(t *testing.T) {
+	s := newTestSyslog("tcp://127.0.0.1:0")
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	client, err := net.Dial("tcp", s.Closer.(net.Listener).Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Write([]byte("<13>1 2017-05-03T10:00:00Z host app - - - first\n"))
+	client.Write([]byte("<13>May  3 11:00:00 host app: second\n"))
+
+	testSyslog(t, acc)
+}
+
+func TestSyslog_tcpOctetCounting(t *testing.T) {
+	s := newTestSyslog("tcp://127.0.0.1:0")
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	client, err := net.Dial("tcp", s.Closer.(net.Listener).Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Write([]byte("47 <13>1 2017-05-03T10:00:00Z host app - - - first"))
+	client.Write([]byte("36 <13>May  3 11:00:00 host app: second"))
+
+	testSyslog(t, acc)
+}
+
+func TestSyslog_udp(t *testing.T) {
+	s := newTestSyslog("udp://127.0.0.1:0")
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	client, err := net.Dial("udp", s.Closer.(net.PacketConn).LocalAddr().String())
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Write([]byte("<13>1 2017-05-03T10:00:00Z host app - - - first"))
+	client.Write([]byte("<13>May  3 11:00:00 host app: second\n"))
+
+	testSyslog(t, acc)
+}
+
+func TestSyslog_unix(t *testing.T) {
+	defer os.Remove("/tmp/telegraf_syslog_test.sock")
+	s := newTestSyslog("unix:///tmp/telegraf_syslog_test.sock")
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+
+	client, err := net.Dial("unix", "/tmp/telegraf_syslog_test.sock")
+	require.NoError(t, err)
+	defer client.Close()
+
+	client.Write([]byte("<13>1 2017-05-03T10:00:00Z host app - - - first\n"))
+	client.Write([]byte("<13>May  3 11:00:00 host app: second\n"))
+
+	testSyslog(t, acc)
+}
+
+func TestSyslog_unixConnections(t *testing.T) {
+	defer os.Remove("/tmp/telegraf_syslog_test.sock")
+	s := newTestSyslog("unix:///tmp/telegraf_syslog_test.sock")
+	s.MaxConnections = 2
+	acc := &testutil.Accumulator{}
+	require.NoError(t, s.Start(acc))
+	defer s.Stop()
+	ssl := s.Closer.(*streamSyslogListener)
+
+	connections := func() int {
+		ssl.connectionsMtx.Lock()
+		defer ssl.connectionsMtx.Unlock()
+		return len(ssl.connections)
+	}
+
+	// the peers of unix sockets all have the same empty address
+	var clients []net.Conn
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("unix", "/tmp/telegraf_syslog_test.sock")
+		require.NoError(t, err)
+		defer client.Close()
+		client.Write([]byte("<13>1 2017-05-03T10:00:00Z host app - - - msg\n"))
+		clients = append(clients, client)
+	}
+	acc.Lock()
+	for len(acc.Metrics) < 2 {
+		acc.Wait()
+	}
+	acc.Unlock()
+	assert.Equal(t, 2, connections())
+
+	clients[0].Close()
+	for i := 0; i < 100 && connections() != 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, connections())
+}
+
+func TestSyslog_structuredData(t *testing.T) {
+	s := newTestSyslog("udp://127.0.0.1:0")
+	acc := &testutil.Accumulator{}
+	s.Accumulator = acc
+
+	s.store([]byte(`<165>1 - host app 42 ID47 [origin ip="10.0.0.1"][flag] msg`))
+
+	require.Len(t, acc.Metrics, 1)
+	m := acc.Metrics[0]
+	assert.Equal(t, map[string]string{
+		"severity": "notice",
+		"facility": "local4",
+		"hostname": "host",
+		"appname":  "app",
+	}, m.Tags)
+	assert.Equal(t, map[string]interface{}{
+		"severity_code": 5,
+		"facility_code": 20,
+		"version":       1,
+		"procid":        "42",
+		"msgid":         "ID47",
+		"origin_ip":     "10.0.0.1",
+		"flag":          true,
+		"message":       "msg",
+	}, m.Fields)
+	assert.True(t, defaultTime.Equal(m.Time))
+}
+
+func testSyslog(t *testing.T, acc *testutil.Accumulator) {
+	acc.Lock()
+	for len(acc.Metrics) < 2 {
+		acc.Wait()
+	}
+	metrics := acc.Metrics
+	acc.Unlock()
+
+	assert.Equal(t, "syslog", metrics[0].Measurement)
+	assert.Equal(t, map[string]string{
+		"severity": "notice",
+		"facility": "user",
+		"hostname": "host",
+		"appname":  "app",
+	}, metrics[0].Tags)
+	assert.Equal(t, "first", metrics[0].Fields["message"])
+	assert.Equal(t, 1, metrics[0].Fields["version"])
+	assert.True(t, time.Date(2017, 5, 3, 10, 0, 0, 0, time.UTC).Equal(metrics[0].Time))
+
+	assert.Equal(t, "syslog", metrics[1].Measurement)
+	assert.Equal(t, "second", metrics[1].Fields["message"])
+	assert.Equal(t, "app", metrics[1].Tags["appname"])
+	assert.True(t, time.Date(2017, 5, 3, 11, 0, 0, 0, time.UTC).Equal(metrics[1].Time))
+}
@@ -3,12 +3,16 @@ package tail
 import (
 	"fmt"
 	"log"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/hpcloud/tail"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal/globpath"
+	"github.com/influxdata/telegraf/internal/multiline"
+	"github.com/influxdata/telegraf/internal/offsets"
 	"github.com/influxdata/telegraf/plugins/inputs"
 	"github.com/influxdata/telegraf/plugins/parsers"
 )
@@ -17,8 +21,13 @@ type Tail struct {
 	Files         []string
 	FromBeginning bool
 	Pipe          bool
+	OffsetsFile   string `toml:"offsets_file"`
 
-	tailers []*tail.Tail
+	Multiline *multiline.Config `toml:"multiline"`
+
+	files   []*tailedFile
+	done    chan struct{}
+	offsets *offsets.Store
 	parser  parsers.Parser
 	wg      sync.WaitGroup
 	acc     telegraf.Accumulator
@@ -47,12 +56,34 @@ const sampleConfig = `
   from_beginning = false
   ## Whether file is a named pipe
   pipe = false
+  ## File in which to save the read offsets of the tailed files, so that they
+  ## are resumed from where they were left on restart. When set, files that
+  ## have a saved offset are not read according to from_beginning.
+  ## Each plugin instance should use its own offsets file.
+  # offsets_file = "/var/lib/telegraf/tail.offsets"
 
   ## Data format to consume.
   ## Each data format has it's own unique set of configuration options, read
   ## more about them here:
   ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_INPUT.md
   data_format = "influx"
+
+  ## Join multi-line events, such as stack traces, into a single event before
+  ## parsing them. Lines of an event are joined with a newline.
+  # [inputs.tail.multiline]
+  #   ## Regular expression matching the start or the continuation lines of
+  #   ## an event.
+  #   pattern = '^\s'
+  #   ## Whether the lines matching the pattern are the "start" or the
+  #   ## "continuation" lines of an event.
+  #   match = "continuation"
+  #   ## Negate the pattern.
+  #   invert_match = false
+  #   ## Flush the current event when no new line arrived for this long.
+  #   timeout = "5s"
+  #   ## Maximum size of an event in bytes, lines are not joined past it.
+  #   ## Lines are never split, a longer line is an event on its own.
+  #   max_size = 65536
 `
 
 func (t *Tail) SampleConfig() string {
@@ -64,7 +95,10 @@ func (t *Tail) Description() string {
 }
 
 func (t *Tail) Gather(acc telegraf.Accumulator) error {
-	return nil
+	t.Lock()
+	defer t.Unlock()
+
+	return t.saveOffsets()
 }
 
 func (t *Tail) Start(acc telegraf.Accumulator) error {
@@ -72,6 +106,13 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 	defer t.Unlock()
 
 	t.acc = acc
+	t.done = make(chan struct{})
+
+	if t.Multiline != nil {
+		if err := t.Multiline.Compile(); err != nil {
+			return err
+		}
+	}
 
 	var seek *tail.SeekInfo
 	if !t.Pipe && !t.FromBeginning {
@@ -81,6 +122,14 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 		}
 	}
 
+	if t.OffsetsFile != "" && !t.Pipe {
+		t.offsets = offsets.NewStore(t.OffsetsFile)
+		if err := t.offsets.Load(); err != nil {
+			return fmt.Errorf("Error loading offsets file %s: %s",
+				t.OffsetsFile, err)
+		}
+	}
+
 	var errS string
 	// Create a "tailer" for each file
 	for _, filepath := range t.Files {
@@ -89,22 +138,32 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 			log.Printf("E! Error Glob %s failed to compile, %s", filepath, err)
 		}
 		for file, _ := range g.Match() {
-			tailer, err := tail.TailFile(file,
-				tail.Config{
-					ReOpen:    true,
-					Follow:    true,
-					Location:  seek,
-					MustExist: true,
-					Pipe:      t.Pipe,
-				})
-			if err != nil {
+			location := seek
+			var rotated string
+			var rotatedOffset int64
+			if t.offsets != nil {
+				if path, offset, ok := t.offsets.Rotated(file); ok {
+					// the rest of the file rotated while we were stopped is
+					// read first, then the new file from its beginning
+					rotated, rotatedOffset = path, offset
+					location = nil
+				} else if offset, ok := t.offsets.Get(file); ok {
+					location = &tail.SeekInfo{
+						Whence: 0,
+						Offset: offset,
+					}
+				}
+			}
+
+			f := &tailedFile{path: file}
+			if err := t.tailFile(f, location, true); err != nil {
 				errS += err.Error() + " "
 				continue
 			}
 			// create a goroutine for each "tailer"
 			t.wg.Add(1)
-			go t.receiver(tailer)
-			t.tailers = append(t.tailers, tailer)
+			go t.receiver(f, rotated, rotatedOffset)
+			t.files = append(t.files, f)
 		}
 	}
 
@@ -114,31 +173,216 @@ func (t *Tail) Start(acc telegraf.Accumulator) error {
 	return nil
 }
 
+// tailedFile is a file being tailed, along with the offsets of its lines.
+type tailedFile struct {
+	path string
+	pos  offsets.Position
+
+	sync.Mutex
+	tailer *tail.Tail
+}
+
+// tailFile starts tailing the file at f's path, from location or from its
+// beginning when nil, and counts its offsets from there. The tailers don't
+// reopen the files when they are rotated, the receivers do, so that the
+// offsets are counted from the beginning of the new file.
+// Assumes f's lock is held, or that f isn't shared yet!
+func (t *Tail) tailFile(f *tailedFile, location *tail.SeekInfo, mustExist bool) error {
+	info, err := os.Stat(f.path)
+	if err != nil && mustExist {
+		return err
+	}
+
+	var offset int64
+	if location != nil && info != nil && !t.Pipe {
+		offset = location.Offset
+		if location.Whence == 2 {
+			offset = info.Size()
+		}
+		// seek to the offset the lines are counted from
+		location = &tail.SeekInfo{Whence: 0, Offset: offset}
+	}
+
+	tailer, err := tail.TailFile(f.path,
+		tail.Config{
+			ReOpen:    false,
+			Follow:    true,
+			Location:  location,
+			MustExist: mustExist,
+			Pipe:      t.Pipe,
+		})
+	if err != nil {
+		return err
+	}
+	f.tailer = tailer
+	f.pos.Reset(info, offset)
+	return nil
+}
+
 // this is launched as a goroutine to continuously watch a tailed logfile
 // for changes, parse any incoming msgs, and add to the accumulator.
-func (t *Tail) receiver(tailer *tail.Tail) {
+// rotated is the file the path had before being rotated while we were
+// stopped, whose rest is read first.
+func (t *Tail) receiver(f *tailedFile, rotated string, offset int64) {
 	defer t.wg.Done()
 
-	var m telegraf.Metric
-	var err error
-	var line *tail.Line
-	for line = range tailer.Lines {
-		if line.Err != nil {
-			log.Printf("E! Error tailing file %s, Error: %s\n",
-				tailer.Filename, err)
-			continue
+	r := &lineReader{Tail: t, file: f}
+	var flush <-chan time.Time
+	if t.Multiline != nil {
+		r.mline = t.Multiline.New()
+		ticker := time.NewTicker(r.mline.FlushInterval())
+		defer ticker.Stop()
+		flush = ticker.C
+	}
+
+	f.Lock()
+	tailer := f.tailer
+	f.Unlock()
+
+	if rotated != "" && !r.readRotated(rotated, offset) {
+		return
+	}
+
+	for {
+		select {
+		case line, ok := <-tailer.Lines:
+			if !ok {
+				select {
+				case <-t.done:
+					// the lines of the event being joined are read again
+					// on restart when the offsets are saved
+					if t.offsets == nil {
+						r.flush()
+					}
+					return
+				default:
+				}
+				r.flush()
+				if err := tailer.Err(); err != nil {
+					log.Printf("E! Error tailing file %s, Error: %s\n",
+						tailer.Filename, err)
+					return
+				}
+				// the file was rotated or removed, follow the new file
+				// under its path once created
+				if tailer = t.reopen(f); tailer == nil {
+					return
+				}
+				continue
+			}
+			if line.Err != nil {
+				log.Printf("E! Error tailing file %s, Error: %s\n",
+					tailer.Filename, line.Err)
+				continue
+			}
+			r.add(line.Text)
+		case now := <-flush:
+			if text, ok := r.mline.FlushExpired(now); ok {
+				t.parseLine(f.path, text)
+				f.pos.Emit(r.info, r.end)
+			}
 		}
-		m, err = t.parser.ParseLine(line.Text)
-		if err == nil {
-			t.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
-		} else {
-			log.Printf("E! Malformed log line in %s: [%s], Error: %s\n",
-				tailer.Filename, line.Text, err)
+	}
+}
+
+// reopen tails the new file under f's path, unless the plugin is stopped.
+func (t *Tail) reopen(f *tailedFile) *tail.Tail {
+	f.Lock()
+	defer f.Unlock()
+
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+	f.tailer.Cleanup()
+	if err := t.tailFile(f, nil, false); err != nil {
+		log.Printf("E! Error tailing file %s, Error: %s\n", f.path, err)
+		return nil
+	}
+	return f.tailer
+}
+
+// lineReader parses the lines read from a file, joining them into events
+// when mline is set, and moves the offsets of the file past the lines
+// parsed.
+type lineReader struct {
+	*Tail
+	file  *tailedFile
+	mline *multiline.Multiline
+
+	// file and offset of the end of the last line read
+	info os.FileInfo
+	end  int64
+}
+
+func (r *lineReader) add(text string) {
+	var start int64
+	r.info, start, r.end = r.file.pos.Read(text)
+	if r.mline == nil {
+		r.parseLine(r.file.path, text)
+		r.file.pos.Emit(r.info, r.end)
+		return
+	}
+	// the line starts the next event when the current one is complete
+	if event, ok := r.mline.Add(text, time.Now()); ok {
+		r.parseLine(r.file.path, event)
+		r.file.pos.Emit(r.info, start)
+	}
+}
+
+// flush parses the event being joined, if any.
+func (r *lineReader) flush() {
+	if r.mline == nil {
+		return
+	}
+	if text, ok := r.mline.Flush(); ok {
+		r.parseLine(r.file.path, text)
+		r.file.pos.Emit(r.info, r.end)
+	}
+}
+
+// readRotated reads the rest of the file the path had before being rotated,
+// from the offset saved for it, before the new file is read from its
+// beginning. It returns false when the plugin was stopped meanwhile.
+func (r *lineReader) readRotated(rotated string, offset int64) bool {
+	current, _ := os.Stat(r.file.path)
+	info, err := os.Stat(rotated)
+	if err != nil {
+		log.Printf("E! Error reading rotated file %s, Error: %s\n", rotated, err)
+		return true
+	}
+	r.file.pos.Reset(info, offset)
+
+	stopped := false
+	err = offsets.ReadLines(rotated, offset, func(line string) bool {
+		select {
+		case <-r.done:
+			stopped = true
+			return false
+		default:
 		}
+		r.add(line)
+		return true
+	})
+	if err != nil {
+		log.Printf("E! Error reading rotated file %s, Error: %s\n", rotated, err)
 	}
-	if err := tailer.Err(); err != nil {
-		log.Printf("E! Error tailing file %s, Error: %s\n",
-			tailer.Filename, err)
+	if stopped {
+		return false
+	}
+	r.flush()
+	r.file.pos.Reset(current, 0)
+	return true
+}
+
+func (t *Tail) parseLine(filename string, text string) {
+	m, err := t.parser.ParseLine(text)
+	if err == nil {
+		t.acc.AddFields(m.Name(), m.Fields(), m.Tags(), m.Time())
+	} else {
+		log.Printf("E! Malformed log line in %s: [%s], Error: %s\n",
+			filename, text, err)
 	}
 }
 
@@ -146,14 +390,51 @@ func (t *Tail) Stop() {
 	t.Lock()
 	defer t.Unlock()
 
-	for _, t := range t.tailers {
-		err := t.Stop()
+	close(t.done)
+	for _, f := range t.files {
+		f.Lock()
+		err := f.tailer.Stop()
 		if err != nil {
-			log.Printf("E! Error stopping tail on file %s\n", t.Filename)
+			log.Printf("E! Error stopping tail on file %s\n", f.path)
 		}
-		t.Cleanup()
+		f.tailer.Cleanup()
+		f.Unlock()
 	}
 	t.wg.Wait()
+
+	// the offsets are those of the lines parsed until the receivers stopped
+	if t.offsets != nil {
+		t.recordOffsets()
+		if err := t.offsets.Save(); err != nil {
+			log.Printf("E! Error saving offsets file %s: %s\n", t.OffsetsFile, err)
+		}
+	}
+}
+
+// recordOffsets records the offset of the last line emitted from every
+// tailed file, so that the lines of an event being joined are read again.
+// Assumes t's lock is held!
+func (t *Tail) recordOffsets() {
+	if t.offsets == nil {
+		return
+	}
+
+	for _, f := range t.files {
+		f.Lock()
+		f.pos.Record(t.offsets, f.path, f.tailer.Tell)
+		f.Unlock()
+	}
+}
+
+// saveOffsets records and saves the offsets of every tailed file.
+// Assumes t's lock is held!
+func (t *Tail) saveOffsets() error {
+	if t.offsets == nil {
+		return nil
+	}
+
+	t.recordOffsets()
+	return t.offsets.Save()
 }
 
 func (t *Tail) SetParser(parser parsers.Parser) {
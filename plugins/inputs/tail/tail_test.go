@@ -3,9 +3,12 @@ package tail
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf/internal/multiline"
+	"github.com/influxdata/telegraf/internal/offsets"
 	"github.com/influxdata/telegraf/plugins/parsers"
 	"github.com/influxdata/telegraf/testutil"
 
@@ -100,3 +103,216 @@ func TestTailBadLine(t *testing.T) {
 
 	assert.Len(t, acc.Metrics, 0)
 }
+
+func TestTailResumeFromOffsetsFile(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	offsetsfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	offsetsfile.Close()
+	defer os.Remove(offsetsfile.Name())
+
+	_, err = tmpfile.WriteString("cpu,mytag=foo usage_idle=100\n")
+	require.NoError(t, err)
+
+	tt := NewTail()
+	tt.FromBeginning = true
+	tt.OffsetsFile = offsetsfile.Name()
+	tt.Files = []string{tmpfile.Name()}
+	p, _ := parsers.NewInfluxParser()
+	tt.SetParser(p)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	time.Sleep(time.Millisecond * 100)
+	require.NoError(t, tt.Gather(&acc))
+	tt.Stop()
+	assert.Len(t, acc.Metrics, 1)
+
+	// written while telegraf is not running
+	_, err = tmpfile.WriteString("cpu,othertag=foo usage_idle=100\n")
+	require.NoError(t, err)
+
+	tt = NewTail()
+	tt.FromBeginning = true
+	tt.OffsetsFile = offsetsfile.Name()
+	tt.Files = []string{tmpfile.Name()}
+	p, _ = parsers.NewInfluxParser()
+	tt.SetParser(p)
+	defer tt.Stop()
+
+	acc = testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	time.Sleep(time.Millisecond * 100)
+	require.NoError(t, tt.Gather(&acc))
+	time.Sleep(time.Millisecond * 50)
+
+	acc.AssertContainsTaggedFields(t, "cpu",
+		map[string]interface{}{
+			"usage_idle": float64(100),
+		},
+		map[string]string{
+			"othertag": "foo",
+		})
+	assert.Len(t, acc.Metrics, 1)
+}
+
+func TestTailMultiline(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	_, err = tmpfile.WriteString("{\n  \"usage_idle\": 100\n}\n")
+	require.NoError(t, err)
+
+	tt := NewTail()
+	tt.FromBeginning = true
+	tt.Files = []string{tmpfile.Name()}
+	tt.Multiline = &multiline.Config{Pattern: `^{`, Match: multiline.MatchStart}
+	tt.Multiline.Timeout.Duration = 50 * time.Millisecond
+	p, _ := parsers.NewJSONParser("cpu", nil, nil)
+	tt.SetParser(p)
+	defer tt.Stop()
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	// wait for the event to be flushed by the timeout
+	time.Sleep(time.Millisecond * 200)
+
+	acc.AssertContainsFields(t, "cpu",
+		map[string]interface{}{
+			"usage_idle": float64(100),
+		})
+}
+
+func TestTailResumeMultilineEvent(t *testing.T) {
+	tmpfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	defer tmpfile.Close()
+	offsetsfile, err := ioutil.TempFile("", "")
+	require.NoError(t, err)
+	offsetsfile.Close()
+	defer os.Remove(offsetsfile.Name())
+
+	_, err = tmpfile.WriteString("{\n  \"usage_idle\": 100\n}\n{\n  \"usage_idle\": 50\n")
+	require.NoError(t, err)
+
+	newTail := func(timeout time.Duration) *Tail {
+		tt := NewTail()
+		tt.FromBeginning = true
+		tt.OffsetsFile = offsetsfile.Name()
+		tt.Files = []string{tmpfile.Name()}
+		tt.Multiline = &multiline.Config{Pattern: `^{`, Match: multiline.MatchStart}
+		tt.Multiline.Timeout.Duration = timeout
+		p, _ := parsers.NewJSONParser("cpu", nil, nil)
+		tt.SetParser(p)
+		return tt
+	}
+
+	// the second event is still being joined when stopped
+	tt := newTail(time.Hour)
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	time.Sleep(time.Millisecond * 100)
+	tt.Stop()
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, float64(100), acc.Metrics[0].Fields["usage_idle"])
+
+	// written while telegraf is not running
+	_, err = tmpfile.WriteString("}\n")
+	require.NoError(t, err)
+
+	// the lines of the event are read again
+	tt = newTail(50 * time.Millisecond)
+	acc = testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	time.Sleep(time.Millisecond * 200)
+	tt.Stop()
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, float64(50), acc.Metrics[0].Fields["usage_idle"])
+}
+
+func TestTailResumeRotatedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logfile := filepath.Join(dir, "cpu.log")
+	offsetsfile := filepath.Join(dir, "offsets")
+
+	require.NoError(t, ioutil.WriteFile(logfile,
+		[]byte("cpu,line=1 usage_idle=100\n"), 0644))
+
+	newTail := func() *Tail {
+		tt := NewTail()
+		tt.OffsetsFile = offsetsfile
+		tt.Files = []string{logfile}
+		p, _ := parsers.NewInfluxParser()
+		tt.SetParser(p)
+		return tt
+	}
+
+	tt := newTail()
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	tt.Stop()
+
+	// written and rotated while telegraf is not running
+	f, err := os.OpenFile(logfile, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString("cpu,line=2 usage_idle=100\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Rename(logfile, logfile+".1"))
+	require.NoError(t, ioutil.WriteFile(logfile,
+		[]byte("cpu,line=3 usage_idle=100\n"), 0644))
+
+	// the rest of the rotated file is read, then the new file
+	tt = newTail()
+	acc = testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	time.Sleep(time.Millisecond * 100)
+	tt.Stop()
+
+	require.Len(t, acc.Metrics, 2)
+	assert.Equal(t, "2", acc.Metrics[0].Tags["line"])
+	assert.Equal(t, "3", acc.Metrics[1].Tags["line"])
+}
+
+func TestTailRotatedWhileRunning(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logfile := filepath.Join(dir, "cpu.log")
+
+	require.NoError(t, ioutil.WriteFile(logfile,
+		[]byte("cpu,line=1 usage_idle=100\n"), 0644))
+
+	tt := NewTail()
+	tt.FromBeginning = true
+	tt.OffsetsFile = filepath.Join(dir, "offsets")
+	tt.Files = []string{logfile}
+	p, _ := parsers.NewInfluxParser()
+	tt.SetParser(p)
+
+	acc := testutil.Accumulator{}
+	require.NoError(t, tt.Start(&acc))
+	time.Sleep(time.Millisecond * 100)
+
+	require.NoError(t, os.Rename(logfile, logfile+".1"))
+	require.NoError(t, ioutil.WriteFile(logfile,
+		[]byte("cpu,line=2 usage_idle=100\n"), 0644))
+	time.Sleep(time.Millisecond * 200)
+	tt.Stop()
+	require.Len(t, acc.Metrics, 2)
+	assert.Equal(t, "2", acc.Metrics[1].Tags["line"])
+
+	// the offset is counted from the beginning of the new file
+	store := offsets.NewStore(tt.OffsetsFile)
+	require.NoError(t, store.Load())
+	offset, ok := store.Get(logfile)
+	require.True(t, ok)
+	assert.Equal(t, int64(len("cpu,line=2 usage_idle=100\n")), offset)
+}
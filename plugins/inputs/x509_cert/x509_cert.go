@@ -0,0 +1,318 @@
+package x509_cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+const sampleConfig = `
+  ## List of certificate sources, either TLS endpoints or local PEM files and
+  ## directories. Endpoints without a port default to 443.
+  sources = ["https://example.org", "tcp://example.org:993", "/etc/ssl/certs/"]
+
+  ## Timeout for the TLS handshakes with endpoints.
+  # timeout = "5s"
+
+  ## Server name sent with SNI and used to verify the certificate of
+  ## endpoints, defaults to the host of each endpoint.
+  # server_name = ""
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Skip the verification of the certificate chains
+  # insecure_skip_verify = false
+`
+
+type X509Cert struct {
+	Sources    []string
+	Timeout    internal.Duration
+	ServerName string
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Skip the verification of the certificate chains
+	InsecureSkipVerify bool
+
+	tlsCfg *tls.Config
+}
+
+func (c *X509Cert) Description() string {
+	return "Reads metrics from the certificates of TLS endpoints and PEM files"
+}
+
+func (c *X509Cert) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *X509Cert) Gather(acc telegraf.Accumulator) error {
+	if c.tlsCfg == nil {
+		tlsCfg, err := internal.GetTLSConfig(c.SSLCert, c.SSLKey, c.SSLCA, false)
+		if err != nil {
+			return err
+		}
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{}
+		}
+		c.tlsCfg = tlsCfg
+	}
+
+	var wg sync.WaitGroup
+	for _, source := range c.Sources {
+		wg.Add(1)
+		go func(source string) {
+			defer wg.Done()
+			if err := c.gatherSource(acc, source); err != nil {
+				acc.AddError(fmt.Errorf("%s: %s", source, err))
+			}
+		}(source)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (c *X509Cert) gatherSource(acc telegraf.Accumulator, source string) error {
+	path := source
+	if strings.Contains(source, "://") {
+		u, err := url.Parse(source)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "file" {
+			certs, err := c.getRemoteCerts(u)
+			if err != nil {
+				return err
+			}
+			c.addCerts(acc, source, certs, c.serverName(u))
+			return nil
+		}
+		path = u.Path
+	} else if _, err := os.Stat(source); os.IsNotExist(err) {
+		if _, _, err := net.SplitHostPort(source); err == nil {
+			// host:port without a scheme
+			return c.gatherSource(acc, "tcp://"+source)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		certs, err := getFileCerts(path)
+		if err != nil {
+			return err
+		}
+		if len(certs) == 0 {
+			return fmt.Errorf("no certificate found")
+		}
+		c.addCerts(acc, path, certs, "")
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if !file.Mode().IsRegular() && file.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		name := filepath.Join(path, file.Name())
+		if file.Mode()&os.ModeSymlink != 0 && linksWithin(name, path) {
+			continue
+		}
+		// files without certificates, such as keys, are skipped
+		certs, err := getFileCerts(name)
+		if err != nil || len(certs) == 0 {
+			continue
+		}
+		c.addCerts(acc, name, certs, "")
+	}
+	return nil
+}
+
+// linksWithin returns whether the symlink targets a file of the directory,
+// which is reported under its own name, such as the hash links of
+// /etc/ssl/certs.
+func linksWithin(link, dir string) bool {
+	target, err := os.Readlink(link)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return filepath.Dir(target) == filepath.Clean(dir)
+}
+
+func (c *X509Cert) serverName(u *url.URL) string {
+	if c.ServerName != "" {
+		return c.ServerName
+	}
+	host := u.Host
+	if h, _, err := net.SplitHostPort(u.Host); err == nil {
+		host = h
+	}
+	return host
+}
+
+// getRemoteCerts returns the certificate chain presented by the endpoint.
+func (c *X509Cert) getRemoteCerts(u *url.URL) ([]*x509.Certificate, error) {
+	addr := u.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+
+	// The chain is verified on its own, so that the certificates of endpoints
+	// failing the verification are reported too.
+	tlsCfg := c.tlsCfg.Clone()
+	tlsCfg.ServerName = c.serverName(u)
+	tlsCfg.InsecureSkipVerify = true
+
+	dialer := &net.Dialer{Timeout: c.Timeout.Duration}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+// getFileCerts returns the certificates of a PEM file, in order.
+func getFileCerts(path string) ([]*x509.Certificate, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// verify verifies the first certificate of the chain, using the following
+// ones as intermediates.
+func (c *X509Cert) verify(certs []*x509.Certificate, dnsName string) error {
+	opts := x509.VerifyOptions{
+		DNSName:       dnsName,
+		Roots:         c.tlsCfg.RootCAs,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+func (c *X509Cert) addCerts(
+	acc telegraf.Accumulator,
+	source string,
+	certs []*x509.Certificate,
+	dnsName string,
+) {
+	now := time.Now()
+
+	var verifyErr error
+	verification := "skipped"
+	if !c.InsecureSkipVerify {
+		verifyErr = c.verify(certs, dnsName)
+		if verifyErr == nil {
+			verification = "valid"
+		} else {
+			verification = "invalid"
+		}
+	}
+
+	for i, cert := range certs {
+		tags := map[string]string{
+			"source":        source,
+			"common_name":   cert.Subject.CommonName,
+			"serial_number": cert.SerialNumber.Text(16),
+		}
+		if len(cert.Subject.Organization) > 0 {
+			tags["organization"] = cert.Subject.Organization[0]
+		}
+		if cert.Issuer.CommonName != "" {
+			tags["issuer_common_name"] = cert.Issuer.CommonName
+		}
+		if len(cert.Issuer.Organization) > 0 {
+			tags["issuer_organization"] = cert.Issuer.Organization[0]
+		}
+		if san := subjectAltNames(cert); san != "" {
+			tags["san"] = san
+		}
+
+		expiry := cert.NotAfter.Sub(now)
+		fields := map[string]interface{}{
+			"age":            int64(now.Sub(cert.NotBefore).Seconds()),
+			"expiry":         int64(expiry.Seconds()),
+			"days_remaining": int64(expiry.Hours() / 24),
+			"startdate":      cert.NotBefore.Unix(),
+			"enddate":        cert.NotAfter.Unix(),
+		}
+
+		// the verification applies to the first certificate of the chain
+		if i == 0 {
+			tags["verification"] = verification
+			if verifyErr != nil {
+				fields["verification_error"] = verifyErr.Error()
+			}
+		}
+
+		acc.AddFields("x509_cert", fields, tags)
+	}
+}
+
+// subjectAltNames returns the DNS names and IP addresses of the certificate,
+// comma separated.
+func subjectAltNames(cert *x509.Certificate) string {
+	names := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	return strings.Join(names, ",")
+}
+
+func init() {
+	inputs.Add("x509_cert", func() telegraf.Input {
+		return &X509Cert{
+			Timeout: internal.Duration{Duration: 5 * time.Second},
+		}
+	})
+}
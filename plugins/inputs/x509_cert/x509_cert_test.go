@@ -0,0 +1,247 @@
+package x509_cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testPKI struct {
+	dir      string
+	caFile   string
+	certFile string
+	keyFile  string
+	cert     tls.Certificate
+}
+
+// newTestPKI writes a CA, and a certificate for localhost issued by it, in a
+// temporary directory.
+func newTestPKI(t *testing.T) *testPKI {
+	dir, err := ioutil.TempDir("", "x509_cert")
+	require.NoError(t, err)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Telegraf Test CA", Organization: []string{"InfluxData"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+	ca, err := x509.ParseCertificate(caDER)
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0xbeef),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(10*24*time.Hour + time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	p := &testPKI{
+		dir:      dir,
+		caFile:   filepath.Join(dir, "ca.pem"),
+		certFile: filepath.Join(dir, "cert.pem"),
+		keyFile:  filepath.Join(dir, "key.pem"),
+	}
+	writePEM(t, p.caFile, "CERTIFICATE", caDER)
+	writePEM(t, p.certFile, "CERTIFICATE", der)
+	writePEM(t, p.keyFile, "EC PRIVATE KEY", keyDER)
+
+	p.cert, err = tls.LoadX509KeyPair(p.certFile, p.keyFile)
+	require.NoError(t, err)
+	return p
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, pem.Encode(f, &pem.Block{Type: typ, Bytes: der}))
+}
+
+func startTLSServer(t *testing.T, cert tls.Certificate) net.Listener {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return ln
+}
+
+func TestGatherRemote(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+	ln := startTLSServer(t, pki.cert)
+	defer ln.Close()
+
+	acc := &testutil.Accumulator{}
+	c := &X509Cert{
+		Sources: []string{"tcp://" + ln.Addr().String()},
+		SSLCA:   pki.caFile,
+	}
+	require.NoError(t, c.Gather(acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 1)
+
+	m := acc.Metrics[0]
+	assert.Equal(t, "x509_cert", m.Measurement)
+	assert.Equal(t, map[string]string{
+		"source":              "tcp://" + ln.Addr().String(),
+		"common_name":         "localhost",
+		"serial_number":       "beef",
+		"issuer_common_name":  "Telegraf Test CA",
+		"issuer_organization": "InfluxData",
+		"san":                 "localhost,127.0.0.1",
+		"verification":        "valid",
+	}, m.Tags)
+	assert.Equal(t, int64(10), m.Fields["days_remaining"])
+	assert.InDelta(t, 48*3600, m.Fields["age"], 60)
+	assert.InDelta(t, 241*3600, m.Fields["expiry"], 60)
+	assert.NotContains(t, m.Fields, "verification_error")
+}
+
+func TestGatherRemote_invalid(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+	ln := startTLSServer(t, pki.cert)
+	defer ln.Close()
+
+	// unknown authority
+	acc := &testutil.Accumulator{}
+	c := &X509Cert{Sources: []string{ln.Addr().String()}}
+	require.NoError(t, c.Gather(acc))
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, "invalid", acc.Metrics[0].Tags["verification"])
+	assert.Contains(t, acc.Metrics[0].Fields["verification_error"], "unknown authority")
+
+	// wrong server name
+	acc = &testutil.Accumulator{}
+	c = &X509Cert{
+		Sources:    []string{ln.Addr().String()},
+		ServerName: "example.org",
+		SSLCA:      pki.caFile,
+	}
+	require.NoError(t, c.Gather(acc))
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, "invalid", acc.Metrics[0].Tags["verification"])
+
+	acc = &testutil.Accumulator{}
+	c = &X509Cert{
+		Sources:            []string{ln.Addr().String()},
+		InsecureSkipVerify: true,
+	}
+	require.NoError(t, c.Gather(acc))
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, "skipped", acc.Metrics[0].Tags["verification"])
+}
+
+func TestGatherFiles(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+
+	// a chain file, followed by the certificates of the directory
+	chain := filepath.Join(pki.dir, "chain.pem")
+	leaf, err := ioutil.ReadFile(pki.certFile)
+	require.NoError(t, err)
+	ca, err := ioutil.ReadFile(pki.caFile)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(chain, append(leaf, ca...), 0644))
+
+	// links to the certificates of the directory are skipped, the others
+	// are followed
+	require.NoError(t, os.Symlink(filepath.Base(pki.caFile),
+		filepath.Join(pki.dir, "1a2b3c4d.0")))
+	require.NoError(t, os.Symlink(pki.certFile, filepath.Join(pki.dir, "5e6f7a8b.0")))
+	other, err := ioutil.TempDir("", "x509_cert")
+	require.NoError(t, err)
+	defer os.RemoveAll(other)
+	otherLink := filepath.Join(other, "ca.pem")
+	require.NoError(t, os.Symlink(pki.caFile, otherLink))
+
+	acc := &testutil.Accumulator{}
+	c := &X509Cert{Sources: []string{chain, "file://" + pki.dir, other}}
+	require.NoError(t, c.Gather(acc))
+	require.Empty(t, acc.Errors)
+
+	// chain.pem is read twice, key.pem is skipped
+	require.Len(t, acc.Metrics, 7)
+	count := map[string]int{}
+	for _, m := range acc.Metrics {
+		count[m.Tags["source"]]++
+	}
+	assert.Equal(t, map[string]int{
+		chain:        4,
+		pki.caFile:   1,
+		pki.certFile: 1,
+		otherLink:    1,
+	}, count)
+
+	// the CA is self-signed, the certificate can't be verified on its own
+	for _, m := range acc.Metrics {
+		switch m.Tags["source"] {
+		case pki.caFile:
+			assert.Equal(t, "invalid", m.Tags["verification"])
+		case pki.certFile:
+			assert.Equal(t, "invalid", m.Tags["verification"])
+		}
+	}
+
+	c = &X509Cert{Sources: []string{pki.certFile}, SSLCA: pki.caFile}
+	acc = &testutil.Accumulator{}
+	require.NoError(t, c.Gather(acc))
+	require.Len(t, acc.Metrics, 1)
+	assert.Equal(t, "valid", acc.Metrics[0].Tags["verification"])
+}
+
+func TestGatherErrors(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+
+	acc := &testutil.Accumulator{}
+	c := &X509Cert{Sources: []string{
+		pki.keyFile,
+		filepath.Join(pki.dir, "missing.pem"),
+		"tcp://127.0.0.1:1",
+	}}
+	require.NoError(t, c.Gather(acc))
+	assert.Empty(t, acc.Metrics)
+	assert.Len(t, acc.Errors, 3)
+}
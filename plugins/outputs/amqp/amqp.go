@@ -191,7 +191,7 @@ func (q *AMQP) Write(metrics []telegraf.Metric) error {
 	if len(metrics) == 0 {
 		return nil
 	}
-	outbuf := make(map[string][]byte)
+	batches := make(map[string][]telegraf.Metric)
 
 	for _, metric := range metrics {
 		var key string
@@ -201,16 +201,16 @@ func (q *AMQP) Write(metrics []telegraf.Metric) error {
 			}
 		}
 
-		buf, err := q.serializer.Serialize(metric)
+		batches[key] = append(batches[key], metric)
+	}
+
+	for key, batch := range batches {
+		buf, err := serializers.SerializeBatch(q.serializer, batch)
 		if err != nil {
 			return err
 		}
 
-		outbuf[key] = append(outbuf[key], buf...)
-	}
-
-	for key, buf := range outbuf {
-		err := q.channel.Publish(
+		err = q.channel.Publish(
 			q.Exchange, // exchange
 			key,        // routing key
 			false,      // mandatory
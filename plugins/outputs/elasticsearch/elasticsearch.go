@@ -0,0 +1,500 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/placeholder"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+var sampleConfig = `
+  ## The full HTTP endpoint URLs of your Elasticsearch nodes. The urls are
+  ## used in turn, the next one being tried when one is unavailable.
+  urls = ["http://localhost:9200"] # required
+
+  ## Timeout for HTTP requests.
+  # timeout = "5s"
+
+  ## HTTP Basic Auth credentials
+  # username = "telegraf"
+  # password = "mypassword"
+
+  ## Index to store the metrics in, formatted with the time of each metric:
+  ##   %Y - year (2016)
+  ##   %y - last two digits of year (00..99)
+  ##   %m - month (01..12)
+  ##   %d - day of month (e.g., 01)
+  ##   %H - hour (00..23)
+  ##   %V - week of the year (ISO week) (01..53)
+  ## and the value of its tags, such as {{host}}.
+  index_name = "telegraf-%Y.%m.%d" # required
+  ## Value used for the tags of the index name which the metric doesn't have.
+  # default_tag_value = "none"
+
+  ## Install an index template for the indexes on connection, mapping the
+  ## tags as keywords and the fields as numbers. An existing template isn't
+  ## overwritten unless overwrite_template is true.
+  # manage_template = true
+  # template_name = "telegraf"
+  # overwrite_template = false
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+`
+
+// retryLimit is the maximum number of rejected metrics kept to be retried.
+const retryLimit = 10000
+
+type Elasticsearch struct {
+	URLs              []string `toml:"urls"`
+	Timeout           internal.Duration
+	Username          string
+	Password          string
+	IndexName         string
+	DefaultTagValue   string
+	ManageTemplate    bool
+	TemplateName      string
+	OverwriteTemplate bool
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	client       *http.Client
+	majorVersion int
+	// index of the url the requests are sent to
+	current int
+	// metrics rejected by the last bulk request which can be retried
+	retry []telegraf.Metric
+}
+
+func (a *Elasticsearch) Connect() error {
+	if len(a.URLs) == 0 {
+		return fmt.Errorf("elasticsearch: at least one url is required")
+	}
+	if a.IndexName == "" {
+		return fmt.Errorf("elasticsearch: index_name is required")
+	}
+
+	tlsCfg, err := internal.GetTLSConfig(
+		a.SSLCert, a.SSLKey, a.SSLCA, a.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	a.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsCfg,
+		},
+		Timeout: a.Timeout.Duration,
+	}
+
+	version, err := a.version()
+	if err != nil {
+		return err
+	}
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return fmt.Errorf("elasticsearch: invalid version %q", version)
+	}
+	if major < 5 {
+		return fmt.Errorf("elasticsearch: version %s isn't supported, 5.x or later is required",
+			version)
+	}
+	a.majorVersion = major
+
+	if a.ManageTemplate {
+		return a.manageTemplate()
+	}
+	return nil
+}
+
+// version returns the version number of Elasticsearch.
+func (a *Elasticsearch) version() (string, error) {
+	body, err := a.request("GET", "/", "", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var info struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("elasticsearch: unable to decode the node info: %s", err)
+	}
+	return info.Version.Number, nil
+}
+
+func (a *Elasticsearch) manageTemplate() error {
+	if a.TemplateName == "" {
+		return fmt.Errorf("elasticsearch: template_name is required to manage the template")
+	}
+	path := "/_template/" + a.TemplateName
+
+	if !a.OverwriteTemplate {
+		_, err := a.request("HEAD", path, "", nil)
+		if err == nil {
+			log.Printf("I! Elasticsearch: template %s found, not overwriting it",
+				a.TemplateName)
+			return nil
+		}
+		if err, ok := err.(*statusError); !ok || err.code != http.StatusNotFound {
+			return err
+		}
+	}
+
+	tmpl, err := json.Marshal(a.template())
+	if err != nil {
+		return err
+	}
+	if _, err := a.request("PUT", path, "application/json", tmpl); err != nil {
+		return fmt.Errorf("elasticsearch: unable to create template %s: %s",
+			a.TemplateName, err)
+	}
+	log.Printf("I! Elasticsearch: template %s created", a.TemplateName)
+	return nil
+}
+
+// template returns the index template, which maps the tags as keywords and
+// the fields as numbers.
+func (a *Elasticsearch) template() map[string]interface{} {
+	mapping := map[string]interface{}{
+		"dynamic_templates": []interface{}{
+			map[string]interface{}{
+				"tags": map[string]interface{}{
+					"match_mapping_type": "string",
+					"path_match":         "tag.*",
+					"mapping": map[string]interface{}{
+						"ignore_above": 512,
+						"type":         "keyword",
+					},
+				},
+			},
+			map[string]interface{}{
+				"metrics_long": map[string]interface{}{
+					"match_mapping_type": "long",
+					"mapping": map[string]interface{}{
+						"type":  "float",
+						"index": false,
+					},
+				},
+			},
+			map[string]interface{}{
+				"metrics_double": map[string]interface{}{
+					"match_mapping_type": "double",
+					"mapping": map[string]interface{}{
+						"type":  "float",
+						"index": false,
+					},
+				},
+			},
+			map[string]interface{}{
+				"text_fields": map[string]interface{}{
+					"match": "*",
+					"mapping": map[string]interface{}{
+						"norms": false,
+					},
+				},
+			},
+		},
+		"properties": map[string]interface{}{
+			"@timestamp":       map[string]interface{}{"type": "date"},
+			"measurement_name": map[string]interface{}{"type": "keyword"},
+		},
+	}
+
+	tmpl := map[string]interface{}{
+		"order": 0,
+		"settings": map[string]interface{}{
+			"index": map[string]interface{}{
+				"refresh_interval":           "10s",
+				"mapping.total_fields.limit": 5000,
+			},
+		},
+	}
+
+	pattern := templatePattern(a.IndexName)
+	if a.majorVersion >= 6 {
+		tmpl["index_patterns"] = []string{pattern}
+	} else {
+		tmpl["template"] = pattern
+	}
+	// mapping types are gone as of 7.0
+	if a.majorVersion >= 7 {
+		tmpl["mappings"] = mapping
+	} else {
+		tmpl["mappings"] = map[string]interface{}{"metrics": mapping}
+	}
+	return tmpl
+}
+
+// templatePattern returns the pattern of the index names matching the index
+// name option, up to its first placeholder.
+func templatePattern(indexName string) string {
+	end := len(indexName)
+	if n := strings.Index(indexName, "%"); n >= 0 && n < end {
+		end = n
+	}
+	if n := strings.Index(indexName, "{{"); n >= 0 && n < end {
+		end = n
+	}
+	return strings.ToLower(indexName[:end]) + "*"
+}
+
+// indexName returns the index of the metric, formatting the placeholders of
+// the index name option with its time and tags.
+func (a *Elasticsearch) indexName(m telegraf.Metric) string {
+	t := m.Time().UTC()
+	_, week := t.ISOWeek()
+	name := strings.NewReplacer(
+		"%Y", t.Format("2006"),
+		"%y", t.Format("06"),
+		"%m", t.Format("01"),
+		"%d", t.Format("02"),
+		"%H", t.Format("15"),
+		"%V", fmt.Sprintf("%02d", week),
+	).Replace(a.IndexName)
+
+	name = placeholder.Replace(name, m, false, func(value string) string {
+		if value == "" {
+			return a.DefaultTagValue
+		}
+		return value
+	})
+
+	// index names are lowercase
+	return strings.ToLower(name)
+}
+
+// document returns the document of the metric, with its fields under its
+// name and its tags under "tag".
+func document(m telegraf.Metric) map[string]interface{} {
+	fields := make(map[string]interface{}, len(m.Fields()))
+	for k, v := range m.Fields() {
+		// NaN and infinity aren't valid JSON
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			continue
+		}
+		fields[k] = v
+	}
+
+	return map[string]interface{}{
+		"@timestamp":       m.Time().UTC(),
+		"measurement_name": m.Name(),
+		"tag":              m.Tags(),
+		m.Name():           fields,
+	}
+}
+
+type bulkResponse struct {
+	Errors bool                          `json:"errors"`
+	Items  []map[string]bulkResponseItem `json:"items"`
+}
+
+type bulkResponseItem struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// Write indexes the metrics with a bulk request. The documents rejected
+// because Elasticsearch is overloaded are retried with the next write, the
+// others are dropped.
+func (a *Elasticsearch) Write(metrics []telegraf.Metric) error {
+	batch := append(append([]telegraf.Metric(nil), a.retry...), metrics...)
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	var indexed []telegraf.Metric
+	for _, m := range batch {
+		meta := map[string]string{"_index": a.indexName(m)}
+		if a.majorVersion < 7 {
+			meta["_type"] = "metrics"
+		}
+		n := buf.Len()
+		if err := enc.Encode(map[string]interface{}{"index": meta}); err != nil {
+			buf.Truncate(n)
+			continue
+		}
+		if err := enc.Encode(document(m)); err != nil {
+			log.Printf("E! Elasticsearch: dropping metric %s: %s", m.Name(), err)
+			buf.Truncate(n)
+			continue
+		}
+		indexed = append(indexed, m)
+	}
+
+	body, err := a.request("POST", "/_bulk", "application/x-ndjson", buf.Bytes())
+	if err != nil {
+		// the metrics to retry are kept for the next write
+		return err
+	}
+
+	var resp bulkResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("elasticsearch: unable to decode the bulk response: %s", err)
+	}
+
+	var retry []telegraf.Metric
+	var dropped int
+	var dropErr string
+	if resp.Errors {
+		for n, item := range resp.Items {
+			if n >= len(indexed) {
+				break
+			}
+			for _, result := range item {
+				switch {
+				case result.Status >= 200 && result.Status < 300:
+				case result.Status == http.StatusTooManyRequests || result.Status >= 500:
+					retry = append(retry, indexed[n])
+				default:
+					dropped++
+					if dropErr == "" {
+						dropErr = string(result.Error)
+					}
+				}
+			}
+		}
+	}
+
+	if dropped > 0 {
+		log.Printf("E! Elasticsearch: dropping %d rejected metrics: %s", dropped, dropErr)
+	}
+	if len(retry) > retryLimit {
+		log.Printf("E! Elasticsearch: dropping %d rejected metrics over the retry limit",
+			len(retry)-retryLimit)
+		retry = retry[len(retry)-retryLimit:]
+	}
+	if len(retry) > 0 {
+		log.Printf("W! Elasticsearch: %d metrics rejected, retrying them with the next write",
+			len(retry))
+	}
+	a.retry = retry
+	return nil
+}
+
+// statusError is returned when Elasticsearch answers with an unsuccessful
+// status.
+type statusError struct {
+	code   int
+	status string
+	body   string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("elasticsearch: %s", e.status)
+	}
+	return fmt.Sprintf("elasticsearch: %s: %s", e.status, e.body)
+}
+
+// request sends the request to the current url, trying the next ones when it
+// is unavailable, and returns the body of the response.
+func (a *Elasticsearch) request(method, path, contentType string, body []byte) ([]byte, error) {
+	var err error
+	for n := 0; n < len(a.URLs); n++ {
+		url := strings.TrimRight(a.URLs[a.current], "/") + path
+
+		var resp []byte
+		resp, err = a.do(method, url, contentType, body)
+		if err == nil {
+			return resp, nil
+		}
+		if err, ok := err.(*statusError); ok && err.code < 500 {
+			return nil, err
+		}
+		log.Printf("E! Elasticsearch: %s %s: %s", method, url, err)
+		a.current = (a.current + 1) % len(a.URLs)
+	}
+	return nil, err
+}
+
+func (a *Elasticsearch) do(method, url, contentType string, body []byte) ([]byte, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if a.Username != "" || a.Password != "" {
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg := strings.TrimSpace(string(respBody))
+		if len(msg) > 512 {
+			msg = msg[:512]
+		}
+		return nil, &statusError{
+			code:   resp.StatusCode,
+			status: resp.Status,
+			body:   msg,
+		}
+	}
+	return respBody, nil
+}
+
+func (a *Elasticsearch) Close() error {
+	return nil
+}
+
+func (a *Elasticsearch) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Elasticsearch) Description() string {
+	return "Configuration for Elasticsearch to send metrics to."
+}
+
+func init() {
+	outputs.Add("elasticsearch", func() telegraf.Output {
+		return &Elasticsearch{
+			Timeout:         internal.Duration{Duration: 5 * time.Second},
+			DefaultTagValue: "none",
+			ManageTemplate:  true,
+			TemplateName:    "telegraf",
+		}
+	})
+}
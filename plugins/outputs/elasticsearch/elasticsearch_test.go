@@ -0,0 +1,248 @@
+package elasticsearch
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// server is a stand-in of the Elasticsearch API.
+type server struct {
+	*httptest.Server
+	version string
+
+	mu        sync.Mutex
+	template  map[string]interface{}
+	templates int
+	// status returned for the documents of each measurement, once
+	reject map[string]int
+	docs   []map[string]interface{}
+	meta   []map[string]map[string]string
+}
+
+func newServer(version string) *server {
+	s := &server{version: version, reject: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/":
+		fmt.Fprintf(w, `{"version":{"number":%q}}`, s.version)
+	case r.URL.Path == "/_template/telegraf" && r.Method == "HEAD":
+		if s.template == nil {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case r.URL.Path == "/_template/telegraf" && r.Method == "PUT":
+		s.templates++
+		json.NewDecoder(r.Body).Decode(&s.template)
+	case r.URL.Path == "/_bulk":
+		var items []string
+		errors := false
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var meta map[string]map[string]string
+			json.Unmarshal(scanner.Bytes(), &meta)
+			scanner.Scan()
+			var doc map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &doc)
+
+			name := doc["measurement_name"].(string)
+			if status, ok := s.reject[name]; ok {
+				delete(s.reject, name)
+				errors = true
+				items = append(items, fmt.Sprintf(
+					`{"index":{"status":%d,"error":{"type":"rejected"}}}`, status))
+				continue
+			}
+			s.meta = append(s.meta, meta)
+			s.docs = append(s.docs, doc)
+			items = append(items, `{"index":{"status":201}}`)
+		}
+		fmt.Fprintf(w, `{"errors":%t,"items":[`, errors)
+		for n, item := range items {
+			if n > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprint(w, item)
+		}
+		fmt.Fprint(w, "]}")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newElasticsearch(url string) *Elasticsearch {
+	return &Elasticsearch{
+		URLs:            []string{url},
+		Timeout:         internal.Duration{Duration: 5 * time.Second},
+		IndexName:       "telegraf-%Y.%m.%d",
+		DefaultTagValue: "none",
+		ManageTemplate:  true,
+		TemplateName:    "telegraf",
+	}
+}
+
+func TestConnect_template(t *testing.T) {
+	for _, version := range []string{"5.6.9", "6.2.4", "7.1.0"} {
+		s := newServer(version)
+
+		e := newElasticsearch(s.URL)
+		require.NoError(t, e.Connect(), version)
+		require.Equal(t, 1, s.templates, version)
+
+		mappings := s.template["mappings"].(map[string]interface{})
+		switch version {
+		case "5.6.9":
+			assert.Equal(t, "telegraf-*", s.template["template"])
+			assert.Contains(t, mappings, "metrics")
+		case "6.2.4":
+			assert.Equal(t, []interface{}{"telegraf-*"}, s.template["index_patterns"])
+			assert.Contains(t, mappings, "metrics")
+		case "7.1.0":
+			assert.Equal(t, []interface{}{"telegraf-*"}, s.template["index_patterns"])
+			assert.Contains(t, mappings, "dynamic_templates")
+		}
+
+		// an existing template is kept unless overwritten
+		require.NoError(t, e.Connect())
+		assert.Equal(t, 1, s.templates)
+		e.OverwriteTemplate = true
+		require.NoError(t, e.Connect())
+		assert.Equal(t, 2, s.templates)
+
+		s.Close()
+	}
+}
+
+func TestConnect_errors(t *testing.T) {
+	s := newServer("2.4.6")
+	defer s.Close()
+
+	assert.Error(t, (&Elasticsearch{IndexName: "telegraf"}).Connect())
+	assert.Error(t, (&Elasticsearch{URLs: []string{s.URL}}).Connect())
+	// unsupported version
+	assert.Error(t, newElasticsearch(s.URL).Connect())
+}
+
+func TestIndexName(t *testing.T) {
+	m, _ := metric.New("cpu",
+		map[string]string{"host": "Server01", "empty": ""},
+		map[string]interface{}{"value": 1.0},
+		time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC))
+
+	tests := []struct {
+		indexName string
+		expected  string
+	}{
+		{"telegraf", "telegraf"},
+		{"telegraf-%Y.%m.%d", "telegraf-2018.01.02"},
+		{"telegraf-%y-%m-%d-%H", "telegraf-18-01-02-15"},
+		{"telegraf-%Y.%V", "telegraf-2018.01"},
+		{"telegraf-{{host}}-%Y", "telegraf-server01-2018"},
+		{"telegraf-{{ empty }}-{{missing}}", "telegraf-none-none"},
+	}
+	for _, tt := range tests {
+		e := &Elasticsearch{IndexName: tt.indexName, DefaultTagValue: "none"}
+		assert.Equal(t, tt.expected, e.indexName(m), tt.indexName)
+	}
+}
+
+func TestTemplatePattern(t *testing.T) {
+	assert.Equal(t, "telegraf-*", templatePattern("telegraf-%Y.%m.%d"))
+	assert.Equal(t, "telegraf-*", templatePattern("telegraf-{{host}}-%Y"))
+	assert.Equal(t, "telegraf*", templatePattern("Telegraf"))
+}
+
+func TestWrite(t *testing.T) {
+	s := newServer("6.2.4")
+	defer s.Close()
+
+	e := newElasticsearch(s.URL)
+	e.ManageTemplate = false
+	require.NoError(t, e.Connect())
+
+	now := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	m, _ := metric.New("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"idle": 90.5, "count": int64(2)},
+		now)
+	require.NoError(t, e.Write([]telegraf.Metric{m}))
+
+	require.Len(t, s.docs, 1)
+	assert.Equal(t, map[string]string{
+		"_index": "telegraf-2018.01.02",
+		"_type":  "metrics",
+	}, s.meta[0]["index"])
+	assert.Equal(t, map[string]interface{}{
+		"@timestamp":       "2018-01-02T15:04:05Z",
+		"measurement_name": "cpu",
+		"tag":              map[string]interface{}{"host": "a"},
+		"cpu":              map[string]interface{}{"idle": 90.5, "count": 2.0},
+	}, s.docs[0])
+}
+
+func TestWrite_partialFailure(t *testing.T) {
+	s := newServer("7.1.0")
+	defer s.Close()
+
+	e := newElasticsearch(s.URL)
+	require.NoError(t, e.Connect())
+
+	now := time.Now()
+	var metrics []telegraf.Metric
+	for _, name := range []string{"ok", "overloaded", "invalid"} {
+		m, _ := metric.New(name, nil, map[string]interface{}{"value": 1.0}, now)
+		metrics = append(metrics, m)
+	}
+	s.reject["overloaded"] = http.StatusTooManyRequests
+	s.reject["invalid"] = http.StatusBadRequest
+	require.NoError(t, e.Write(metrics))
+	require.Len(t, s.docs, 1)
+	assert.Equal(t, "ok", s.docs[0]["measurement_name"])
+	assert.NotContains(t, s.meta[0]["index"], "_type")
+
+	// only the overloaded document is retried
+	m, _ := metric.New("next", nil, map[string]interface{}{"value": 1.0}, now)
+	require.NoError(t, e.Write([]telegraf.Metric{m}))
+	require.Len(t, s.docs, 3)
+	assert.Equal(t, "overloaded", s.docs[1]["measurement_name"])
+	assert.Equal(t, "next", s.docs[2]["measurement_name"])
+	assert.Empty(t, e.retry)
+}
+
+func TestWrite_failover(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	s := newServer("6.2.4")
+	defer s.Close()
+
+	e := newElasticsearch(unavailable.URL)
+	e.URLs = append(e.URLs, s.URL)
+	require.NoError(t, e.Connect())
+
+	m, _ := metric.New("cpu", nil, map[string]interface{}{"value": 1.0}, time.Now())
+	require.NoError(t, e.Write([]telegraf.Metric{m}))
+	assert.Len(t, s.docs, 1)
+
+	// the write fails when no url is available
+	s.Close()
+	assert.Error(t, e.Write([]telegraf.Metric{m}))
+}
@@ -1,27 +1,67 @@
 package file
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/placeholder"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 )
 
 type File struct {
-	Files []string
-
-	writer  io.Writer
-	closers []io.Closer
+	Files               []string
+	RotationInterval    internal.Duration `toml:"rotation_interval"`
+	RotationMaxSize     int64             `toml:"rotation_max_size"`
+	RotationMaxArchives int               `toml:"rotation_max_archives"`
+	CompressArchives    bool              `toml:"compress_archives"`
+	DefaultTagValue     string            `toml:"default_tag_value"`
+
+	// writers by path, files with placeholders are opened on their first
+	// write and closed once idle
+	writers map[string]io.WriteCloser
+	// time of the last write to the files with placeholders, by path
+	lastWrites map[string]time.Time
+
+	// compression of the rotated files, done in the background one at a
+	// time
+	archiving sync.WaitGroup
+	archiveMu sync.Mutex
 
 	serializer serializers.Serializer
 }
 
 var sampleConfig = `
   ## Files to write to, "stdout" is a specially handled file.
+  ## File paths can contain placeholders replaced with the name of each
+  ## metric, {{measurement}}, and the value of its tags, such as {{host}}, to
+  ## write the metrics to different files.
   files = ["stdout", "/tmp/metrics.out"]
+  # files = ["/var/lib/telegraf/{{host}}/{{measurement}}.out"]
+
+  ## Value used for the tags of the file paths which the metric doesn't have.
+  # default_tag_value = "none"
+
+  ## Rotate the files when they are older than the interval, or when a write
+  ## would make them larger than the size in bytes. 0 disables either.
+  ## Rotated files are suffixed with the time of their rotation.
+  # rotation_interval = "0h"
+  # rotation_max_size = 0
+
+  ## Maximum number of rotated files kept per file, the oldest ones are
+  ## removed. 0 keeps all of them.
+  # rotation_max_archives = 5
+
+  ## Compress the rotated files with gzip.
+  # compress_archives = false
 
   ## Data format to output.
   ## Each data format has it's own unique set of configuration options, read
@@ -30,50 +70,65 @@ var sampleConfig = `
   data_format = "influx"
 `
 
+// idleTimeout is how long the files with placeholders stay open without
+// being written to.
+const idleTimeout = 5 * time.Minute
+
+// pathReplacer keeps the values of the placeholders within a path element.
+var pathReplacer = strings.NewReplacer("/", "_", `\`, "_")
+
 func (f *File) SetSerializer(serializer serializers.Serializer) {
 	f.serializer = serializer
 }
 
 func (f *File) Connect() error {
-	writers := []io.Writer{}
-
 	if len(f.Files) == 0 {
 		f.Files = []string{"stdout"}
 	}
 
+	f.writers = make(map[string]io.WriteCloser)
+	f.lastWrites = make(map[string]time.Time)
 	for _, file := range f.Files {
 		if file == "stdout" {
-			writers = append(writers, os.Stdout)
-			f.closers = append(f.closers, os.Stdout)
-		} else {
-			var of *os.File
-			var err error
-			if _, err := os.Stat(file); os.IsNotExist(err) {
-				of, err = os.Create(file)
-			} else {
-				of, err = os.OpenFile(file, os.O_APPEND|os.O_WRONLY, os.ModeAppend)
-			}
-
-			if err != nil {
-				return err
-			}
-			writers = append(writers, of)
-			f.closers = append(f.closers, of)
+			f.writers[file] = os.Stdout
+			continue
+		}
+		if placeholder.Has(file) {
+			continue
+		}
+		w := f.newWriter(file)
+		if err := w.open(); err != nil {
+			f.Close()
+			return err
 		}
+		f.writers[file] = w
 	}
-	f.writer = io.MultiWriter(writers...)
 	return nil
 }
 
+func (f *File) newWriter(path string) *rotatingFile {
+	return &rotatingFile{
+		path:        path,
+		interval:    f.RotationInterval.Duration,
+		maxSize:     f.RotationMaxSize,
+		maxArchives: f.RotationMaxArchives,
+		compress:    f.CompressArchives,
+		archiving:   &f.archiving,
+		archiveMu:   &f.archiveMu,
+	}
+}
+
 func (f *File) Close() error {
 	var errS string
-	for _, c := range f.closers {
-		if err := c.Close(); err != nil {
+	for _, w := range f.writers {
+		if err := w.Close(); err != nil {
 			errS += err.Error() + "\n"
 		}
 	}
+	f.writers = nil
+	f.archiving.Wait()
 	if errS != "" {
-		return fmt.Errorf(errS)
+		return errors.New(errS)
 	}
 	return nil
 }
@@ -91,21 +146,88 @@ func (f *File) Write(metrics []telegraf.Metric) error {
 		return nil
 	}
 
-	for _, metric := range metrics {
-		b, err := f.serializer.Serialize(metric)
-		if err != nil {
-			return fmt.Errorf("failed to serialize message: %s", err)
-		}
-		_, err = f.writer.Write(b)
-		if err != nil {
-			return fmt.Errorf("failed to write message: %s, %s", metric.Serialize(), err)
+	now := time.Now()
+	for _, file := range f.Files {
+		templated := file != "stdout" && placeholder.Has(file)
+		paths, batches := f.route(file, metrics)
+		for _, path := range paths {
+			b, err := serializers.SerializeBatch(f.serializer, batches[path])
+			if err != nil {
+				return fmt.Errorf("failed to serialize message: %s", err)
+			}
+
+			w, ok := f.writers[path]
+			if !ok {
+				w = f.newWriter(path)
+				f.writers[path] = w
+			}
+			if _, err := w.Write(b); err != nil {
+				return fmt.Errorf("failed to write message: %s", err)
+			}
+			if templated {
+				f.lastWrites[path] = now
+			}
 		}
 	}
+
+	f.closeIdle(now)
 	return nil
 }
 
+// closeIdle closes the files with placeholders which weren't written to for
+// the idle timeout, they are opened again on their next write.
+func (f *File) closeIdle(now time.Time) {
+	for path, last := range f.lastWrites {
+		if now.Sub(last) < idleTimeout {
+			continue
+		}
+		if err := f.writers[path].Close(); err != nil {
+			log.Printf("E! file: unable to close %s: %s", path, err)
+		}
+		delete(f.writers, path)
+		delete(f.lastWrites, path)
+	}
+}
+
+// route groups the metrics by the path they are written to, the paths are
+// returned in the order of their first metric.
+func (f *File) route(
+	file string,
+	metrics []telegraf.Metric,
+) ([]string, map[string][]telegraf.Metric) {
+	if file == "stdout" || !placeholder.Has(file) {
+		return []string{file}, map[string][]telegraf.Metric{file: metrics}
+	}
+
+	var paths []string
+	batches := make(map[string][]telegraf.Metric)
+	for _, m := range metrics {
+		path := f.path(file, m)
+		if _, ok := batches[path]; !ok {
+			paths = append(paths, path)
+		}
+		batches[path] = append(batches[path], m)
+	}
+	return paths, batches
+}
+
+// path replaces the placeholders of the file with the name and tags of the
+// metric.
+func (f *File) path(file string, m telegraf.Metric) string {
+	return placeholder.Replace(file, m, true, func(value string) string {
+		value = pathReplacer.Replace(value)
+		if value == "" || value == "." || value == ".." {
+			return f.DefaultTagValue
+		}
+		return value
+	})
+}
+
 func init() {
 	outputs.Add("file", func() telegraf.Output {
-		return &File{}
+		return &File{
+			DefaultTagValue:     "none",
+			RotationMaxArchives: 5,
+		}
 	})
 }
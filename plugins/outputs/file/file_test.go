@@ -2,14 +2,21 @@ package file
 
 import (
 	"bytes"
+	"compress/gzip"
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
 )
@@ -170,6 +177,194 @@ func TestFileStdout(t *testing.T) {
 	assert.Equal(t, expNewFile, out)
 }
 
+func TestFileTemplatedPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:           []string{filepath.Join(dir, "{{host}}", "{{ measurement }}.out")},
+		DefaultTagValue: "none",
+		serializer:      s,
+	}
+	require.NoError(t, f.Connect())
+	defer f.Close()
+
+	m1, _ := metric.New("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	m2, _ := metric.New("mem", map[string]string{"host": "a/b"},
+		map[string]interface{}{"value": 2.0}, time.Unix(0, 0))
+	m3, _ := metric.New("cpu", map[string]string{"host": ".."},
+		map[string]interface{}{"value": 3.0}, time.Unix(0, 0))
+	m4, _ := metric.New("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 4.0}, time.Unix(0, 0))
+	require.NoError(t, f.Write([]telegraf.Metric{m1, m2, m3, m4}))
+
+	validateFile(filepath.Join(dir, "a", "cpu.out"),
+		"cpu,host=a value=1 0\ncpu,host=a value=4 0\n", t)
+	validateFile(filepath.Join(dir, "a_b", "mem.out"), "mem,host=a/b value=2 0\n", t)
+	validateFile(filepath.Join(dir, "none", "cpu.out"), "cpu,host=.. value=3 0\n", t)
+}
+
+func TestFileTemplatedPathsIdle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	f := File{
+		Files:           []string{filepath.Join(dir, "{{host}}.out")},
+		DefaultTagValue: "none",
+		serializer:      s,
+	}
+	require.NoError(t, f.Connect())
+	defer f.Close()
+
+	m1, _ := metric.New("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	m2, _ := metric.New("cpu", map[string]string{"host": "b"},
+		map[string]interface{}{"value": 2.0}, time.Unix(0, 0))
+	require.NoError(t, f.Write([]telegraf.Metric{m1}))
+	require.Len(t, f.writers, 1)
+
+	// the file of host a is closed once idle
+	pathA := filepath.Join(dir, "a.out")
+	f.lastWrites[pathA] = time.Now().Add(-idleTimeout)
+	require.NoError(t, f.Write([]telegraf.Metric{m2}))
+	assert.Len(t, f.writers, 1)
+	assert.NotContains(t, f.writers, pathA)
+
+	// and opened again by the next write
+	require.NoError(t, f.Write([]telegraf.Metric{m1}))
+	assert.Len(t, f.writers, 2)
+	validateFile(pathA, "cpu,host=a value=1 0\ncpu,host=a value=1 0\n", t)
+}
+
+func TestFileRotationSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	path := filepath.Join(dir, "metrics.out")
+	f := File{
+		Files:               []string{path},
+		RotationMaxSize:     int64(len(expNewFile)) + 1,
+		RotationMaxArchives: 2,
+		CompressArchives:    true,
+		serializer:          s,
+	}
+	require.NoError(t, f.Connect())
+	defer f.Close()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, f.Write(testutil.MockMetrics()))
+	}
+	validateFile(path, expNewFile, t)
+	// the archives are compressed in the background
+	f.archiving.Wait()
+
+	// 3 rotations, the oldest archive is removed
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	// sorted by name, the current file first
+	for _, file := range files[1:] {
+		assert.True(t, strings.HasPrefix(file.Name(), "metrics.out."))
+		assert.True(t, strings.HasSuffix(file.Name(), ".gz"))
+
+		gzf, err := os.Open(filepath.Join(dir, file.Name()))
+		require.NoError(t, err)
+		gz, err := gzip.NewReader(gzf)
+		require.NoError(t, err)
+		buf, err := ioutil.ReadAll(gz)
+		require.NoError(t, err)
+		gzf.Close()
+		assert.Equal(t, expNewFile, string(buf))
+	}
+}
+
+func TestFileRotationInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	path := filepath.Join(dir, "metrics.out")
+	f := File{
+		Files:            []string{path},
+		RotationInterval: internal.Duration{Duration: time.Hour},
+		serializer:       s,
+	}
+	require.NoError(t, f.Connect())
+	defer f.Close()
+
+	require.NoError(t, f.Write(testutil.MockMetrics()))
+	require.NoError(t, f.Write(testutil.MockMetrics()))
+	validateFile(path, expNewFile+expNewFile, t)
+
+	f.writers[path].(*rotatingFile).started = time.Now().Add(-time.Hour)
+	require.NoError(t, f.Write(testutil.MockMetrics()))
+	validateFile(path, expNewFile, t)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	validateFile(filepath.Join(dir, files[1].Name()), expNewFile+expNewFile, t)
+}
+
+func TestFileRotationIntervalReopened(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, _ := serializers.NewInfluxSerializer()
+	path := filepath.Join(dir, "metrics.out")
+	require.NoError(t, ioutil.WriteFile(path, []byte(expNewFile), 0644))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	newFile := func() *File {
+		f := &File{
+			Files:            []string{path},
+			RotationInterval: internal.Duration{Duration: time.Hour},
+			serializer:       s,
+		}
+		require.NoError(t, f.Connect())
+		return f
+	}
+
+	// without archive, the interval counts from the modification time of
+	// the file
+	f := newFile()
+	require.NoError(t, f.Write(testutil.MockMetrics()))
+	require.NoError(t, f.Close())
+	validateFile(path, expNewFile, t)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	// and from the last rotation otherwise, whatever the modification time
+	archive := path + "." + old.UTC().Format(archiveTimeFormat)
+	require.NoError(t, os.Rename(filepath.Join(dir, files[1].Name()), archive))
+	now := time.Now()
+	require.NoError(t, os.Chtimes(path, now, now))
+	f = newFile()
+	require.NoError(t, f.Write(testutil.MockMetrics()))
+	require.NoError(t, f.Close())
+	validateFile(path, expNewFile, t)
+	files, err = ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+
+	// which isn't due yet after it
+	f = newFile()
+	require.NoError(t, f.Write(testutil.MockMetrics()))
+	require.NoError(t, f.Close())
+	validateFile(path, expNewFile+expNewFile, t)
+}
+
 func createFile() *os.File {
 	f, err := ioutil.TempFile("", "")
 	if err != nil {
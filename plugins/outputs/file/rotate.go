@@ -0,0 +1,221 @@
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveTimeFormat is the format of the time suffix of the rotated files, it
+// sorts in chronological order.
+const archiveTimeFormat = "20060102T150405.000000000"
+
+// rotatingFile is a file which is rotated when it is older than the interval
+// or would grow larger than the max size, 0 disabling either.
+type rotatingFile struct {
+	path        string
+	interval    time.Duration
+	maxSize     int64
+	maxArchives int
+	compress    bool
+
+	// the rotated files are compressed in the background, archiveMu
+	// serializing the compression of the files of the output
+	archiving *sync.WaitGroup
+	archiveMu *sync.Mutex
+
+	file *os.File
+	size int64
+	// start of the current file, from which the interval counts
+	started time.Time
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.started = time.Now()
+	if r.interval > 0 {
+		r.started = r.start(info)
+	}
+	return nil
+}
+
+// start returns when the current file was started, for the interval not to
+// restart whenever the file is reopened, such as on restarts: the time of
+// the last rotation, or the modification time of the file when it wasn't
+// rotated yet.
+func (r *rotatingFile) start(info os.FileInfo) time.Time {
+	archives, err := r.archives()
+	if err == nil && len(archives) > 0 {
+		last := filepath.Base(archives[len(archives)-1])
+		if t, ok := archiveTime(filepath.Base(r.path), last); ok {
+			return t
+		}
+	}
+	return info.ModTime()
+}
+
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	expired := r.interval > 0 && time.Since(r.started) >= r.interval
+	full := r.maxSize > 0 && r.size > 0 && r.size+int64(len(b)) > r.maxSize
+	if expired || full {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+// rotate renames the current file with a time suffix, removes the oldest
+// archives past the max count and opens a new file. When compressed, the
+// archives are removed in the background once the file is compressed, not to
+// delay the writes.
+func (r *rotatingFile) rotate() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+
+	archive := r.path + "." + time.Now().UTC().Format(archiveTimeFormat)
+	if err := os.Rename(r.path, archive); err != nil {
+		return err
+	}
+	if r.compress {
+		r.archiving.Add(1)
+		go r.compressArchive(archive)
+	} else if err := r.removeArchives(); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) compressArchive(archive string) {
+	defer r.archiving.Done()
+	r.archiveMu.Lock()
+	defer r.archiveMu.Unlock()
+
+	if err := compressFile(archive); err != nil {
+		log.Printf("E! file: unable to compress %s: %s", archive, err)
+	}
+	if err := r.removeArchives(); err != nil {
+		log.Printf("E! file: unable to remove the archives of %s: %s", r.path, err)
+	}
+}
+
+// archives returns the rotated files of the path, oldest first.
+func (r *rotatingFile) archives() ([]string, error) {
+	dir, base := filepath.Split(r.path)
+	files, err := ioutil.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var archives []string
+	for _, file := range files {
+		name := file.Name()
+		if _, ok := archiveTime(base, name); !ok {
+			continue
+		}
+		archives = append(archives, filepath.Join(dir, name))
+	}
+	sort.Strings(archives)
+	return archives, nil
+}
+
+// archiveTime returns the time of the rotation of an archive of the base
+// name, ok being false for the files which aren't.
+func archiveTime(base, name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, base+".") {
+		return time.Time{}, false
+	}
+	suffix := strings.TrimSuffix(name[len(base)+1:], ".gz")
+	t, err := time.Parse(archiveTimeFormat, suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func (r *rotatingFile) removeArchives() error {
+	if r.maxArchives <= 0 {
+		return nil
+	}
+	archives, err := r.archives()
+	if err != nil {
+		return err
+	}
+	for len(archives) > r.maxArchives {
+		if err := os.Remove(archives[0]); err != nil {
+			return err
+		}
+		archives = archives[1:]
+	}
+	return nil
+}
+
+// compressFile replaces the file with its gzipped copy.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Remove(path)
+}
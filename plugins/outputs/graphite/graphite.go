@@ -15,11 +15,12 @@ import (
 
 type Graphite struct {
 	// URL is only for backwards compatability
-	Servers  []string
-	Prefix   string
-	Template string
-	Timeout  int
-	conns    []net.Conn
+	Servers    []string
+	Prefix     string
+	Template   string
+	TagSupport bool `toml:"graphite_tag_support"`
+	Timeout    int
+	conns      []net.Conn
 }
 
 var sampleConfig = `
@@ -32,6 +33,9 @@ var sampleConfig = `
   ## Graphite output template
   ## see https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
   template = "host.tags.measurement.field"
+  ## Enable Graphite tags support, sending metrics as tagged series
+  ## (measurement.field;tag1=value1;tag2=value2) instead of using the template
+  # graphite_tag_support = false
   ## timeout in seconds for the write connection to graphite
   timeout = 2
 `
@@ -102,7 +106,7 @@ func checkEOF(conn net.Conn) {
 func (g *Graphite) Write(metrics []telegraf.Metric) error {
 	// Prepare data
 	var batch []byte
-	s, err := serializers.NewGraphiteSerializer(g.Prefix, g.Template)
+	s, err := serializers.NewGraphiteSerializer(g.Prefix, g.Template, g.TagSupport)
 	if err != nil {
 		return err
 	}
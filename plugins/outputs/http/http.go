@@ -0,0 +1,255 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/serializers"
+)
+
+var sampleConfig = `
+  ## URL to send the metrics to.
+  url = "http://127.0.0.1:8080/metric"
+
+  ## HTTP method, one of: "POST" or "PUT"
+  # method = "POST"
+
+  ## Timeout for HTTP requests.
+  # timeout = "5s"
+
+  ## HTTP Basic Auth credentials
+  # username = "username"
+  # password = "pa$$word"
+
+  ## Use bearer token for authorization, read from this file on every request
+  # bearer_token = "/path/to/bearer/token"
+
+  ## Additional HTTP headers
+  # [outputs.http.headers]
+  #   # Should be set manually to "application/json" for json data_format
+  #   Content-Type = "text/plain; charset=utf-8"
+
+  ## HTTP Content-Encoding of the request body, either "identity" or "gzip".
+  # content_encoding = "identity"
+
+  ## Whether to keep and retry the batch when the server answers with a 4xx
+  ## client error or a 5xx server error. Batches which aren't retried are
+  ## dropped.
+  # retry_client_errors = false
+  # retry_server_errors = true
+
+  ## Optional SSL Config
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Data format to output.
+  ## Each data format has it's own unique set of configuration options, read
+  ## more about them here:
+  ## https://github.com/influxdata/telegraf/blob/master/docs/DATA_FORMATS_OUTPUT.md
+  data_format = "influx"
+`
+
+const defaultContentType = "text/plain; charset=utf-8"
+
+type HTTP struct {
+	URL             string `toml:"url"`
+	Method          string
+	Timeout         internal.Duration
+	Username        string
+	Password        string
+	BearerToken     string `toml:"bearer_token"`
+	Headers         map[string]string
+	ContentEncoding string
+
+	RetryClientErrors bool
+	RetryServerErrors bool
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	client     *http.Client
+	serializer serializers.Serializer
+}
+
+func (h *HTTP) SetSerializer(serializer serializers.Serializer) {
+	h.serializer = serializer
+}
+
+func (h *HTTP) Connect() error {
+	if h.URL == "" {
+		return fmt.Errorf("http: url is required")
+	}
+
+	switch h.Method = strings.ToUpper(h.Method); h.Method {
+	case "":
+		h.Method = "POST"
+	case "POST", "PUT":
+	default:
+		return fmt.Errorf("http: invalid method %q, must be POST or PUT", h.Method)
+	}
+
+	switch h.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return fmt.Errorf("http: invalid content_encoding %q", h.ContentEncoding)
+	}
+
+	tlsCfg, err := internal.GetTLSConfig(
+		h.SSLCert, h.SSLKey, h.SSLCA, h.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+
+	h.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsCfg,
+		},
+		Timeout: h.Timeout.Duration,
+	}
+	return nil
+}
+
+func (h *HTTP) Close() error {
+	return nil
+}
+
+func (h *HTTP) Description() string {
+	return "Send telegraf metrics to an HTTP endpoint"
+}
+
+func (h *HTTP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (h *HTTP) Write(metrics []telegraf.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	body, err := serializers.SerializeBatch(h.serializer, metrics)
+	if err != nil {
+		return err
+	}
+
+	if err := h.write(body); err != nil {
+		if err, ok := err.(*statusError); ok && !err.retryable {
+			// the batch is dropped, as sending it again won't help
+			log.Printf("E! http: dropping %d metrics: %s", len(metrics), err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// statusError is returned when the server answers with an unsuccessful status.
+type statusError struct {
+	status    string
+	body      string
+	retryable bool
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("http: %s", e.status)
+	}
+	return fmt.Sprintf("http: %s: %s", e.status, e.body)
+}
+
+func (h *HTTP) write(body []byte) error {
+	var reqBody io.Reader = bytes.NewReader(body)
+	if h.ContentEncoding == "gzip" {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		reqBody = &buf
+	}
+
+	req, err := http.NewRequest(h.Method, h.URL, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", defaultContentType)
+	if h.ContentEncoding == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range h.Headers {
+		if strings.ToLower(k) == "host" {
+			req.Host = v
+		}
+		req.Header.Set(k, v)
+	}
+
+	if h.Username != "" || h.Password != "" {
+		req.SetBasicAuth(h.Username, h.Password)
+	}
+	if h.BearerToken != "" {
+		token, err := ioutil.ReadFile(h.BearerToken)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+
+	// a bit of the body usually explains the error
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	serr := &statusError{
+		status:    resp.Status,
+		body:      strings.TrimSpace(string(msg)),
+		retryable: true,
+	}
+	switch {
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		serr.retryable = h.RetryClientErrors
+	case resp.StatusCode >= 500:
+		serr.retryable = h.RetryServerErrors
+	}
+	return serr
+}
+
+func init() {
+	outputs.Add("http", func() telegraf.Output {
+		return &HTTP{
+			Method:            "POST",
+			Timeout:           internal.Duration{Duration: 5 * time.Second},
+			RetryServerErrors: true,
+		}
+	})
+}
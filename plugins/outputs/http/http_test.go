@@ -0,0 +1,127 @@
+package http
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHTTP(url string) *HTTP {
+	return &HTTP{
+		URL:               url,
+		RetryServerErrors: true,
+		serializer:        &influx.InfluxSerializer{},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	h := newTestHTTP(ts.URL + "/metrics")
+	h.Method = "put"
+	h.Username = "user"
+	h.Password = "pass"
+	h.Headers = map[string]string{"X-Test": "yes"}
+	require.NoError(t, h.Connect())
+	require.NoError(t, h.Write(testutil.MockMetrics()))
+
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "/metrics", req.URL.Path)
+	assert.Equal(t, "text/plain; charset=utf-8", req.Header.Get("Content-Type"))
+	assert.Equal(t, "yes", req.Header.Get("X-Test"))
+	user, pass, ok := req.BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "pass", pass)
+	assert.Equal(t, "test1,tag1=value1 value=1 1257894000000000000\n", string(body))
+}
+
+func TestWrite_gzipBearer(t *testing.T) {
+	token, err := ioutil.TempFile("", "token")
+	require.NoError(t, err)
+	defer os.Remove(token.Name())
+	token.WriteString("secret\n")
+	token.Close()
+
+	var req *http.Request
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		gz, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+		body, _ = ioutil.ReadAll(gz)
+	}))
+	defer ts.Close()
+
+	h := newTestHTTP(ts.URL)
+	h.ContentEncoding = "gzip"
+	h.BearerToken = token.Name()
+	h.Headers = map[string]string{"Content-Type": "application/json"}
+	require.NoError(t, h.Connect())
+	require.NoError(t, h.Write(testutil.MockMetrics()))
+
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+	assert.Equal(t, "test1,tag1=value1 value=1 1257894000000000000\n", string(body))
+}
+
+func TestWrite_statusCodes(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte("something went wrong\n"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		status            int
+		retryClientErrors bool
+		retryServerErrors bool
+		err               bool
+	}{
+		{http.StatusOK, false, false, false},
+		{http.StatusBadRequest, false, true, false},
+		{http.StatusBadRequest, true, true, true},
+		{http.StatusServiceUnavailable, false, true, true},
+		{http.StatusServiceUnavailable, false, false, false},
+	}
+	for _, tt := range tests {
+		status = tt.status
+		h := newTestHTTP(ts.URL)
+		h.RetryClientErrors = tt.retryClientErrors
+		h.RetryServerErrors = tt.retryServerErrors
+		require.NoError(t, h.Connect())
+
+		err := h.Write(testutil.MockMetrics())
+		if tt.err {
+			require.Error(t, err, fmt.Sprint(tt.status))
+			assert.Contains(t, err.Error(), "something went wrong")
+		} else {
+			assert.NoError(t, err, fmt.Sprint(tt.status))
+		}
+	}
+}
+
+func TestConnect_invalid(t *testing.T) {
+	assert.Error(t, (&HTTP{}).Connect())
+	assert.Error(t, (&HTTP{URL: "http://localhost", Method: "GET"}).Connect())
+	assert.Error(t, (&HTTP{URL: "http://localhost", ContentEncoding: "zstd"}).Connect())
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -40,6 +41,21 @@ func NewHTTP(config HTTPConfig, defaultWP WriteParams) (Client, error) {
 		return nil, fmt.Errorf("config.URL scheme must be http(s), got %s", u.Scheme)
 	}
 
+	switch config.ContentEncoding {
+	case "", "identity", "gzip":
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %s", config.ContentEncoding)
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing config.HTTPProxy: %s", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
 	return &httpClient{
 		writeURL: writeURL(u, defaultWP),
 		config:   config,
@@ -47,6 +63,7 @@ func NewHTTP(config HTTPConfig, defaultWP WriteParams) (Client, error) {
 		client: &http.Client{
 			Timeout: config.Timeout,
 			Transport: &http.Transport{
+				Proxy:           proxy,
 				TLSClientConfig: config.TLSConfig,
 			},
 		},
@@ -77,9 +94,13 @@ type HTTPConfig struct {
 	// TLSConfig is the tls auth settings to use for each request.
 	TLSConfig *tls.Config
 
-	// Gzip, if true, compresses each payload using gzip.
-	// TODO
-	// Gzip bool
+	// HTTPProxy is the URL of the proxy to send the requests through. The
+	// proxy is read from the environment when empty.
+	HTTPProxy string
+
+	// ContentEncoding is the encoding of the write requests, either
+	// "identity" or "gzip". Payloads aren't compressed when empty.
+	ContentEncoding string
 }
 
 // Response represents a list of statement results.
@@ -211,18 +232,41 @@ func (c *httpClient) makeWriteRequest(
 	contentLength int,
 	writeURL string,
 ) (*http.Request, error) {
+	if c.config.ContentEncoding == "gzip" {
+		body = compress(body)
+	}
+
 	req, err := c.makeRequest(writeURL, body)
 	if err != nil {
+		if rc, ok := body.(io.Closer); ok {
+			rc.Close()
+		}
 		return nil, err
 	}
-	req.Header.Set("Content-Length", fmt.Sprint(contentLength))
-	// TODO
-	// if gzip {
-	// 	req.Header.Set("Content-Encoding", "gzip")
-	// }
+	if c.config.ContentEncoding == "gzip" {
+		// the length of the compressed payload isn't known in advance
+		req.Header.Set("Content-Encoding", "gzip")
+	} else {
+		req.Header.Set("Content-Length", fmt.Sprint(contentLength))
+	}
 	return req, nil
 }
 
+// compress returns a reader of the gzip compressed content of r, which must be
+// closed if it isn't read until the end.
+func compress(r io.Reader) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(pw)
+		_, err := io.Copy(gz, r)
+		if err == nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
 func (c *httpClient) makeRequest(uri string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest("POST", uri, body)
 	if err != nil {
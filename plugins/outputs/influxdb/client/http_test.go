@@ -2,7 +2,9 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -162,6 +164,57 @@ func TestHTTPClient_WriteParamsOverride(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHTTPClient_Write_Gzip(t *testing.T) {
+	var body []byte
+	var encoding string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ = ioutil.ReadAll(gz)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	config := HTTPConfig{
+		URL:             ts.URL,
+		ContentEncoding: "gzip",
+	}
+	client, err := NewHTTP(config, WriteParams{Database: "test"})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	n, err := client.WriteStream(bytes.NewReader([]byte("cpu value=99\n")), 13)
+	assert.NoError(t, err)
+	assert.Equal(t, 13, n)
+	assert.Equal(t, "gzip", encoding)
+	assert.Equal(t, "cpu value=99\n", string(body))
+}
+
+func TestHTTPClient_Proxy(t *testing.T) {
+	var host string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.URL.Host
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	config := HTTPConfig{
+		URL:       "http://influxdb.example.org:8086",
+		HTTPProxy: proxy.URL,
+	}
+	client, err := NewHTTP(config, WriteParams{Database: "test"})
+	assert.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Write([]byte("cpu value=99\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "influxdb.example.org:8086", host)
+}
+
 func TestHTTPClient_Write_Errors(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -236,6 +289,15 @@ func TestNewHTTPErrors(t *testing.T) {
 	assert.Nil(t, client)
 	assert.Error(t, err)
 
+	// Invalid content encoding:
+	config = HTTPConfig{
+		URL:             "http://localhost:8086",
+		ContentEncoding: "zstd",
+	}
+	client, err = NewHTTP(config, defaultWP)
+	assert.Nil(t, client)
+	assert.Error(t, err)
+
 	// Invalid URL scheme:
 	config = HTTPConfig{
 		URL: "mailto://localhost:8086",
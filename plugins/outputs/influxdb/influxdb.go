@@ -5,6 +5,7 @@ import (
 	"log"
 	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -26,7 +27,16 @@ type InfluxDB struct {
 	RetentionPolicy  string
 	WriteConsistency string
 	Timeout          internal.Duration
-	UDPPayload       int `toml:"udp_payload"`
+	UDPPayload       int    `toml:"udp_payload"`
+	HTTPProxy        string `toml:"http_proxy"`
+	ContentEncoding  string `toml:"content_encoding"`
+
+	// Tags whose value overrides the database or retention policy of the
+	// metrics they are set on.
+	DatabaseTag               string `toml:"database_tag"`
+	ExcludeDatabaseTag        bool   `toml:"exclude_database_tag"`
+	RetentionPolicyTag        string `toml:"retention_policy_tag"`
+	ExcludeRetentionPolicyTag bool   `toml:"exclude_retention_policy_tag"`
 
 	// Path to CA file
 	SSLCA string `toml:"ssl_ca"`
@@ -40,7 +50,16 @@ type InfluxDB struct {
 	// Precision is only here for legacy support. It will be ignored.
 	Precision string
 
+	// WriteMode is either "any", to write each batch to one of the urls, or
+	// "all", to write it to all of them.
+	WriteMode      string `toml:"write_mode"`
+	URLBufferLimit int    `toml:"url_buffer_limit"`
+
 	clients []client.Client
+	writers []*urlWriter
+
+	done chan struct{}
+	wg   sync.WaitGroup
 }
 
 var sampleConfig = `
@@ -49,11 +68,30 @@ var sampleConfig = `
   ## this means that only ONE of the urls will be written to each interval.
   # urls = ["udp://localhost:8089"] # UDP endpoint example
   urls = ["http://localhost:8086"] # required
+
+  ## Write mode of the urls, either "any" to write each interval to ONE of
+  ## the urls, or "all" to write to ALL of them concurrently.
+  # write_mode = "any"
+  ## With the "all" write mode, the maximum number of metrics kept for each
+  ## url while it can't be written to, the oldest metrics are dropped first.
+  # url_buffer_limit = 10000
+
   ## The target database for metrics (telegraf will create it if not exists).
   database = "telegraf" # required
 
+  ## Name of the tag whose value, when set, is the database to write the
+  ## metric to instead of "database". Optionally remove the tag from the
+  ## written metrics.
+  # database_tag = ""
+  # exclude_database_tag = false
+
   ## Retention policy to write to. Empty string writes to the default rp.
   retention_policy = ""
+  ## Name of the tag whose value, when set, is the retention policy to write
+  ## the metric to instead of "retention_policy". Optionally remove the tag
+  ## from the written metrics.
+  # retention_policy_tag = ""
+  # exclude_retention_policy_tag = false
   ## Write consistency (clusters only), can be: "any", "one", "quorum", "all"
   write_consistency = "any"
 
@@ -67,6 +105,13 @@ var sampleConfig = `
   ## Set UDP payload size, defaults to InfluxDB UDP Client default (512 bytes)
   # udp_payload = 512
 
+  ## HTTP proxy to write through, the proxy of the environment is used if
+  ## not set.
+  # http_proxy = "http://corporate.proxy:3128"
+
+  ## HTTP Content-Encoding of the writes, either "identity" or "gzip".
+  # content_encoding = "identity"
+
   ## Optional SSL Config
   # ssl_ca = "/etc/telegraf/ca.pem"
   # ssl_cert = "/etc/telegraf/cert.pem"
@@ -76,6 +121,12 @@ var sampleConfig = `
 `
 
 func (i *InfluxDB) Connect() error {
+	switch i.WriteMode {
+	case "", "any", "all":
+	default:
+		return fmt.Errorf("Invalid write_mode %q, must be \"any\" or \"all\"", i.WriteMode)
+	}
+
 	var urls []string
 	for _, u := range i.URLs {
 		urls = append(urls, u)
@@ -108,12 +159,14 @@ func (i *InfluxDB) Connect() error {
 		default:
 			// If URL doesn't start with "udp", assume HTTP client
 			config := client.HTTPConfig{
-				URL:       u,
-				Timeout:   i.Timeout.Duration,
-				TLSConfig: tlsConfig,
-				UserAgent: i.UserAgent,
-				Username:  i.Username,
-				Password:  i.Password,
+				URL:             u,
+				Timeout:         i.Timeout.Duration,
+				TLSConfig:       tlsConfig,
+				UserAgent:       i.UserAgent,
+				Username:        i.Username,
+				Password:        i.Password,
+				HTTPProxy:       i.HTTPProxy,
+				ContentEncoding: i.ContentEncoding,
 			}
 			wp := client.WriteParams{
 				Database:        i.Database,
@@ -126,7 +179,7 @@ func (i *InfluxDB) Connect() error {
 			}
 			i.clients = append(i.clients, c)
 
-			err = c.Query("CREATE DATABASE " + i.Database)
+			err = c.Query(createDatabase(i.Database))
 			if err != nil {
 				log.Println("E! Database creation failed: " + err.Error())
 				continue
@@ -135,10 +188,34 @@ func (i *InfluxDB) Connect() error {
 	}
 
 	rand.Seed(time.Now().UnixNano())
+
+	if i.WriteMode == "all" {
+		limit := i.URLBufferLimit
+		if limit <= 0 {
+			limit = defaultURLBufferLimit
+		}
+		i.done = make(chan struct{})
+		for n, c := range i.clients {
+			w := newURLWriter(urls[n], c, limit)
+			i.writers = append(i.writers, w)
+			i.wg.Add(1)
+			go func() {
+				defer i.wg.Done()
+				w.run(i, i.done)
+			}()
+		}
+	}
 	return nil
 }
 
+// Close waits for the pending metrics of the urls to be written once more,
+// with the "all" write mode.
 func (i *InfluxDB) Close() error {
+	if i.done != nil {
+		close(i.done)
+		i.wg.Wait()
+		i.done = nil
+	}
 	return nil
 }
 
@@ -150,46 +227,127 @@ func (i *InfluxDB) Description() string {
 	return "Configuration for influxdb server to send metrics to"
 }
 
-// Choose a random server in the cluster to write to until a successful write
-// occurs, logging each unsuccessful. If all servers fail, return error.
+// Write sends the metrics to the database and retention policy selected by
+// their tags, in a separate batch for each of them.
 func (i *InfluxDB) Write(metrics []telegraf.Metric) error {
-	bufsize := 0
+	if i.WriteMode == "all" {
+		// each url has its own buffer, so the write never fails
+		for _, w := range i.writers {
+			w.add(metrics)
+		}
+		return nil
+	}
+
+	// A failed batch fails the whole write, the batches which were written
+	// are then written again with the next one, overwriting the same points.
+	var err error
+	for wp, batch := range i.route(metrics) {
+		if e := i.writeBatch(wp, batch); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// route groups the metrics by the write parameters selected by their tags.
+func (i *InfluxDB) route(metrics []telegraf.Metric) map[client.WriteParams][]telegraf.Metric {
+	batches := make(map[client.WriteParams][]telegraf.Metric)
 	for _, m := range metrics {
-		bufsize += m.Len()
+		wp := client.WriteParams{
+			Database:        i.Database,
+			RetentionPolicy: i.RetentionPolicy,
+			Consistency:     i.WriteConsistency,
+		}
+		tags := m.Tags()
+		if db, ok := tags[i.DatabaseTag]; ok && i.DatabaseTag != "" && db != "" {
+			wp.Database = db
+		}
+		if rp, ok := tags[i.RetentionPolicyTag]; ok && i.RetentionPolicyTag != "" && rp != "" {
+			wp.RetentionPolicy = rp
+		}
+		batches[wp] = append(batches[wp], m)
+	}
+	return batches
+}
+
+// exclude returns the metrics without the routing tags which are excluded.
+func (i *InfluxDB) exclude(metrics []telegraf.Metric) []telegraf.Metric {
+	var keys []string
+	if i.ExcludeDatabaseTag {
+		keys = append(keys, i.DatabaseTag)
+	}
+	if i.ExcludeRetentionPolicyTag {
+		keys = append(keys, i.RetentionPolicyTag)
+	}
+	if len(keys) == 0 {
+		return metrics
 	}
-	r := metric.NewReader(metrics)
 
-	// This will get set to nil if a successful write occurs
-	err := fmt.Errorf("Could not write to any InfluxDB server in cluster")
+	out := make([]telegraf.Metric, len(metrics))
+	for n, m := range metrics {
+		out[n] = outputs.ExcludeTags(m, keys...)
+	}
+	return out
+}
 
+// Choose a random server in the cluster to write to until a successful write
+// occurs, logging each unsuccessful. If all servers fail, return error.
+func (i *InfluxDB) writeBatch(wp client.WriteParams, metrics []telegraf.Metric) error {
 	p := rand.Perm(len(i.clients))
 	for _, n := range p {
-		if _, e := i.clients[n].WriteStream(r, bufsize); e != nil {
-			// If the database was not found, try to recreate it:
-			if strings.Contains(e.Error(), "database not found") {
-				if errc := i.clients[n].Query("CREATE DATABASE  " + i.Database); errc != nil {
-					log.Printf("E! Error: Database %s not found and failed to recreate\n",
-						i.Database)
-				}
-			}
-			if strings.Contains(e.Error(), "field type conflict") {
-				log.Printf("E! Field type conflict, dropping conflicted points: %s", e)
-				// setting err to nil, otherwise we will keep retrying and points
-				// w/ conflicting types will get stuck in the buffer forever.
-				err = nil
-				break
-			}
+		if err := i.writeTo(i.clients[n], wp, metrics); err != nil {
 			// Log write failure
-			log.Printf("E! InfluxDB Output Error: %s", e)
+			log.Printf("E! InfluxDB Output Error: %s", err)
 		} else {
-			err = nil
-			break
+			return nil
 		}
 	}
 
+	return fmt.Errorf("Could not write to any InfluxDB server in cluster")
+}
+
+// writeTo writes the metrics with the client. Metrics with conflicting field
+// types are dropped rather than failing the write.
+func (i *InfluxDB) writeTo(
+	c client.Client,
+	wp client.WriteParams,
+	metrics []telegraf.Metric,
+) error {
+	metrics = i.exclude(metrics)
+	bufsize := 0
+	for _, m := range metrics {
+		bufsize += m.Len()
+	}
+
+	r := metric.NewReader(metrics)
+	_, err := c.WriteStreamWithParams(r, bufsize, wp)
+	if err == nil {
+		return nil
+	}
+
+	// If the database was not found, try to recreate it:
+	if strings.Contains(err.Error(), "database not found") {
+		if errc := c.Query(createDatabase(wp.Database)); errc != nil {
+			log.Printf("E! Error: Database %s not found and failed to recreate\n",
+				wp.Database)
+		}
+	}
+	if strings.Contains(err.Error(), "field type conflict") {
+		log.Printf("E! Field type conflict, dropping conflicted points: %s", err)
+		// returning nil, otherwise we will keep retrying and points
+		// w/ conflicting types will get stuck in the buffer forever.
+		return nil
+	}
 	return err
 }
 
+// createDatabase returns the query creating the database, quoting its name.
+func createDatabase(name string) string {
+	name = strings.Replace(name, `\`, `\\`, -1)
+	name = strings.Replace(name, `"`, `\"`, -1)
+	return `CREATE DATABASE "` + name + `"`
+}
+
 func newInflux() *InfluxDB {
 	return &InfluxDB{
 		Timeout: internal.Duration{Duration: time.Second * 5},
@@ -2,12 +2,18 @@ package influxdb
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -59,6 +65,152 @@ func TestHTTPInflux(t *testing.T) {
 	require.NoError(t, i.Close())
 }
 
+func TestHTTPInflux_RoutingTags(t *testing.T) {
+	var mu sync.Mutex
+	writes := make(map[string]string)
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/write":
+			body, _ := ioutil.ReadAll(r.Body)
+			key := r.FormValue("db") + "/" + r.FormValue("rp")
+			if key == "missing/" && len(queries) == 1 {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintln(w, `{"results":[{}],"error":"database not found: \"missing\""}`)
+				return
+			}
+			writes[key] += string(body)
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			queries = append(queries, r.FormValue("q"))
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprintln(w, `{"results":[{}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	i := newInflux()
+	i.URLs = []string{ts.URL}
+	i.Database = "telegraf"
+	i.RetentionPolicy = "default"
+	i.DatabaseTag = "database"
+	i.ExcludeDatabaseTag = true
+	i.RetentionPolicyTag = "rp"
+	require.NoError(t, i.Connect())
+
+	now := time.Unix(0, 0)
+	m1, _ := metric.New("cpu", map[string]string{"database": "team_a", "rp": "short"},
+		map[string]interface{}{"value": 1.0}, now)
+	m2, _ := metric.New("cpu", map[string]string{"database": "team_a"},
+		map[string]interface{}{"value": 2.0}, now)
+	m3, _ := metric.New("cpu", map[string]string{"host": "a"},
+		map[string]interface{}{"value": 3.0}, now)
+	require.NoError(t, i.Write([]telegraf.Metric{m1, m2, m3}))
+
+	assert.Equal(t, map[string]string{
+		"team_a/short":     "cpu,rp=short value=1 0\n",
+		"team_a/default":   "cpu value=2 0\n",
+		"telegraf/default": "cpu,host=a value=3 0\n",
+	}, writes)
+	// the written metrics are left untouched
+	assert.True(t, m1.HasTag("database"))
+
+	// databases selected by tags are created when not found
+	m4, _ := metric.New("cpu", map[string]string{"database": "missing"},
+		map[string]interface{}{"value": 4.0}, now)
+	i.RetentionPolicy = ""
+	require.Error(t, i.Write([]telegraf.Metric{m4}))
+	assert.Equal(t, []string{
+		`CREATE DATABASE "telegraf"`,
+		`CREATE DATABASE "missing"`,
+	}, queries)
+	require.NoError(t, i.Write([]telegraf.Metric{m4}))
+	assert.Equal(t, "cpu value=4 0\n", writes["missing/"])
+}
+
+func TestHTTPInflux_WriteModeAll(t *testing.T) {
+	var mu sync.Mutex
+	var bodyA, bodyB string
+	failB := true
+	attemptedB := make(chan struct{}, 10)
+	tsA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			body, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			bodyA += string(body)
+			mu.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer tsA.Close()
+	tsB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			mu.Lock()
+			defer mu.Unlock()
+			attemptedB <- struct{}{}
+			if failB {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprintln(w, `{"error":"unavailable"}`)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			bodyB += string(body)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer tsB.Close()
+
+	i := newInflux()
+	i.URLs = []string{tsA.URL, tsB.URL}
+	i.Database = "test"
+	i.WriteMode = "all"
+	require.NoError(t, i.Connect())
+
+	now := time.Unix(0, 0)
+	m1, _ := metric.New("cpu", nil, map[string]interface{}{"value": 1.0}, now)
+	m2, _ := metric.New("cpu", nil, map[string]interface{}{"value": 2.0}, now)
+
+	// the failure of a url doesn't fail the write
+	require.NoError(t, i.Write([]telegraf.Metric{m1}))
+	select {
+	case <-attemptedB:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no write attempt")
+	}
+
+	mu.Lock()
+	failB = false
+	mu.Unlock()
+	require.NoError(t, i.Write([]telegraf.Metric{m2}))
+	require.NoError(t, i.Close())
+
+	// the metrics which failed are written again to that url only
+	assert.Equal(t, "cpu value=1 0\ncpu value=2 0\n", bodyA)
+	assert.Equal(t, "cpu value=1 0\ncpu value=2 0\n", bodyB)
+}
+
+func TestURLWriter_Limit(t *testing.T) {
+	now := time.Unix(0, 0)
+	var metrics []telegraf.Metric
+	for n := 0; n < 3; n++ {
+		m, _ := metric.New("cpu", nil, map[string]interface{}{"value": n}, now)
+		metrics = append(metrics, m)
+	}
+
+	w := newURLWriter("http://localhost:8086", nil, 2)
+	w.add(metrics[:1])
+	w.add(metrics[1:])
+	assert.Equal(t, metrics[1:], w.metrics)
+}
+
+func TestHTTPInflux_InvalidWriteMode(t *testing.T) {
+	i := newInflux()
+	i.WriteMode = "some"
+	require.Error(t, i.Connect())
+}
+
 func TestUDPConnectError(t *testing.T) {
 	i := InfluxDB{
 		URLs: []string{"udp://foobar:8089"},
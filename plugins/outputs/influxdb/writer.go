@@ -0,0 +1,98 @@
+package influxdb
+
+import (
+	"log"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs/influxdb/client"
+)
+
+// defaultURLBufferLimit is the number of metrics kept for each url with the
+// "all" write mode, the same as the default metric_buffer_limit.
+const defaultURLBufferLimit = 10000
+
+// urlWriter writes metrics to a single url in the background, keeping the
+// metrics which couldn't be written until the next write.
+type urlWriter struct {
+	url    string
+	client client.Client
+	limit  int
+
+	mu      sync.Mutex
+	metrics []telegraf.Metric
+	// wake is signaled when metrics are added
+	wake chan struct{}
+}
+
+func newURLWriter(url string, c client.Client, limit int) *urlWriter {
+	return &urlWriter{
+		url:    url,
+		client: c,
+		limit:  limit,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// add adds metrics to the buffer and wakes up the writer.
+func (w *urlWriter) add(metrics []telegraf.Metric) {
+	w.mu.Lock()
+	w.metrics = append(w.metrics, metrics...)
+	w.trim()
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// trim drops the oldest metrics over the limit of the buffer.
+func (w *urlWriter) trim() {
+	if dropped := len(w.metrics) - w.limit; dropped > 0 {
+		log.Printf("E! InfluxDB Output Error [%s]: buffer full, dropping %d metrics",
+			w.url, dropped)
+		w.metrics = append([]telegraf.Metric(nil), w.metrics[dropped:]...)
+	}
+}
+
+// run writes the buffer each time metrics are added, and once more when done
+// is closed.
+func (w *urlWriter) run(i *InfluxDB, done <-chan struct{}) {
+	for {
+		select {
+		case <-w.wake:
+			w.write(i)
+		case <-done:
+			w.write(i)
+			return
+		}
+	}
+}
+
+func (w *urlWriter) write(i *InfluxDB) {
+	w.mu.Lock()
+	metrics := w.metrics
+	w.metrics = nil
+	w.mu.Unlock()
+
+	if len(metrics) == 0 {
+		return
+	}
+
+	var failed []telegraf.Metric
+	for wp, batch := range i.route(metrics) {
+		if err := i.writeTo(w.client, wp, batch); err != nil {
+			log.Printf("E! InfluxDB Output Error [%s]: %s", w.url, err)
+			failed = append(failed, batch...)
+		}
+	}
+
+	if len(failed) > 0 {
+		// the failed metrics go before the ones added in the meantime
+		w.mu.Lock()
+		w.metrics = append(failed, w.metrics...)
+		w.trim()
+		w.mu.Unlock()
+	}
+}
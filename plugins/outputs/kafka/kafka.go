@@ -3,9 +3,13 @@ package kafka
 import (
 	"crypto/tls"
 	"fmt"
+	"log"
+	"math/rand"
+	"sort"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/placeholder"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 
@@ -15,10 +19,24 @@ import (
 type Kafka struct {
 	// Kafka brokers to send metrics to
 	Brokers []string
-	// Kafka topic
+	// Kafka topic, used for the metrics without a topic tag
 	Topic string
+	// Tag whose value is the topic of the metric
+	TopicTag string `toml:"topic_tag"`
+	// Remove the topic tag from the metrics
+	ExcludeTopicTag bool `toml:"exclude_topic_tag"`
+	// Use the name of the metric as topic when it has no topic tag
+	TopicFromMeasurement bool `toml:"topic_from_measurement"`
 	// Routing Key Tag
 	RoutingTag string `toml:"routing_tag"`
+	// Routing key template, overrides the routing tag
+	RoutingKey string `toml:"routing_key"`
+	// Send a message per topic partition rather than per metric
+	Batch bool
+	// Headers of the messages
+	Headers map[string]string
+	// Maximum size of the messages, batches being split to stay below it
+	MaxMessageBytes int `toml:"max_message_bytes"`
 	// Compression Codec Tag
 	CompressionCodec int
 	// RequiredAcks Tag
@@ -45,19 +63,60 @@ type Kafka struct {
 	InsecureSkipVerify bool
 
 	tlsConfig tls.Config
+	client    sarama.Client
 	producer  sarama.SyncProducer
 
+	// partitions returns the partitions of the topic, it is replaced by
+	// tests.
+	partitions func(topic string) ([]int32, error)
+
 	serializer serializers.Serializer
 }
 
+const (
+	// default of the producer for the maximum size of the messages
+	defaultMaxMessageBytes = 1000000
+	// overheads of a message and of its headers counted by the producer
+	// against the maximum size of the messages
+	messageOverhead = 36
+	headerOverhead  = 10
+)
+
 var sampleConfig = `
   ## URLs of kafka brokers
   brokers = ["localhost:9092"]
   ## Kafka topic for producer messages
   topic = "telegraf"
+  ## Telegraf tag to use as topic
+  ##  ie, if this tag exists, it's value will be used as the topic, and
+  ##  the tag removed from the metric when exclude_topic_tag is true
+  # topic_tag = ""
+  # exclude_topic_tag = false
+  ## Use the measurement name as topic for the metrics without topic_tag,
+  ## rather than topic
+  # topic_from_measurement = false
+
   ## Telegraf tag to use as a routing key
   ##  ie, if this tag exists, it's value will be used as the routing key
   routing_tag = "host"
+  ## Routing key template, overriding routing_tag, with placeholders replaced
+  ## with the name of the metric, {{measurement}}, and the value of its tags,
+  ## such as {{host}}
+  # routing_key = "{{host}}-{{measurement}}"
+
+  ## Send the metrics of each write in a single message per topic
+  ## partition, the metrics being assigned to the partitions from their
+  ## routing key as when sent one per message
+  # batch = false
+
+  ## Maximum size of the messages in bytes, which should not be above the
+  ## message.max.bytes of the brokers. Batches are split into several
+  ## messages to stay below it, and metrics larger on their own are dropped.
+  # max_message_bytes = 1000000
+
+  ## Headers added to the messages, they require Kafka 0.11 or later
+  # [outputs.kafka.headers]
+  #   source = "telegraf"
 
   ## CompressionCodec represents the various compression codecs recognized by
   ## Kafka in messages.
@@ -129,16 +188,43 @@ func (k *Kafka) Connect() error {
 		config.Net.TLS.Enable = true
 	}
 
-	producer, err := sarama.NewSyncProducer(k.Brokers, config)
+	if k.MaxMessageBytes > 0 {
+		config.Producer.MaxMessageBytes = k.MaxMessageBytes
+	}
+	// record headers were introduced with the 0.11 message format
+	if len(k.Headers) > 0 && !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		config.Version = sarama.V0_11_0_0
+	}
+	// batches are assigned to partitions before they are sent
+	if k.Batch {
+		config.Producer.Partitioner = sarama.NewManualPartitioner
+	}
+
+	client, err := sarama.NewClient(k.Brokers, config)
 	if err != nil {
 		return err
 	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	k.client = client
 	k.producer = producer
+	k.partitions = client.Partitions
 	return nil
 }
 
 func (k *Kafka) Close() error {
-	return k.producer.Close()
+	if k.producer == nil {
+		return nil
+	}
+	err := k.producer.Close()
+	// the producer doesn't close the client it was created from
+	if cerr := k.client.Close(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
 func (k *Kafka) SampleConfig() string {
@@ -154,34 +240,237 @@ func (k *Kafka) Write(metrics []telegraf.Metric) error {
 		return nil
 	}
 
+	var msgs []*sarama.ProducerMessage
+	var err error
+	if k.Batch {
+		msgs, err = k.batchMessages(metrics)
+	} else {
+		msgs, err = k.messages(metrics)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		_, _, err := k.producer.SendMessage(m)
+		if err != nil {
+			return fmt.Errorf("FAILED to send kafka message: %s\n", err)
+		}
+	}
+	return nil
+}
+
+// messages returns a message per metric.
+func (k *Kafka) messages(metrics []telegraf.Metric) ([]*sarama.ProducerMessage, error) {
+	msgs := make([]*sarama.ProducerMessage, 0, len(metrics))
 	for _, metric := range metrics {
-		buf, err := k.serializer.Serialize(metric)
+		topic, key := k.topic(metric), k.key(metric)
+		buf, err := k.serializer.Serialize(k.exclude(metric))
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		m := k.newMessage(topic, key)
+		if k.tooLarge(m, buf) {
+			k.drop(metric, buf)
+			continue
 		}
+		m.Value = sarama.ByteEncoder(buf)
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+// batchMessages returns a message per topic partition, with the metrics of
+// the partition in order, or several when a single message would be larger
+// than the maximum size. The metrics with a key get the partition of the
+// hash partitioner, the same as they would when sent one per message, while
+// the ones without a key share a random partition of their topic.
+func (k *Kafka) batchMessages(metrics []telegraf.Metric) ([]*sarama.ProducerMessage, error) {
+	type batch struct {
+		msg     *sarama.ProducerMessage
+		metrics []telegraf.Metric
+	}
+	type topicPartition struct {
+		topic     string
+		partition int32
+	}
+
+	var batches []*batch
+	byPartition := make(map[topicPartition]*batch)
+	partitioners := make(map[string]sarama.Partitioner)
+	partitionCounts := make(map[string]int32)
+	keyless := make(map[string]int32)
+	for _, metric := range metrics {
+		topic, key := k.topic(metric), k.key(metric)
 
-		m := &sarama.ProducerMessage{
-			Topic: k.Topic,
-			Value: sarama.ByteEncoder(buf),
+		count, ok := partitionCounts[topic]
+		if !ok {
+			partitions, err := k.partitions(topic)
+			if err != nil {
+				return nil, err
+			}
+			if len(partitions) == 0 {
+				return nil, fmt.Errorf("no partition found for topic %q", topic)
+			}
+			count = int32(len(partitions))
+			partitionCounts[topic] = count
+			keyless[topic] = rand.Int31n(count)
 		}
-		if h, ok := metric.Tags()[k.RoutingTag]; ok {
-			m.Key = sarama.StringEncoder(h)
+
+		partition := keyless[topic]
+		if key != "" {
+			p, ok := partitioners[topic]
+			if !ok {
+				p = sarama.NewHashPartitioner(topic)
+				partitioners[topic] = p
+			}
+			var err error
+			partition, err = p.Partition(
+				&sarama.ProducerMessage{Key: sarama.StringEncoder(key)}, count)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		_, _, err = k.producer.SendMessage(m)
+		tp := topicPartition{topic, partition}
+		b, ok := byPartition[tp]
+		if !ok {
+			b = &batch{msg: k.newMessage(topic, key)}
+			b.msg.Partition = partition
+			byPartition[tp] = b
+			batches = append(batches, b)
+		} else if b.msg.Key != nil && string(b.msg.Key.(sarama.StringEncoder)) != key {
+			// the batch has a key only if its metrics have the same
+			b.msg.Key = nil
+		}
+		b.metrics = append(b.metrics, k.exclude(metric))
+	}
 
+	msgs := make([]*sarama.ProducerMessage, 0, len(batches))
+	for _, b := range batches {
+		values, err := k.batchValues(b.msg, b.metrics)
 		if err != nil {
-			return fmt.Errorf("FAILED to send kafka message: %s\n", err)
+			return nil, err
+		}
+		for _, value := range values {
+			m := *b.msg
+			m.Value = sarama.ByteEncoder(value)
+			msgs = append(msgs, &m)
 		}
 	}
-	return nil
+	return msgs, nil
+}
+
+// batchValues returns the values of the messages of a batch, the metrics
+// being split in halves until the messages are below the maximum size.
+func (k *Kafka) batchValues(m *sarama.ProducerMessage, metrics []telegraf.Metric) ([][]byte, error) {
+	buf, err := serializers.SerializeBatch(k.serializer, metrics)
+	if err != nil {
+		return nil, err
+	}
+	if !k.tooLarge(m, buf) {
+		return [][]byte{buf}, nil
+	}
+	if len(metrics) == 1 {
+		k.drop(metrics[0], buf)
+		return nil, nil
+	}
+
+	half := len(metrics) / 2
+	values, err := k.batchValues(m, metrics[:half])
+	if err != nil {
+		return nil, err
+	}
+	rest, err := k.batchValues(m, metrics[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(values, rest...), nil
+}
+
+// tooLarge returns whether the message with the value would be larger than
+// the maximum size, the producer failing to send it whatever the retries.
+func (k *Kafka) tooLarge(m *sarama.ProducerMessage, value []byte) bool {
+	max := k.MaxMessageBytes
+	if max <= 0 {
+		max = defaultMaxMessageBytes
+	}
+
+	size := messageOverhead + len(value)
+	if m.Key != nil {
+		size += m.Key.Length()
+	}
+	for _, h := range m.Headers {
+		size += len(h.Key) + len(h.Value) + headerOverhead
+	}
+	return size > max
+}
+
+// drop logs a metric which can't be sent, being larger than the maximum
+// size of the messages on its own.
+func (k *Kafka) drop(metric telegraf.Metric, value []byte) {
+	log.Printf("E! kafka: dropping metric %s of %d bytes, larger than the maximum message size",
+		metric.Name(), len(value))
+}
+
+func (k *Kafka) newMessage(topic, key string) *sarama.ProducerMessage {
+	m := &sarama.ProducerMessage{Topic: topic}
+	if key != "" {
+		m.Key = sarama.StringEncoder(key)
+	}
+
+	names := make([]string, 0, len(k.Headers))
+	for name := range k.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.Headers = append(m.Headers, sarama.RecordHeader{
+			Key:   []byte(name),
+			Value: []byte(k.Headers[name]),
+		})
+	}
+	return m
+}
+
+// topic returns the topic of the metric, from its topic tag or name when
+// configured, or else the topic option.
+func (k *Kafka) topic(metric telegraf.Metric) string {
+	if k.TopicTag != "" {
+		if topic, ok := metric.Tags()[k.TopicTag]; ok && topic != "" {
+			return topic
+		}
+	}
+	if k.TopicFromMeasurement {
+		return metric.Name()
+	}
+	return k.Topic
+}
+
+// key returns the routing key of the metric, an empty key leaving the
+// partition to the partitioner.
+func (k *Kafka) key(metric telegraf.Metric) string {
+	if k.RoutingKey == "" {
+		return metric.Tags()[k.RoutingTag]
+	}
+	return placeholder.Replace(k.RoutingKey, metric, true, nil)
+}
+
+// exclude returns the metric without its topic tag when it is excluded.
+func (k *Kafka) exclude(metric telegraf.Metric) telegraf.Metric {
+	if !k.ExcludeTopicTag {
+		return metric
+	}
+	return outputs.ExcludeTags(metric, k.TopicTag)
 }
 
 func init() {
 	outputs.Add("kafka", func() telegraf.Output {
 		return &Kafka{
-			MaxRetry:     3,
-			RequiredAcks: -1,
+			MaxRetry:        3,
+			RequiredAcks:    -1,
+			MaxMessageBytes: defaultMaxMessageBytes,
 		}
 	})
 }
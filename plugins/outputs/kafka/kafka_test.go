@@ -1,10 +1,16 @@
 package kafka
 
 import (
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Shopify/sarama"
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/plugins/serializers"
 	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -29,3 +35,208 @@ func TestConnectAndWrite(t *testing.T) {
 	err = k.Write(testutil.MockMetrics())
 	require.NoError(t, err)
 }
+
+type fakeProducer struct {
+	msgs []*sarama.ProducerMessage
+}
+
+func (p *fakeProducer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	p.msgs = append(p.msgs, msg)
+	return msg.Partition, int64(len(p.msgs)), nil
+}
+
+func (p *fakeProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	return nil
+}
+
+func newTestKafka() (*Kafka, *fakeProducer) {
+	s, _ := serializers.NewInfluxSerializer()
+	p := &fakeProducer{}
+	k := &Kafka{
+		Topic:      "telegraf",
+		serializer: s,
+		producer:   p,
+		partitions: func(topic string) ([]int32, error) {
+			return []int32{0, 1, 2, 3}, nil
+		},
+	}
+	return k, p
+}
+
+func newMetric(name string, tags map[string]string, value int64) telegraf.Metric {
+	m, _ := metric.New(name, tags, map[string]interface{}{"value": value}, time.Unix(0, 0))
+	return m
+}
+
+func value(t *testing.T, e sarama.Encoder) string {
+	if e == nil {
+		return ""
+	}
+	b, err := e.Encode()
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestWrite_topic(t *testing.T) {
+	metrics := []telegraf.Metric{
+		newMetric("cpu", map[string]string{"domain": "system", "host": "a"}, 1),
+		newMetric("cpu", map[string]string{"host": "a"}, 2),
+	}
+
+	k, p := newTestKafka()
+	k.TopicTag = "domain"
+	k.RoutingTag = "host"
+	require.NoError(t, k.Write(metrics))
+	require.Len(t, p.msgs, 2)
+	assert.Equal(t, "system", p.msgs[0].Topic)
+	assert.Equal(t, metrics[0].String(), value(t, p.msgs[0].Value))
+	assert.Equal(t, "a", value(t, p.msgs[0].Key))
+	assert.Equal(t, "telegraf", p.msgs[1].Topic)
+
+	k, p = newTestKafka()
+	k.TopicTag = "domain"
+	k.ExcludeTopicTag = true
+	k.TopicFromMeasurement = true
+	require.NoError(t, k.Write(metrics))
+	require.Len(t, p.msgs, 2)
+	assert.Equal(t, "system", p.msgs[0].Topic)
+	assert.Equal(t, "cpu,host=a value=1i 0\n", value(t, p.msgs[0].Value))
+	assert.Nil(t, p.msgs[0].Key)
+	assert.Equal(t, "cpu", p.msgs[1].Topic)
+
+	// the metric isn't modified
+	assert.True(t, metrics[0].HasTag("domain"))
+}
+
+func TestWrite_routingKey(t *testing.T) {
+	k, p := newTestKafka()
+	k.RoutingTag = "host"
+	k.RoutingKey = "{{ host }}-{{measurement}}-{{missing}}"
+	k.Headers = map[string]string{"source": "telegraf", "env": "test"}
+	require.NoError(t, k.Write([]telegraf.Metric{
+		newMetric("cpu", map[string]string{"host": "a"}, 1),
+	}))
+
+	require.Len(t, p.msgs, 1)
+	assert.Equal(t, "a-cpu-", value(t, p.msgs[0].Key))
+	assert.Equal(t, []sarama.RecordHeader{
+		{Key: []byte("env"), Value: []byte("test")},
+		{Key: []byte("source"), Value: []byte("telegraf")},
+	}, p.msgs[0].Headers)
+}
+
+func TestWrite_batch(t *testing.T) {
+	k, p := newTestKafka()
+	k.Batch = true
+	k.RoutingTag = "host"
+	k.TopicTag = "domain"
+
+	var metrics []telegraf.Metric
+	for i, host := range []string{"a", "b", "c", "d", "e", "a", "", ""} {
+		tags := map[string]string{}
+		if host != "" {
+			tags["host"] = host
+		}
+		metrics = append(metrics, newMetric("cpu", tags, int64(i)))
+	}
+	metrics = append(metrics, newMetric("cpu", map[string]string{"domain": "system", "host": "a"}, 8))
+	require.NoError(t, k.Write(metrics))
+
+	// the metrics of the messages, by topic and partition
+	expected := make(map[string]map[int32]string)
+	for _, m := range metrics {
+		topic := k.topic(m)
+		if expected[topic] == nil {
+			expected[topic] = make(map[int32]string)
+		}
+
+		buf, err := k.serializer.Serialize(m)
+		require.NoError(t, err)
+		var partition int32
+		if host, ok := m.Tags()["host"]; ok {
+			msg := &sarama.ProducerMessage{Key: sarama.StringEncoder(host)}
+			partition, err = sarama.NewHashPartitioner(topic).Partition(msg, 4)
+			require.NoError(t, err)
+		} else {
+			// the metrics without key are sent to a random partition
+			for _, msg := range p.msgs {
+				if msg.Topic == topic && strings.Contains(value(t, msg.Value), string(buf)) {
+					partition = msg.Partition
+				}
+			}
+		}
+		expected[topic][partition] += string(buf)
+	}
+
+	actual := make(map[string]map[int32]string)
+	for _, msg := range p.msgs {
+		if actual[msg.Topic] == nil {
+			actual[msg.Topic] = make(map[int32]string)
+		}
+		_, ok := actual[msg.Topic][msg.Partition]
+		require.False(t, ok, "several messages for the same partition")
+		actual[msg.Topic][msg.Partition] = value(t, msg.Value)
+	}
+	assert.Equal(t, expected, actual)
+
+	// the message of a single key keeps it
+	for _, msg := range p.msgs {
+		if msg.Topic == "system" {
+			assert.Equal(t, "a", value(t, msg.Key))
+		}
+	}
+}
+
+func TestWrite_batchMaxMessageBytes(t *testing.T) {
+	k, p := newTestKafka()
+	k.Batch = true
+	k.RoutingTag = "host"
+
+	var metrics []telegraf.Metric
+	var expected string
+	for i := 0; i < 1000; i++ {
+		m := newMetric("cpu", map[string]string{"host": "a"}, int64(i))
+		buf, err := k.serializer.Serialize(m)
+		require.NoError(t, err)
+		expected += string(buf)
+		metrics = append(metrics, m)
+	}
+	k.MaxMessageBytes = len(expected) / 10
+	require.NoError(t, k.Write(metrics))
+
+	// the metrics of the partition are split into messages below the
+	// maximum size, in order
+	require.True(t, len(p.msgs) > 10)
+	var actual string
+	for _, msg := range p.msgs {
+		assert.False(t, k.tooLarge(msg, []byte(value(t, msg.Value))))
+		assert.Equal(t, p.msgs[0].Partition, msg.Partition)
+		assert.Equal(t, "a", value(t, msg.Key))
+		actual += value(t, msg.Value)
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestWrite_dropTooLarge(t *testing.T) {
+	k, p := newTestKafka()
+	k.MaxMessageBytes = 100
+
+	large := newMetric("cpu", map[string]string{"host": strings.Repeat("a", 100)}, 1)
+	small := newMetric("cpu", nil, 2)
+	for _, batch := range []bool{false, true} {
+		p.msgs = nil
+		k.Batch = batch
+		require.NoError(t, k.Write([]telegraf.Metric{large, small}))
+
+		// the metric which can't ever be sent doesn't block the others
+		require.Len(t, p.msgs, 1)
+		buf, err := k.serializer.Serialize(small)
+		require.NoError(t, err)
+		assert.Equal(t, string(buf), value(t, p.msgs[0].Value))
+	}
+}
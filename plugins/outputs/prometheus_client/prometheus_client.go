@@ -2,30 +2,54 @@ package prometheus_client
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
 	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
 var invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 
 type MetricWithExpiration struct {
+	Name       string
 	Metric     prometheus.Metric
 	Expiration time.Time
 }
 
 type PrometheusClient struct {
-	Listen             string
-	ExpirationInterval internal.Duration `toml:"expiration_interval"`
-	server             *http.Server
+	Listen               string
+	Path                 string
+	ExpirationInterval   internal.Duration `toml:"expiration_interval"`
+	StringFieldsAsLabels bool              `toml:"string_fields_as_labels"`
+
+	BasicUsername string `toml:"basic_username"`
+	BasicPassword string `toml:"basic_password"`
+
+	// Path to the TLS certificate and key, enables TLS on the listener.
+	SSLCert string `toml:"ssl_cert"`
+	SSLKey  string `toml:"ssl_key"`
+	// Path to the CA of the certificates clients are required to present,
+	// enables TLS client authentication.
+	SSLCA string `toml:"ssl_ca"`
+
+	server   *http.Server
+	listener net.Listener
 
 	metrics map[string]*MetricWithExpiration
 
@@ -36,30 +60,96 @@ var sampleConfig = `
   ## Address to listen on
   # listen = ":9126"
 
+  ## Path to publish the metrics on.
+  # path = "/metrics"
+
   ## Interval to expire metrics and not deliver to prometheus, 0 == no expiration
   # expiration_interval = "60s"
+
+  ## Export the string fields of each metric as the labels of an additional
+  ## "<name>_info" metric, whose value is always 1. String fields are ignored
+  ## otherwise.
+  # string_fields_as_labels = false
+
+  ## Username and password required to scrape the metrics with HTTP Basic
+  ## Auth, when set.
+  # basic_username = "Foo"
+  # basic_password = "Bar"
+
+  ## TLS certificate and key, enables HTTPS.
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## CA of the certificates clients are required to present, when set.
+  # ssl_ca = "/etc/telegraf/ca.pem"
 `
 
 func (p *PrometheusClient) Start() error {
 	p.metrics = make(map[string]*MetricWithExpiration)
-	prometheus.Register(p)
 
 	if p.Listen == "" {
 		p.Listen = "localhost:9126"
 	}
+	if p.Path == "" {
+		p.Path = "/metrics"
+	}
+
+	tlsConfig, err := internal.GetServerTLSConfig(p.SSLCert, p.SSLKey, p.SSLCA)
+	if err != nil {
+		return err
+	}
+
+	// Each instance gathers its own metrics rather than registering them,
+	// so that several instances don't collide and only the metrics written
+	// to them are exposed.
+	var handler http.Handler = promhttp.HandlerFor(p, promhttp.HandlerOpts{
+		ErrorLog:      logger{},
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+	if p.BasicUsername != "" || p.BasicPassword != "" {
+		handler = p.basicAuth(handler)
+	}
 
 	mux := http.NewServeMux()
-	mux.Handle("/metrics", prometheus.Handler())
+	mux.Handle(p.Path, handler)
 
 	p.server = &http.Server{
 		Addr:    p.Listen,
 		Handler: mux,
 	}
 
-	go p.server.ListenAndServe()
+	p.listener, err = net.Listen("tcp", p.Listen)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		p.listener = tls.NewListener(p.listener, tlsConfig)
+	}
+
+	go p.server.Serve(p.listener)
 	return nil
 }
 
+func (p *PrometheusClient) basicAuth(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(p.BasicUsername)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(p.BasicPassword)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="telegraf"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// logger writes the errors of the prometheus handler to the telegraf log.
+type logger struct{}
+
+func (logger) Println(v ...interface{}) {
+	log.Printf("E! prometheus_client: %s", fmt.Sprint(v...))
+}
+
 func (p *PrometheusClient) Stop() {
 	// plugin gets cleaned up in Close() already.
 }
@@ -70,6 +160,9 @@ func (p *PrometheusClient) Connect() error {
 }
 
 func (p *PrometheusClient) Close() error {
+	if p.server == nil {
+		return nil
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	return p.server.Shutdown(ctx)
@@ -83,23 +176,72 @@ func (p *PrometheusClient) Description() string {
 	return "Configuration for the Prometheus client to spawn"
 }
 
-// Implements prometheus.Collector
-func (p *PrometheusClient) Describe(ch chan<- *prometheus.Desc) {
-	prometheus.NewGauge(prometheus.GaugeOpts{Name: "Dummy", Help: "Dummy"}).Describe(ch)
-}
-
-// Implements prometheus.Collector
-func (p *PrometheusClient) Collect(ch chan<- prometheus.Metric) {
+// Gather implements prometheus.Gatherer, grouping the metrics written by
+// name. The metrics aren't described up front, as those of a registered
+// prometheus.Collector have to be, since they are only known once written.
+func (p *PrometheusClient) Gather() ([]*dto.MetricFamily, error) {
 	p.Lock()
 	defer p.Unlock()
 
+	keys := make([]string, 0, len(p.metrics))
 	for key, m := range p.metrics {
 		if p.ExpirationInterval.Duration != 0 && time.Now().After(m.Expiration) {
 			delete(p.metrics, key)
-		} else {
-			ch <- m.Metric
+			continue
 		}
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
+
+	var names []string
+	families := make(map[string]*dto.MetricFamily)
+	var errs prometheus.MultiError
+	for _, key := range keys {
+		m := p.metrics[key]
+		pb := &dto.Metric{}
+		if err := m.Metric.Write(pb); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		mType := metricType(pb)
+		family, ok := families[m.Name]
+		if !ok {
+			family = &dto.MetricFamily{
+				Name: proto.String(m.Name),
+				Help: proto.String("Telegraf collected metric"),
+				Type: mType.Enum(),
+			}
+			families[m.Name] = family
+			names = append(names, m.Name)
+		} else if family.GetType() != mType {
+			errs = append(errs, fmt.Errorf("metric %s of type %s conflicts with the %s metrics of the same name",
+				m.Name, mType, family.GetType()))
+			continue
+		}
+		family.Metric = append(family.Metric, pb)
+	}
+
+	sort.Strings(names)
+	result := make([]*dto.MetricFamily, 0, len(names))
+	for _, name := range names {
+		result = append(result, families[name])
+	}
+	return result, errs.MaybeUnwrap()
+}
+
+func metricType(m *dto.Metric) dto.MetricType {
+	switch {
+	case m.Counter != nil:
+		return dto.MetricType_COUNTER
+	case m.Gauge != nil:
+		return dto.MetricType_GAUGE
+	case m.Summary != nil:
+		return dto.MetricType_SUMMARY
+	case m.Histogram != nil:
+		return dto.MetricType_HISTOGRAM
+	}
+	return dto.MetricType_UNTYPED
 }
 
 func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
@@ -126,6 +268,12 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 			l[k] = v
 		}
 
+		switch point.Type() {
+		case telegraf.Summary, telegraf.Histogram:
+			p.addDistribution(point, key, l)
+			continue
+		}
+
 		// Get a type if it's available, defaulting to Untyped
 		var mType prometheus.ValueType
 		switch point.Type() {
@@ -137,10 +285,17 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 			mType = prometheus.UntypedValue
 		}
 
+		var info prometheus.Labels
 		for n, val := range point.Fields() {
 			// Ignore string and bool fields.
-			switch val.(type) {
+			switch val := val.(type) {
 			case string:
+				if p.StringFieldsAsLabels {
+					if info == nil {
+						info = prometheus.Labels{}
+					}
+					info[invalidNameCharRE.ReplaceAllString(n, "_")] = val
+				}
 				continue
 			case bool:
 				continue
@@ -172,17 +327,108 @@ func (p *PrometheusClient) Write(metrics []telegraf.Metric) error {
 				log.Printf("E! Error creating prometheus metric, "+
 					"key: %s, labels: %v,\nerr: %s\n",
 					mname, l, err.Error())
+				continue
 			}
 
-			p.metrics[desc.String()] = &MetricWithExpiration{
-				Metric:     metric,
-				Expiration: time.Now().Add(p.ExpirationInterval.Duration),
+			p.addMetric(desc.String(), mname, metric)
+		}
+
+		if info != nil {
+			// tags take precedence over string fields of the same name
+			for k, v := range l {
+				info[k] = v
 			}
+			mname := key + "_info"
+			desc := prometheus.NewDesc(mname, "Telegraf collected metric", nil, info)
+			metric, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1)
+			if err != nil {
+				log.Printf("E! Error creating prometheus metric, "+
+					"key: %s, labels: %v,\nerr: %s\n",
+					mname, info, err.Error())
+				continue
+			}
+			p.addMetric(desc.String(), mname, metric)
 		}
 	}
 	return nil
 }
 
+func (p *PrometheusClient) addMetric(key, name string, metric prometheus.Metric) {
+	p.metrics[key] = &MetricWithExpiration{
+		Name:       name,
+		Metric:     metric,
+		Expiration: time.Now().Add(p.ExpirationInterval.Duration),
+	}
+}
+
+// addDistribution adds a summary or histogram metric. The fields of the point
+// are the "count" and "sum" of the observations, and either the quantiles or
+// the cumulative counts of the buckets keyed by their upper bound.
+func (p *PrometheusClient) addDistribution(
+	point telegraf.Metric,
+	mname string,
+	l prometheus.Labels,
+) {
+	var count uint64
+	var sum float64
+	values := make(map[float64]float64)
+	for n, val := range point.Fields() {
+		v, ok := toFloat(val)
+		if !ok {
+			continue
+		}
+		switch n {
+		case "count":
+			count = uint64(v)
+		case "sum":
+			sum = v
+		default:
+			bound, err := strconv.ParseFloat(n, 64)
+			if err != nil {
+				continue
+			}
+			values[bound] = v
+		}
+	}
+
+	desc := prometheus.NewDesc(mname, "Telegraf collected metric", nil, l)
+	var metric prometheus.Metric
+	var err error
+	if point.Type() == telegraf.Summary {
+		metric, err = prometheus.NewConstSummary(desc, count, sum, values)
+	} else {
+		buckets := make(map[float64]uint64, len(values))
+		for bound, v := range values {
+			// the +Inf bucket is implied by the count
+			if !math.IsInf(bound, +1) {
+				buckets[bound] = uint64(v)
+			}
+		}
+		metric, err = prometheus.NewConstHistogram(desc, count, sum, buckets)
+	}
+	if err != nil {
+		log.Printf("E! Error creating prometheus metric, "+
+			"key: %s, labels: %v,\nerr: %s\n",
+			mname, l, err.Error())
+		return
+	}
+	// distributions are keyed apart from the samples, which would replace
+	// them otherwise when having the same name and labels
+	p.addMetric("distribution "+desc.String(), mname, metric)
+}
+
+func toFloat(val interface{}) (float64, bool) {
+	switch val := val.(type) {
+	case int64:
+		return float64(val), true
+	case uint64:
+		return float64(val), true
+	case float64:
+		return val, true
+	}
+	return 0, false
+}
+
 func init() {
 	outputs.Add("prometheus_client", func() telegraf.Output {
 		return &PrometheusClient{
@@ -1,6 +1,10 @@
 package prometheus_client
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -147,6 +151,196 @@ func TestPrometheusExpireOldMetrics(t *testing.T) {
 	assert.Equal(t, 1, len(pClient.metrics))
 }
 
+func TestPrometheusWriteDistributions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	pClient := &PrometheusClient{
+		Listen:               "localhost:9128",
+		Path:                 "/custom",
+		StringFieldsAsLabels: true,
+	}
+	require.NoError(t, pClient.Start())
+	defer pClient.Close()
+
+	now := time.Now()
+	tags := map[string]string{"host": "a"}
+	hist, _ := metric.New("latency", tags,
+		map[string]interface{}{
+			"0.5":   int64(2),
+			"1":     int64(3),
+			"+Inf":  int64(4),
+			"count": int64(4),
+			"sum":   6.5,
+		}, now, telegraf.Histogram)
+	summary, _ := metric.New("duration", tags,
+		map[string]interface{}{
+			"0.5":   1.5,
+			"0.99":  3.0,
+			"count": int64(10),
+			"sum":   20.0,
+		}, now, telegraf.Summary)
+	info, _ := metric.New("service", tags,
+		map[string]interface{}{
+			"version": "1.2.3",
+			"host":    "b",
+			"uptime":  int64(42),
+		}, now)
+	require.NoError(t, pClient.Write([]telegraf.Metric{hist, summary, info}))
+
+	body := scrape(t, "http://localhost:9128/custom", "", "")
+	for _, line := range []string{
+		"# TYPE latency histogram",
+		`latency_bucket{host="a",le="0.5"} 2`,
+		`latency_bucket{host="a",le="1"} 3`,
+		`latency_bucket{host="a",le="+Inf"} 4`,
+		`latency_sum{host="a"} 6.5`,
+		`latency_count{host="a"} 4`,
+		"# TYPE duration summary",
+		`duration{host="a",quantile="0.5"} 1.5`,
+		`duration{host="a",quantile="0.99"} 3`,
+		`duration_sum{host="a"} 20`,
+		`duration_count{host="a"} 10`,
+		`service_uptime{host="a"} 42`,
+		`service_info{host="a",version="1.2.3"} 1`,
+	} {
+		assert.Contains(t, body, line)
+	}
+}
+
+func TestPrometheusDistributionKeys(t *testing.T) {
+	pClient := &PrometheusClient{metrics: make(map[string]*MetricWithExpiration)}
+
+	now := time.Now()
+	tags := map[string]string{"host": "a"}
+	summary, _ := metric.New("duration", tags,
+		map[string]interface{}{
+			"0.5":   1.5,
+			"count": int64(10),
+			"sum":   20.0,
+		}, now, telegraf.Summary)
+	sample, _ := metric.New("duration", tags,
+		map[string]interface{}{"value": 2.0}, now)
+	require.NoError(t, pClient.Write([]telegraf.Metric{summary, sample}))
+
+	// the sample doesn't replace the summary of the same name and labels
+	assert.Len(t, pClient.metrics, 2)
+
+	// but they can't be exposed together
+	families, err := pClient.Gather()
+	assert.Error(t, err)
+	require.Len(t, families, 1)
+	assert.Equal(t, "duration", families[0].GetName())
+	assert.Len(t, families[0].Metric, 1)
+}
+
+const distributions = `# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{le="0.05"} 24054
+http_request_duration_seconds_bucket{le="0.1"} 33444
+http_request_duration_seconds_bucket{le="+Inf"} 144320
+http_request_duration_seconds_sum 53423
+http_request_duration_seconds_count 144320
+# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{quantile="0.5"} 4773
+rpc_duration_seconds{quantile="0.99"} 76656
+rpc_duration_seconds_sum 1.7560473e+07
+rpc_duration_seconds_count 2693
+`
+
+func TestPrometheusDistributionsRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, distributions)
+	}))
+	defer ts.Close()
+
+	// scraped by the prometheus input
+	var acc testutil.Accumulator
+	require.NoError(t, (&prometheus.Prometheus{Urls: []string{ts.URL}}).Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.Metrics, 2)
+
+	var metrics []telegraf.Metric
+	for _, m := range acc.Metrics {
+		pt, err := metric.New(m.Measurement, m.Tags, m.Fields, m.Time, m.Type)
+		require.NoError(t, err)
+		metrics = append(metrics, pt)
+	}
+
+	// exposed by the output
+	pClient := &PrometheusClient{Listen: "localhost:9130"}
+	require.NoError(t, pClient.Start())
+	defer pClient.Close()
+	require.NoError(t, pClient.Write(metrics))
+
+	// and scraped back the same
+	var acc2 testutil.Accumulator
+	require.NoError(t, (&prometheus.Prometheus{
+		Urls: []string{"http://localhost:9130/metrics"},
+	}).Gather(&acc2))
+	require.Empty(t, acc2.Errors)
+	require.Len(t, acc2.Metrics, 2)
+
+	for _, m := range acc.Metrics {
+		m2, ok := acc2.Get(m.Measurement)
+		require.True(t, ok, m.Measurement)
+		assert.Equal(t, m.Type, m2.Type, m.Measurement)
+		assert.Equal(t, m.Fields, m2.Fields, m.Measurement)
+	}
+	m, _ := acc.Get("http_request_duration_seconds")
+	assert.Equal(t, telegraf.Histogram, m.Type)
+	m, _ = acc.Get("rpc_duration_seconds")
+	assert.Equal(t, telegraf.Summary, m.Type)
+}
+
+func TestPrometheusBasicAuth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	pClient := &PrometheusClient{
+		Listen:        "localhost:9129",
+		BasicUsername: "user",
+		BasicPassword: "pass",
+	}
+	require.NoError(t, pClient.Start())
+	defer pClient.Close()
+
+	pt, _ := metric.New("test_point", map[string]string{},
+		map[string]interface{}{"value": 1.0}, time.Now())
+	require.NoError(t, pClient.Write([]telegraf.Metric{pt}))
+
+	resp, err := http.Get("http://localhost:9129/metrics")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	body := scrape(t, "http://localhost:9129/metrics", "user", "pass")
+	assert.Contains(t, body, "test_point 1")
+	// metrics of other instances aren't exposed
+	assert.NotContains(t, body, "test_point_1")
+}
+
+func scrape(t *testing.T, url, username, password string) string {
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
 func setupPrometheus() (*PrometheusClient, *prometheus.Prometheus, error) {
 	if pTesting == nil {
 		pTesting = &PrometheusClient{Listen: "localhost:9127"}
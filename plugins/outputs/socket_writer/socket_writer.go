@@ -1,21 +1,48 @@
 package socket_writer
 
 import (
+	"crypto/tls"
 	"fmt"
+	"log"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/outputs"
 	"github.com/influxdata/telegraf/plugins/serializers"
 )
 
+const (
+	// delay before the first reconnection attempt, doubled by each failure
+	minReconnectDelay        = time.Second
+	defaultMaxReconnectDelay = time.Minute
+	defaultConnectTimeout    = 5 * time.Second
+)
+
 type SocketWriter struct {
-	Address string
+	Address           string
+	KeepAlivePeriod   *internal.Duration `toml:"keep_alive_period"`
+	MaxReconnectDelay internal.Duration  `toml:"max_reconnect_delay"`
+	ConnectTimeout    internal.Duration  `toml:"connect_timeout"`
+	MaxDatagramSize   int                `toml:"max_datagram_size"`
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
 
 	serializers.Serializer
 
 	net.Conn
+
+	reconnectDelay time.Duration
+	nextConnect    time.Time
 }
 
 func (sw *SocketWriter) Description() string {
@@ -36,6 +63,30 @@ func (sw *SocketWriter) SampleConfig() string {
   # address = "unix:///tmp/telegraf.sock"
   # address = "unixgram:///tmp/telegraf.sock"
 
+  ## Period between keep alive probes of TCP sockets, 0 disables them.
+  ## Defaults to the OS configuration.
+  # keep_alive_period = "5m"
+
+  ## Maximum delay between the reconnection attempts, which double from 1s
+  ## as they fail.
+  # max_reconnect_delay = "1m"
+
+  ## Timeout of the connection attempts, TLS handshake included.
+  # connect_timeout = "5s"
+
+  ## Maximum size of the datagrams of UDP and unixgram sockets. Metrics are
+  ## packed into datagrams up to this size, and larger metrics are split into
+  ## metrics with less fields when possible. 0 sends each metric in its own
+  ## datagram, whatever its size.
+  # max_datagram_size = 0
+
+  ## Optional SSL Config, enables TLS for stream sockets when set.
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
   ## Data format to generate.
   ## Each data format has it's own unique set of configuration options, read
   ## more about them here:
@@ -54,46 +105,213 @@ func (sw *SocketWriter) Connect() error {
 		return fmt.Errorf("invalid address: %s", sw.Address)
 	}
 
-	c, err := net.Dial(spl[0], spl[1])
+	tlsCfg, err := internal.GetTLSConfig(
+		sw.SSLCert, sw.SSLKey, sw.SSLCA, sw.InsecureSkipVerify)
+	if err != nil {
+		return err
+	}
+	if tlsCfg != nil && sw.isPacketSocket() {
+		return fmt.Errorf("TLS is not supported by packet sockets: %s", sw.Address)
+	}
+
+	timeout := sw.ConnectTimeout.Duration
+	if timeout <= 0 {
+		timeout = defaultConnectTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	c, err := dialer.Dial(spl[0], spl[1])
 	if err != nil {
 		return err
 	}
+	if err := sw.setKeepAlive(c); err != nil {
+		c.Close()
+		return err
+	}
+
+	if tlsCfg != nil {
+		// the same server name as tls.Dial
+		tlsCfg.ServerName = spl[1]
+		if host, _, err := net.SplitHostPort(spl[1]); err == nil {
+			tlsCfg.ServerName = host
+		}
+		tc := tls.Client(c, tlsCfg)
+		tc.SetDeadline(time.Now().Add(timeout))
+		if err := tc.Handshake(); err != nil {
+			c.Close()
+			return err
+		}
+		tc.SetDeadline(time.Time{})
+		c = tc
+	}
 
 	sw.Conn = c
 	return nil
 }
 
+func (sw *SocketWriter) setKeepAlive(c net.Conn) error {
+	if sw.KeepAlivePeriod == nil {
+		return nil
+	}
+	tcp, ok := c.(*net.TCPConn)
+	if !ok {
+		log.Printf("W! socket_writer: keep_alive_period is only supported by TCP sockets")
+		return nil
+	}
+	if sw.KeepAlivePeriod.Duration == 0 {
+		return tcp.SetKeepAlive(false)
+	}
+	if err := tcp.SetKeepAlive(true); err != nil {
+		return err
+	}
+	return tcp.SetKeepAlivePeriod(sw.KeepAlivePeriod.Duration)
+}
+
+// reconnect connects the socket, unless the previous attempt failed less than
+// the reconnection delay ago.
+func (sw *SocketWriter) reconnect() error {
+	if wait := sw.nextConnect.Sub(time.Now()); wait > 0 {
+		return fmt.Errorf("not connected to %s, reconnecting in %s",
+			sw.Address, wait-wait%time.Millisecond)
+	}
+
+	if err := sw.Connect(); err != nil {
+		max := sw.MaxReconnectDelay.Duration
+		if max <= 0 {
+			max = defaultMaxReconnectDelay
+		}
+		if sw.reconnectDelay == 0 {
+			sw.reconnectDelay = minReconnectDelay
+		} else {
+			sw.reconnectDelay *= 2
+		}
+		if sw.reconnectDelay > max {
+			sw.reconnectDelay = max
+		}
+		sw.nextConnect = time.Now().Add(sw.reconnectDelay)
+		return err
+	}
+
+	sw.reconnectDelay = 0
+	sw.nextConnect = time.Time{}
+	return nil
+}
+
 // Write writes the given metrics to the destination.
 // If an error is encountered, it is up to the caller to retry the same write again later.
 // Not parallel safe.
 func (sw *SocketWriter) Write(metrics []telegraf.Metric) error {
 	if sw.Conn == nil {
 		// previous write failed with permanent error and socket was closed.
-		if err := sw.Connect(); err != nil {
+		if err := sw.reconnect(); err != nil {
+			return err
+		}
+	}
+
+	// Stream sockets get the whole batch in a single write, while packet
+	// sockets get datagrams of up to MaxDatagramSize bytes.
+	if sw.isPacketSocket() {
+		return sw.writePackets(metrics)
+	}
+
+	bs, err := serializers.SerializeBatch(sw.Serializer, metrics)
+	if err != nil {
+		return err
+	}
+	if err := sw.write(bs); err != nil {
+		if sw.Conn != nil {
 			return err
 		}
+		// The peer may have closed the connection since the last write, so
+		// the batch is written again right away on a new connection.
+		if rerr := sw.reconnect(); rerr != nil {
+			return err
+		}
+		return sw.write(bs)
 	}
+	return nil
+}
 
+// writePackets packs the metrics into datagrams on metric boundaries.
+func (sw *SocketWriter) writePackets(metrics []telegraf.Metric) error {
+	max := sw.MaxDatagramSize
+	var datagram []byte
 	for _, m := range metrics {
-		bs, err := sw.Serialize(m)
+		chunks, err := sw.serializeSplit(m, max)
 		if err != nil {
 			//TODO log & keep going with remaining metrics
 			return err
 		}
-		if _, err := sw.Conn.Write(bs); err != nil {
-			//TODO log & keep going with remaining strings
-			if err, ok := err.(net.Error); !ok || !err.Temporary() {
-				// permanent error. close the connection
-				sw.Close()
-				sw.Conn = nil
+
+		for _, bs := range chunks {
+			if len(datagram) > 0 && (max <= 0 || len(datagram)+len(bs) > max) {
+				if err := sw.write(datagram); err != nil {
+					return err
+				}
+				datagram = datagram[:0]
 			}
-			return err
+			datagram = append(datagram, bs...)
 		}
 	}
 
+	if len(datagram) > 0 {
+		return sw.write(datagram)
+	}
 	return nil
 }
 
+// serializeSplit serializes the metric, split into metrics with less fields
+// when it is larger than max.
+func (sw *SocketWriter) serializeSplit(m telegraf.Metric, max int) ([][]byte, error) {
+	bs, err := sw.Serialize(m)
+	if err != nil {
+		return nil, err
+	}
+	if max <= 0 || len(bs) <= max {
+		return [][]byte{bs}, nil
+	}
+
+	var chunks [][]byte
+	for _, part := range m.Split(max) {
+		bs, err := sw.Serialize(part)
+		if err != nil {
+			return nil, err
+		}
+		if len(bs) > max {
+			log.Printf("W! socket_writer: metric %s is larger than max_datagram_size (%d > %d)",
+				m.Name(), len(bs), max)
+		}
+		chunks = append(chunks, bs)
+	}
+	return chunks, nil
+}
+
+func (sw *SocketWriter) write(bs []byte) error {
+	if _, err := sw.Conn.Write(bs); err != nil {
+		// Errors such as a connection reset by the peer are reported as
+		// temporary, but the connection is unusable all the same.
+		if err, ok := err.(net.Error); !ok || !err.Timeout() {
+			sw.Conn.Close()
+			sw.Conn = nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (sw *SocketWriter) Close() error {
+	if sw.Conn == nil {
+		return nil
+	}
+	err := sw.Conn.Close()
+	sw.Conn = nil
+	return err
+}
+
+func (sw *SocketWriter) isPacketSocket() bool {
+	return strings.HasPrefix(sw.Address, "udp") ||
+		strings.HasPrefix(sw.Address, "unixgram")
+}
+
 func newSocketWriter() *SocketWriter {
 	s, _ := serializers.NewInfluxSerializer()
 	return &SocketWriter{
@@ -3,12 +3,25 @@ package socket_writer
 import (
 	"bufio"
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io/ioutil"
+	"math/big"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -30,6 +43,85 @@ func TestSocketWriter_tcp(t *testing.T) {
 	testSocketWriter_stream(t, sw, lconn)
 }
 
+func TestSocketWriter_tls(t *testing.T) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{selfSignedCert(t)},
+	})
+	require.NoError(t, err)
+
+	sw := newSocketWriter()
+	sw.Address = "tcp://" + listener.Addr().String()
+	sw.InsecureSkipVerify = true
+
+	// the client completes the handshake as it connects
+	var lconn net.Conn
+	var lerr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lconn, lerr = listener.Accept()
+		if lerr == nil {
+			lerr = lconn.(*tls.Conn).Handshake()
+		}
+	}()
+
+	err = sw.Connect()
+	require.NoError(t, err)
+	<-done
+	require.NoError(t, lerr)
+	_, ok := sw.Conn.(*tls.Conn)
+	require.True(t, ok)
+
+	testSocketWriter_stream(t, sw, lconn)
+}
+
+func TestSocketWriter_tls_handshakeTimeout(t *testing.T) {
+	// the server accepts the connection but never answers the handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		if lconn, err := listener.Accept(); err == nil {
+			defer lconn.Close()
+			ioutil.ReadAll(lconn)
+		}
+	}()
+
+	sw := newSocketWriter()
+	sw.Address = "tcp://" + listener.Addr().String()
+	sw.InsecureSkipVerify = true
+	sw.ConnectTimeout.Duration = 100 * time.Millisecond
+
+	start := time.Now()
+	err = sw.Connect()
+	require.Error(t, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Nil(t, sw.Conn)
+}
+
+func TestSocketWriter_tls_packet(t *testing.T) {
+	sw := newSocketWriter()
+	sw.Address = "udp://127.0.0.1:8094"
+	sw.InsecureSkipVerify = true
+	assert.Error(t, sw.Connect())
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
 func TestSocketWriter_udp(t *testing.T) {
 	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
 	require.NoError(t, err)
@@ -139,14 +231,162 @@ func TestSocketWriter_Write_err(t *testing.T) {
 
 	metrics := []telegraf.Metric{testutil.TestMetric(1, "testerr")}
 
-	// close the socket to generate an error
+	// close the socket, and the listener so that it can't reconnect, to
+	// generate an error
 	lconn.Close()
-	sw.Close()
+	listener.Close()
+	sw.Conn.Close()
 	err = sw.Write(metrics)
 	require.Error(t, err)
 	assert.Nil(t, sw.Conn)
 }
 
+func TestSocketWriter_Write_peerClosed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "tcp://" + listener.Addr().String()
+	require.NoError(t, sw.Connect())
+	defer sw.Close()
+
+	lconn, err := listener.Accept()
+	require.NoError(t, err)
+	lconn.Close()
+
+	conns := make(chan net.Conn, 1)
+	go func() {
+		lconn, err := listener.Accept()
+		if err == nil {
+			conns <- lconn
+		}
+	}()
+
+	// the first write may succeed before the peer resets the connection
+	m1 := testutil.TestMetric(1, "test1")
+	require.NoError(t, sw.Write([]telegraf.Metric{m1}))
+	time.Sleep(100 * time.Millisecond)
+	m2 := testutil.TestMetric(2, "test2")
+	require.NoError(t, sw.Write([]telegraf.Metric{m2}))
+
+	var lconn2 net.Conn
+	select {
+	case lconn2 = <-conns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reconnection")
+	}
+	defer lconn2.Close()
+
+	mbsout, _ := sw.Serialize(m2)
+	scnr := bufio.NewScanner(lconn2)
+	for scnr.Scan() {
+		if scnr.Text()+"\n" == string(mbsout) {
+			return
+		}
+	}
+	t.Fatal("metric not received after reconnecting")
+}
+
+func TestSocketWriter_reconnectBackoff(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "tcp://" + addr
+	sw.MaxReconnectDelay = internal.Duration{Duration: 3 * time.Second}
+
+	metrics := []telegraf.Metric{testutil.TestMetric(1, "test")}
+	require.Error(t, sw.Write(metrics))
+	assert.Equal(t, time.Second, sw.reconnectDelay)
+
+	// no attempt until the delay is over
+	err = sw.Write(metrics)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "reconnecting in")
+	assert.Equal(t, time.Second, sw.reconnectDelay)
+
+	for _, expected := range []time.Duration{2 * time.Second, 3 * time.Second} {
+		sw.nextConnect = time.Time{}
+		require.Error(t, sw.Write(metrics))
+		assert.Equal(t, expected, sw.reconnectDelay)
+	}
+
+	// the delay is reset by a successful connection
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer listener.Close()
+	sw.nextConnect = time.Time{}
+	require.NoError(t, sw.Write(metrics))
+	assert.Equal(t, time.Duration(0), sw.reconnectDelay)
+	sw.Close()
+}
+
+func TestSocketWriter_keepAlive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	for _, period := range []time.Duration{0, time.Minute} {
+		sw := newSocketWriter()
+		sw.Address = "tcp://" + listener.Addr().String()
+		sw.KeepAlivePeriod = &internal.Duration{Duration: period}
+		require.NoError(t, sw.Connect())
+		sw.Close()
+	}
+}
+
+func TestSocketWriter_udp_maxDatagramSize(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sw := newSocketWriter()
+	sw.Address = "udp://" + listener.LocalAddr().String()
+	sw.MaxDatagramSize = 100
+	require.NoError(t, sw.Connect())
+	defer sw.Close()
+
+	fields := make(map[string]interface{})
+	for i := 0; i < 10; i++ {
+		fields[fmt.Sprintf("field%d", i)] = int64(i)
+	}
+	large, _ := metric.New("large", nil, fields, time.Unix(0, 0))
+	metrics := []telegraf.Metric{
+		testutil.TestMetric(1, "test1"),
+		testutil.TestMetric(2, "test2"),
+		large,
+		testutil.TestMetric(3, "test3"),
+	}
+	require.NoError(t, sw.Write(metrics))
+
+	// every field of the metrics is received, in datagrams of up to 100
+	// bytes holding whole metrics
+	buf := make([]byte, 1024)
+	received := make(map[string]bool)
+	for len(received) < 13 {
+		listener.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := listener.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.True(t, n <= 100, "datagram too large")
+		require.Equal(t, byte('\n'), buf[n-1])
+
+		for _, line := range strings.Split(string(buf[:n-1]), "\n") {
+			parts := strings.Split(line, " ")
+			require.Len(t, parts, 3, line)
+			name := strings.SplitN(parts[0], ",", 2)[0]
+			for _, field := range strings.Split(parts[1], ",") {
+				received[name+"."+strings.SplitN(field, "=", 2)[0]] = true
+			}
+		}
+	}
+	assert.Len(t, received, 13)
+	assert.True(t, received["large.field9"])
+	assert.True(t, received["test3.value"])
+}
+
 func TestSocketWriter_Write_reconnect(t *testing.T) {
 	listener, err := net.Listen("tcp", "127.0.0.1:0")
 	require.NoError(t, err)
@@ -0,0 +1,131 @@
+package sql
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPostgresSQL returns the output connected to the postgres container of
+// the Makefile, without the tables of the tests.
+func newPostgresSQL(t *testing.T, tables ...string) *SQL {
+	s := newSQL()
+	s.Driver = "pgx"
+	s.DataSourceName = fmt.Sprintf("host=%s user=postgres sslmode=disable",
+		testutil.GetLocalHost())
+	require.NoError(t, s.Connect())
+
+	for _, table := range tables {
+		_, err := s.db.Exec("DROP TABLE IF EXISTS " + s.quoteIdent(table))
+		require.NoError(t, err)
+	}
+	return s
+}
+
+func TestPostgresWrite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	s := newPostgresSQL(t, "telegraf_cpu")
+	defer s.Close()
+
+	now := time.Date(2018, 1, 2, 15, 4, 5, 123456000, time.UTC)
+	m1, _ := metric.New("telegraf_cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{
+			"usage":  1.5,
+			"count":  int64(-2),
+			"total":  uint64(3),
+			"online": true,
+		},
+		now)
+	m2, _ := metric.New("telegraf_cpu",
+		map[string]string{"host": "b", "zone": "eu"},
+		map[string]interface{}{"usage": 2.5},
+		now.Add(time.Second))
+	require.NoError(t, s.Write([]telegraf.Metric{m1, m2}))
+
+	rows, err := s.db.Query(`SELECT "timestamp", "host", "zone", "usage", "count", "total", "online"
+		FROM "telegraf_cpu" ORDER BY "timestamp"`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	type row struct {
+		timestamp    time.Time
+		host         string
+		zone         *string
+		usage        float64
+		count, total *int64
+		online       *bool
+	}
+	var actual []row
+	for rows.Next() {
+		var r row
+		require.NoError(t, rows.Scan(&r.timestamp, &r.host, &r.zone, &r.usage,
+			&r.count, &r.total, &r.online))
+		actual = append(actual, r)
+	}
+	require.NoError(t, rows.Err())
+	require.Len(t, actual, 2)
+
+	assert.True(t, now.Equal(actual[0].timestamp), actual[0].timestamp)
+	assert.Equal(t, "a", actual[0].host)
+	assert.Nil(t, actual[0].zone)
+	assert.Equal(t, 1.5, actual[0].usage)
+	require.NotNil(t, actual[0].count)
+	assert.Equal(t, int64(-2), *actual[0].count)
+	require.NotNil(t, actual[0].total)
+	assert.Equal(t, int64(3), *actual[0].total)
+	require.NotNil(t, actual[0].online)
+	assert.True(t, *actual[0].online)
+
+	assert.True(t, now.Add(time.Second).Equal(actual[1].timestamp), actual[1].timestamp)
+	assert.Equal(t, "b", actual[1].host)
+	require.NotNil(t, actual[1].zone)
+	assert.Equal(t, "eu", *actual[1].zone)
+	assert.Nil(t, actual[1].count)
+}
+
+func TestPostgresWrite_dropFailing(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	s := newPostgresSQL(t, "telegraf_mem")
+	defer s.Close()
+
+	now := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	m1, _ := metric.New("telegraf_mem", nil,
+		map[string]interface{}{"free": int64(1)}, now)
+	require.NoError(t, s.Write([]telegraf.Metric{m1}))
+
+	// values which can't be inserted into the existing column are dropped,
+	// rolling back to the savepoint of the metric, and the others inserted
+	m2, _ := metric.New("telegraf_mem", nil,
+		map[string]interface{}{"free": "none"}, now)
+	m3, _ := metric.New("telegraf_mem", nil,
+		map[string]interface{}{"free": uint64(math.MaxUint64)}, now)
+	m4, _ := metric.New("telegraf_mem", nil,
+		map[string]interface{}{"free": int64(4)}, now)
+	require.NoError(t, s.Write([]telegraf.Metric{m2, m3, m4}))
+
+	rows, err := s.db.Query(`SELECT "free" FROM "telegraf_mem" ORDER BY "free"`)
+	require.NoError(t, err)
+	defer rows.Close()
+	var free []int64
+	for rows.Next() {
+		var v int64
+		require.NoError(t, rows.Scan(&v))
+		free = append(free, v)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []int64{1, 4}, free)
+}
@@ -0,0 +1,146 @@
+// +build sqlite
+
+package sql
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQL(t *testing.T) (*SQL, func()) {
+	dir, err := ioutil.TempDir("", "telegraf-sql")
+	require.NoError(t, err)
+
+	s := newSQL()
+	s.Driver = "sqlite3"
+	s.DataSourceName = "file:" + filepath.Join(dir, "metrics.db")
+	require.NoError(t, s.Connect())
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// rows returns the rows of the table, without their timestamp, as maps of
+// their columns.
+func rows(t *testing.T, s *SQL, table string) []map[string]interface{} {
+	r, err := s.db.Query(`SELECT * FROM "` + table + `" ORDER BY rowid`)
+	require.NoError(t, err)
+	defer r.Close()
+
+	columns, err := r.Columns()
+	require.NoError(t, err)
+	var result []map[string]interface{}
+	for r.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		require.NoError(t, r.Scan(ptrs...))
+
+		row := make(map[string]interface{})
+		for i, column := range columns {
+			if column != s.TimestampColumn && values[i] != nil {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	require.NoError(t, r.Err())
+	return result
+}
+
+func TestWrite(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+
+	now := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	m1, _ := metric.New("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 1.5, "count": int64(2)},
+		now)
+	m2, _ := metric.New("mem",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"free": int64(1024)},
+		now)
+	require.NoError(t, s.Write([]telegraf.Metric{m1, m2}))
+
+	assert.Equal(t, []map[string]interface{}{
+		{"host": "a", "usage": 1.5, "count": int64(2)},
+	}, rows(t, s, "cpu"))
+	assert.Equal(t, []map[string]interface{}{
+		{"host": "a", "free": int64(1024)},
+	}, rows(t, s, "mem"))
+
+	var timestamp time.Time
+	require.NoError(t, s.db.QueryRow(`SELECT "timestamp" FROM "cpu"`).Scan(&timestamp))
+	assert.True(t, now.Equal(timestamp))
+
+	// new tags and fields add columns to the existing table
+	m3, _ := metric.New("cpu",
+		map[string]string{"host": "b", "cpu": "cpu0"},
+		map[string]interface{}{"usage": 2.5, "online": true},
+		now)
+	require.NoError(t, s.Write([]telegraf.Metric{m3}))
+	assert.Equal(t, []map[string]interface{}{
+		{"host": "a", "usage": 1.5, "count": int64(2)},
+		{"host": "b", "cpu": "cpu0", "usage": 2.5, "online": true},
+	}, rows(t, s, "cpu"))
+}
+
+func TestWrite_existingTable(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+	_, err := s.db.Exec(`CREATE TABLE "cpu" ("timestamp" TIMESTAMP, "host" TEXT, "usage" REAL)`)
+	require.NoError(t, err)
+
+	m, _ := metric.New("cpu",
+		map[string]string{"host": "a"},
+		map[string]interface{}{"usage": 1.5, "idle": 98.5},
+		time.Now())
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+	assert.Equal(t, []map[string]interface{}{
+		{"host": "a", "usage": 1.5, "idle": 98.5},
+	}, rows(t, s, "cpu"))
+}
+
+func TestWrite_dropFailing(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+	_, err := s.db.Exec(`CREATE TABLE "cpu" ("timestamp" TIMESTAMP, "usage" REAL CHECK ("usage" >= 0))`)
+	require.NoError(t, err)
+
+	now := time.Now()
+	m1, _ := metric.New("cpu", nil, map[string]interface{}{"usage": 1.5}, now)
+	m2, _ := metric.New("cpu", nil, map[string]interface{}{"usage": -1.0}, now)
+	m3, _ := metric.New("mem", nil, map[string]interface{}{"free": int64(1)}, now)
+
+	// the metric which can't be inserted is dropped rather than retried
+	require.NoError(t, s.Write([]telegraf.Metric{m1, m2, m3}))
+	assert.Equal(t, []map[string]interface{}{{"usage": 1.5}}, rows(t, s, "cpu"))
+	assert.Equal(t, []map[string]interface{}{{"free": int64(1)}}, rows(t, s, "mem"))
+}
+
+func TestWrite_tableDropped(t *testing.T) {
+	s, cleanup := newTestSQL(t)
+	defer cleanup()
+
+	now := time.Now()
+	m, _ := metric.New("cpu", nil, map[string]interface{}{"usage": 1.5}, now)
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+
+	// the table is created again when dropped by someone else
+	_, err := s.db.Exec(`DROP TABLE "cpu"`)
+	require.NoError(t, err)
+	require.NoError(t, s.Write([]telegraf.Metric{m}))
+	assert.Equal(t, []map[string]interface{}{{"usage": 1.5}}, rows(t, s, "cpu"))
+}
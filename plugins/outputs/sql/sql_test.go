@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumns(t *testing.T) {
+	s := newSQL()
+
+	now := time.Now()
+	m1, _ := metric.New("cpu",
+		map[string]string{"host": "a", "usage": "high"},
+		map[string]interface{}{"count": int64(1), "total": uint64(2), "online": true},
+		now)
+	m2, _ := metric.New("cpu",
+		map[string]string{"host": "b"},
+		map[string]interface{}{"count": 1.5, "usage": int64(3)},
+		now)
+
+	// the types don't depend on the order of the metrics: fields take
+	// precedence over tags, reals over integers
+	expected := map[string]map[string]string{
+		"cpu": {
+			"host":   "TEXT",
+			"usage":  "BIGINT",
+			"count":  "DOUBLE PRECISION",
+			"total":  "BIGINT",
+			"online": "BOOLEAN",
+		},
+	}
+	assert.Equal(t, expected, s.columns([]telegraf.Metric{m1, m2}))
+	assert.Equal(t, expected, s.columns([]telegraf.Metric{m2, m1}))
+}
+
+func TestConvert(t *testing.T) {
+	s := newSQL()
+	s.Convert.Integer = "INT"
+	s.Convert.Text = "VARCHAR(255)"
+
+	assert.Equal(t,
+		`CREATE TABLE IF NOT EXISTS "m" ("timestamp" TIMESTAMP, "a" INT, "b" VARCHAR(255), "c" DOUBLE PRECISION, "d" BOOLEAN)`,
+		s.createTable("m", map[string]string{
+			"a": s.columnType(kindInteger),
+			"b": s.columnType(kindText),
+			"c": s.columnType(kindReal),
+			"d": s.columnType(kindBoolean),
+		}))
+}
+
+func TestInsert(t *testing.T) {
+	s := newSQL()
+
+	now := time.Date(2018, 1, 2, 15, 4, 5, 0, time.UTC)
+	m, _ := metric.New("cpu",
+		map[string]string{"host": "a", "usage": "high"},
+		map[string]interface{}{"usage": 1.5},
+		now)
+	query, args := s.insert(m)
+	assert.Equal(t, `INSERT INTO "cpu" ("host", "timestamp", "usage") VALUES ($1, $2, $3)`, query)
+	// fields take precedence over tags of the same name
+	assert.Equal(t, []interface{}{"a", now, 1.5}, args)
+
+	s.Driver = "sqlite3"
+	query, _ = s.insert(m)
+	assert.Equal(t, `INSERT INTO "cpu" ("host", "timestamp", "usage") VALUES (?, ?, ?)`, query)
+}
+
+func TestQuoting(t *testing.T) {
+	s := newSQL()
+	assert.Equal(t, `"a""b"`, s.quoteIdent(`a"b`))
+	assert.Equal(t, "$2", s.placeholder(2))
+}
@@ -0,0 +1,409 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/plugins/outputs/socket_writer"
+)
+
+const sampleConfig = `
+  ## URL to connect to
+  # address = "tcp://127.0.0.1:514"
+  # address = "udp://127.0.0.1:514"
+  address = "tcp://127.0.0.1:6514" # required
+
+  ## Optional SSL Config, enables TLS for the tcp and unix sockets when set.
+  # ssl_ca = "/etc/telegraf/ca.pem"
+  # ssl_cert = "/etc/telegraf/cert.pem"
+  # ssl_key = "/etc/telegraf/key.pem"
+  ## Use SSL but skip chain & host verification
+  # insecure_skip_verify = false
+
+  ## Framing of the messages over the tcp and unix sockets, either
+  ## "octet-counting" (RFC5425 and RFC6587) or "non-transparent" (RFC6587).
+  # framing = "octet-counting"
+  ## Trailer of the messages with the non-transparent framing, either "LF"
+  ## or "NUL".
+  # trailer = "LF"
+
+  ## Tags or fields holding the facility and severity codes, the app name
+  ## and the message ID of the messages, which are excluded from the
+  ## structured data. The message ID defaults to the name of the metric.
+  # facility_key = "facility_code"
+  # severity_key = "severity_code"
+  # appname_key = "appname"
+  # msgid_key = "msgid"
+
+  ## Facility, severity and app name of the metrics without them.
+  # default_facility_code = 1
+  # default_severity_code = 5
+  # default_appname = "Telegraf"
+
+  ## The tags and fields starting with one of these SD-IDs, followed by the
+  ## separator, are the parameters of these structured data elements, such
+  ## as "origin_software" for the "software" parameter of "origin".
+  # sdids = ["origin", "meta"]
+  # separator = "_"
+
+  ## SD-ID of the element holding the other tags and fields, they are left
+  ## out when empty. Private SD-IDs should be suffixed with "@" and the
+  ## enterprise number of the organization.
+  # default_sdid = "default"
+`
+
+const (
+	// maximum lengths of the header fields
+	maxHostname = 255
+	maxAppname  = 48
+	maxProcid   = 128
+	maxMsgid    = 32
+	maxSdname   = 32
+)
+
+// the host of the messages is the value of the first of these tags
+var hostnameTags = []string{"hostname", "source", "host"}
+
+type Syslog struct {
+	Address string
+
+	// Path to CA file
+	SSLCA string `toml:"ssl_ca"`
+	// Path to host cert file
+	SSLCert string `toml:"ssl_cert"`
+	// Path to cert key file
+	SSLKey string `toml:"ssl_key"`
+	// Use SSL but skip chain & host verification
+	InsecureSkipVerify bool
+
+	Framing string
+	Trailer string
+
+	FacilityKey string
+	SeverityKey string
+	AppnameKey  string
+	MsgidKey    string
+
+	DefaultFacilityCode int
+	DefaultSeverityCode int
+	DefaultAppname      string
+
+	Sdids       []string
+	Separator   string
+	DefaultSdid string `toml:"default_sdid"`
+
+	sw *socket_writer.SocketWriter
+}
+
+func (s *Syslog) Description() string {
+	return "Send metrics as RFC5424 syslog messages over UDP, TCP or TLS"
+}
+
+func (s *Syslog) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Syslog) Connect() error {
+	switch s.Framing {
+	case "", "octet-counting", "non-transparent":
+	default:
+		return fmt.Errorf("syslog: invalid framing %q", s.Framing)
+	}
+	switch s.Trailer {
+	case "", "LF", "NUL":
+	default:
+		return fmt.Errorf("syslog: invalid trailer %q", s.Trailer)
+	}
+	if s.DefaultFacilityCode < 0 || s.DefaultFacilityCode > 23 {
+		return fmt.Errorf("syslog: invalid default facility code %d", s.DefaultFacilityCode)
+	}
+	if s.DefaultSeverityCode < 0 || s.DefaultSeverityCode > 7 {
+		return fmt.Errorf("syslog: invalid default severity code %d", s.DefaultSeverityCode)
+	}
+
+	// the messages are framed by Serialize
+	s.sw = &socket_writer.SocketWriter{
+		Address:            s.Address,
+		SSLCA:              s.SSLCA,
+		SSLCert:            s.SSLCert,
+		SSLKey:             s.SSLKey,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+		Serializer:         s,
+	}
+	return s.sw.Connect()
+}
+
+func (s *Syslog) Close() error {
+	if s.sw == nil {
+		return nil
+	}
+	return s.sw.Close()
+}
+
+func (s *Syslog) Write(metrics []telegraf.Metric) error {
+	return s.sw.Write(metrics)
+}
+
+// Serialize returns the syslog message of the metric, framed for the socket.
+func (s *Syslog) Serialize(m telegraf.Metric) ([]byte, error) {
+	msg := s.message(m)
+
+	switch {
+	case s.isPacketSocket():
+		// one message per datagram
+		return msg, nil
+	case s.Framing == "non-transparent":
+		if s.Trailer == "NUL" {
+			return append(msg, 0), nil
+		}
+		return append(msg, '\n'), nil
+	default:
+		return append([]byte(strconv.Itoa(len(msg))+" "), msg...), nil
+	}
+}
+
+func (s *Syslog) isPacketSocket() bool {
+	return strings.HasPrefix(s.Address, "udp") ||
+		strings.HasPrefix(s.Address, "unixgram")
+}
+
+// message formats the metric as an RFC5424 message, that is
+// "<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]".
+func (s *Syslog) message(m telegraf.Metric) []byte {
+	// tags and fields used in the header aren't part of the structured data
+	used := map[string]bool{"msg": true, "procid": true}
+	lookup := func(key string) (interface{}, bool) {
+		if key == "" {
+			return nil, false
+		}
+		if v, ok := m.Tags()[key]; ok {
+			used[key] = true
+			return v, true
+		}
+		if v, ok := m.Fields()[key]; ok {
+			used[key] = true
+			return v, true
+		}
+		return nil, false
+	}
+
+	facility := s.DefaultFacilityCode
+	if v, ok := lookup(s.FacilityKey); ok {
+		if code, ok := toCode(v, 23); ok {
+			facility = code
+		}
+	}
+	severity := s.DefaultSeverityCode
+	if v, ok := lookup(s.SeverityKey); ok {
+		if code, ok := toCode(v, 7); ok {
+			severity = code
+		}
+	}
+	appname := s.DefaultAppname
+	if v, ok := lookup(s.AppnameKey); ok {
+		appname = format(v)
+	}
+	msgid := m.Name()
+	if v, ok := lookup(s.MsgidKey); ok {
+		msgid = format(v)
+	}
+	hostname := ""
+	for _, tag := range hostnameTags {
+		if v, ok := m.Tags()[tag]; ok && v != "" {
+			hostname = v
+			used[tag] = true
+			break
+		}
+	}
+	procid := ""
+	if v, ok := m.Fields()["procid"]; ok {
+		procid = format(v)
+	} else if v, ok := m.Tags()["procid"]; ok {
+		procid = v
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s %s ",
+		facility*8+severity,
+		m.Time().UTC().Format("2006-01-02T15:04:05.999999Z07:00"),
+		headerField(hostname, maxHostname),
+		headerField(appname, maxAppname),
+		headerField(procid, maxProcid),
+		headerField(msgid, maxMsgid))
+
+	s.writeStructuredData(&buf, m, used)
+
+	if v, ok := m.Fields()["msg"]; ok {
+		buf.WriteByte(' ')
+		buf.WriteString(format(v))
+	}
+	return buf.Bytes()
+}
+
+// writeStructuredData writes the tags and fields which aren't used in the
+// header as structured data elements.
+func (s *Syslog) writeStructuredData(buf *bytes.Buffer, m telegraf.Metric, used map[string]bool) {
+	elements := make(map[string]map[string]string)
+	add := func(key string, value interface{}) {
+		if used[key] {
+			return
+		}
+		for _, sdid := range s.Sdids {
+			if prefix := sdid + s.Separator; strings.HasPrefix(key, prefix) {
+				addParam(elements, sdid, key[len(prefix):], format(value))
+				return
+			}
+		}
+		if s.DefaultSdid != "" {
+			addParam(elements, s.DefaultSdid, key, format(value))
+		}
+	}
+	for k, v := range m.Tags() {
+		add(k, v)
+	}
+	for k, v := range m.Fields() {
+		add(k, v)
+	}
+
+	if len(elements) == 0 {
+		buf.WriteByte('-')
+		return
+	}
+
+	// elements in the order of the configuration, then the default one
+	sdids := append(append([]string{}, s.Sdids...), s.DefaultSdid)
+	written := make(map[string]bool)
+	for _, sdid := range sdids {
+		params, ok := elements[sdid]
+		if !ok || written[sdid] {
+			continue
+		}
+		written[sdid] = true
+
+		buf.WriteByte('[')
+		buf.WriteString(sdName(sdid))
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(buf, ` %s="%s"`, sdName(name), escapeParamValue(params[name]))
+		}
+		buf.WriteByte(']')
+	}
+}
+
+func addParam(elements map[string]map[string]string, sdid, name, value string) {
+	if name == "" {
+		return
+	}
+	params, ok := elements[sdid]
+	if !ok {
+		params = make(map[string]string)
+		elements[sdid] = params
+	}
+	params[name] = value
+}
+
+// toCode returns the value as a facility or severity code.
+func toCode(v interface{}, max int) (int, bool) {
+	var code int
+	switch v := v.(type) {
+	case int64:
+		code = int(v)
+	case float64:
+		code = int(v)
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, false
+		}
+		code = n
+	default:
+		return 0, false
+	}
+	if code < 0 || code > max {
+		return 0, false
+	}
+	return code, true
+}
+
+func format(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// headerField returns the value as printable US-ASCII, truncated to the
+// maximum length of the field, or the nil value "-" when empty.
+func headerField(value string, max int) string {
+	value = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(value) > max {
+		value = value[:max]
+	}
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// sdName returns the SD-ID or parameter name without the characters it can't
+// contain.
+func sdName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, name)
+	if len(name) > maxSdname {
+		name = name[:maxSdname]
+	}
+	return name
+}
+
+var paramValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func escapeParamValue(value string) string {
+	return paramValueEscaper.Replace(value)
+}
+
+func newSyslog() *Syslog {
+	return &Syslog{
+		Framing:             "octet-counting",
+		Trailer:             "LF",
+		FacilityKey:         "facility_code",
+		SeverityKey:         "severity_code",
+		AppnameKey:          "appname",
+		MsgidKey:            "msgid",
+		DefaultFacilityCode: 1,
+		DefaultSeverityCode: 5,
+		DefaultAppname:      "Telegraf",
+		Separator:           "_",
+		DefaultSdid:         "default",
+	}
+}
+
+func init() {
+	outputs.Add("syslog", func() telegraf.Output { return newSyslog() })
+}
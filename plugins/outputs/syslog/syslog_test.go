@@ -0,0 +1,155 @@
+package syslog
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTime = time.Date(2018, 1, 2, 15, 4, 5, 123456789, time.UTC)
+
+func TestMessage(t *testing.T) {
+	s := newSyslog()
+
+	m, _ := metric.New("cpu",
+		map[string]string{"host": "server01", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 98.5, "count": int64(2)},
+		testTime)
+	assert.Equal(t,
+		`<13>1 2018-01-02T15:04:05.123456Z server01 Telegraf - cpu [default count="2" cpu="cpu0" usage_idle="98.5"]`,
+		string(s.message(m)))
+
+	// header fields from tags and fields
+	m, _ = metric.New("alert",
+		map[string]string{"hostname": "server02", "appname": "my app", "severity_code": "3"},
+		map[string]interface{}{
+			"facility_code": int64(4),
+			"msgid":         "ID47",
+			"procid":        int64(1234),
+			"msg":           "disk full",
+			"path":          `C:\ "quoted" ]`,
+		},
+		testTime)
+	assert.Equal(t,
+		`<35>1 2018-01-02T15:04:05.123456Z server02 my_app 1234 ID47 [default path="C:\\ \"quoted\" \]"] disk full`,
+		string(s.message(m)))
+
+	// invalid codes fall back to the defaults
+	m, _ = metric.New("alert", nil,
+		map[string]interface{}{"severity_code": int64(8), "facility_code": "kern"},
+		testTime)
+	assert.Equal(t,
+		`<13>1 2018-01-02T15:04:05.123456Z - Telegraf - alert -`,
+		string(s.message(m)))
+}
+
+func TestMessage_sdids(t *testing.T) {
+	s := newSyslog()
+	s.Sdids = []string{"origin", "meta@32473"}
+	s.DefaultSdid = ""
+
+	m, _ := metric.New("cpu",
+		map[string]string{"origin_software": "telegraf", "origin_ip": "10.0.0.1", "other": "x"},
+		map[string]interface{}{"meta@32473_sequenceId": int64(1), "value": 1.0},
+		testTime)
+	assert.Equal(t,
+		`<13>1 2018-01-02T15:04:05.123456Z - Telegraf - cpu [origin ip="10.0.0.1" software="telegraf"][meta@32473 sequenceId="1"]`,
+		string(s.message(m)))
+}
+
+func TestSerialize_framing(t *testing.T) {
+	m, _ := metric.New("cpu", nil, map[string]interface{}{"msg": "hello"}, testTime)
+	msg := `<13>1 2018-01-02T15:04:05.123456Z - Telegraf - cpu - hello`
+
+	s := newSyslog()
+	s.Address = "tcp://127.0.0.1:514"
+	b, err := s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t, "58 "+msg, string(b))
+
+	s.Framing = "non-transparent"
+	b, err = s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t, msg+"\n", string(b))
+
+	s.Trailer = "NUL"
+	b, err = s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t, msg+"\x00", string(b))
+
+	s.Address = "udp://127.0.0.1:514"
+	b, err = s.Serialize(m)
+	require.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestWrite_tcp(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := newSyslog()
+	s.Address = "tcp://" + listener.Addr().String()
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	lconn, err := listener.Accept()
+	require.NoError(t, err)
+	defer lconn.Close()
+
+	m1, _ := metric.New("cpu", nil, map[string]interface{}{"msg": "one"}, testTime)
+	m2, _ := metric.New("cpu", nil, map[string]interface{}{"msg": "two"}, testTime)
+	require.NoError(t, s.Write([]telegraf.Metric{m1, m2}))
+
+	r := bufio.NewReader(lconn)
+	for _, expected := range []string{"one", "two"} {
+		length, err := r.ReadString(' ')
+		require.NoError(t, err)
+		assert.Equal(t, "56 ", length)
+		msg := make([]byte, 56)
+		_, err = io.ReadFull(r, msg)
+		require.NoError(t, err)
+		assert.Equal(t, "<13>1 2018-01-02T15:04:05.123456Z - Telegraf - cpu - "+expected, string(msg))
+	}
+}
+
+func TestWrite_udp(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := newSyslog()
+	s.Address = "udp://" + listener.LocalAddr().String()
+	require.NoError(t, s.Connect())
+	defer s.Close()
+
+	m1, _ := metric.New("cpu", nil, map[string]interface{}{"msg": "one"}, testTime)
+	m2, _ := metric.New("cpu", nil, map[string]interface{}{"msg": "two"}, testTime)
+	require.NoError(t, s.Write([]telegraf.Metric{m1, m2}))
+
+	buf := make([]byte, 1024)
+	for _, expected := range []string{"one", "two"} {
+		n, _, err := listener.ReadFrom(buf)
+		require.NoError(t, err)
+		assert.Equal(t, "<13>1 2018-01-02T15:04:05.123456Z - Telegraf - cpu - "+expected, string(buf[:n]))
+	}
+}
+
+func TestConnect_invalid(t *testing.T) {
+	s := newSyslog()
+	s.Address = "tcp://127.0.0.1:514"
+	s.Framing = "none"
+	assert.Error(t, s.Connect())
+
+	s = newSyslog()
+	s.Address = "tcp://127.0.0.1:514"
+	s.DefaultSeverityCode = 8
+	assert.Error(t, s.Connect())
+}
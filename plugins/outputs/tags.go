@@ -0,0 +1,23 @@
+package outputs
+
+import (
+	"github.com/influxdata/telegraf"
+)
+
+// ExcludeTags returns the metric without the given tags. The metrics are kept
+// in the buffer of the output if a write fails, so a metric with any of those
+// tags is copied rather than modified.
+func ExcludeTags(m telegraf.Metric, keys ...string) telegraf.Metric {
+	copied := false
+	for _, key := range keys {
+		if key == "" || !m.HasTag(key) {
+			continue
+		}
+		if !copied {
+			m = m.Copy()
+			copied = true
+		}
+		m.RemoveTag(key)
+	}
+	return m
+}
@@ -0,0 +1,349 @@
+package dropwizard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/parsers/graphite"
+)
+
+// metricTypes maps the sections of a dropwizard MetricRegistry to the value
+// of the metric_type tag added to the metrics parsed from them.
+var metricTypes = map[string]string{
+	"counters":   "counter",
+	"gauges":     "gauge",
+	"histograms": "histogram",
+	"meters":     "meter",
+	"timers":     "timer",
+}
+
+// Parser parses json documents containing a dropwizard (codahale)
+// MetricRegistry, either at the top-level or embedded inside another
+// json object.
+type Parser struct {
+	// MetricRegistryPath is the dot separated path to the metric registry
+	// within the document. If empty the whole document is the registry.
+	MetricRegistryPath string
+
+	// TimePath is the dot separated path to the timestamp of the metrics.
+	// If empty, or if the path does not exist, the current time is used.
+	TimePath string
+	// TimeFormat is the layout, as understood by time.Parse, of the
+	// timestamp found at TimePath.
+	TimeFormat string
+
+	// TagsPath is the dot separated path to an object whose values are
+	// added as tags to every metric.
+	TagsPath string
+	// TagPathsMap maps tag names to the dot separated path of their values.
+	TagPathsMap map[string]string
+
+	DefaultTags map[string]string
+
+	templateEngine *graphite.GraphiteParser
+}
+
+// NewParser returns a Parser using the given graphite templates to extract
+// the measurement name, tags and field prefix from the dropwizard metric
+// names. Without templates, tags can be embedded in metric names using the
+// line-protocol form "name,tag1=value1,tag2=value2".
+func NewParser(separator string, templates []string) (*Parser, error) {
+	p := &Parser{
+		TimeFormat: time.RFC3339,
+	}
+	if len(templates) > 0 {
+		templateEngine, err := graphite.NewGraphiteParser(separator, templates, nil)
+		if err != nil {
+			return nil, err
+		}
+		p.templateEngine = templateEngine
+	}
+	return p, nil
+}
+
+// Parse parses the given json document into telegraf metrics, one per
+// dropwizard metric found in the registry.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	metrics := make([]telegraf.Metric, 0)
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(buf, &doc); err != nil {
+		return nil, fmt.Errorf("unable to parse dropwizard json, %s", err)
+	}
+
+	registry, err := p.lookupObject(doc, p.MetricRegistryPath)
+	if err != nil {
+		return nil, err
+	}
+	if registry == nil {
+		return nil, fmt.Errorf("metric registry not found at path %q",
+			p.MetricRegistryPath)
+	}
+
+	timestamp, err := p.parseTime(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	docTags, err := p.parseTags(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := make([]string, 0, len(metricTypes))
+	for section := range metricTypes {
+		sections = append(sections, section)
+	}
+	sort.Strings(sections)
+
+	for _, section := range sections {
+		entries, ok := registry[section].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			values, ok := entries[name].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			m, err := p.newMetric(name, metricTypes[section], values,
+				docTags, timestamp)
+			if err != nil {
+				return nil, err
+			}
+			if m != nil {
+				metrics = append(metrics, m)
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
+// ParseLine parses a single json document and returns the first metric
+// found in its registry.
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) < 1 {
+		return nil, fmt.Errorf("Can not parse the line: %s, for data format: dropwizard ", line)
+	}
+
+	return metrics[0], nil
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.DefaultTags = tags
+}
+
+func (p *Parser) newMetric(
+	name string,
+	metricType string,
+	values map[string]interface{},
+	docTags map[string]string,
+	timestamp time.Time,
+) (telegraf.Metric, error) {
+	measurement, nameTags, fieldPrefix := p.parseName(name)
+
+	tags := make(map[string]string)
+	for k, v := range p.DefaultTags {
+		tags[k] = v
+	}
+	for k, v := range docTags {
+		tags[k] = v
+	}
+	for k, v := range nameTags {
+		tags[k] = v
+	}
+	tags["metric_type"] = metricType
+
+	fields := make(map[string]interface{})
+	for key, value := range values {
+		switch v := value.(type) {
+		case float64, string, bool:
+			if fieldPrefix != "" {
+				key = fieldPrefix + "_" + key
+			}
+			fields[key] = v
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return metric.New(measurement, tags, fields, timestamp)
+}
+
+// parseName extracts the measurement name, tags and field prefix from a
+// dropwizard metric name.
+func (p *Parser) parseName(name string) (string, map[string]string, string) {
+	if p.templateEngine != nil {
+		measurement, tags, field, err := p.templateEngine.ApplyTemplate(name)
+		if err == nil && measurement != "" {
+			return measurement, tags, field
+		}
+		return name, nil, ""
+	}
+
+	parts := splitUnescaped(name, ',')
+	tags := make(map[string]string)
+	for _, part := range parts[1:] {
+		kv := splitUnescaped(part, '=')
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return name, nil, ""
+		}
+		tags[unescape(kv[0])] = unescape(kv[1])
+	}
+	return unescape(parts[0]), tags, ""
+}
+
+func (p *Parser) parseTime(doc map[string]interface{}) (time.Time, error) {
+	if p.TimePath == "" {
+		return time.Now().UTC(), nil
+	}
+
+	value, ok := lookup(doc, p.TimePath)
+	if !ok {
+		return time.Now().UTC(), nil
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("time at path %q is not a string: %v",
+			p.TimePath, value)
+	}
+
+	format := p.TimeFormat
+	if format == "" {
+		format = time.RFC3339
+	}
+	timestamp, err := time.Parse(format, str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unable to parse time %q with format %q, %s",
+			str, format, err)
+	}
+	return timestamp.UTC(), nil
+}
+
+func (p *Parser) parseTags(doc map[string]interface{}) (map[string]string, error) {
+	tags := make(map[string]string)
+
+	if p.TagsPath != "" {
+		obj, err := p.lookupObject(doc, p.TagsPath)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range obj {
+			if str, ok := toString(v); ok {
+				tags[k] = str
+			}
+		}
+	}
+
+	for tag, path := range p.TagPathsMap {
+		value, ok := lookup(doc, path)
+		if !ok {
+			continue
+		}
+		if str, ok := toString(value); ok {
+			tags[tag] = str
+		}
+	}
+
+	return tags, nil
+}
+
+// lookupObject returns the json object found at path, or nil if the path
+// does not exist.
+func (p *Parser) lookupObject(
+	doc map[string]interface{},
+	path string,
+) (map[string]interface{}, error) {
+	value, ok := lookup(doc, path)
+	if !ok {
+		return nil, nil
+	}
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("value at path %q is not a json object", path)
+	}
+	return obj, nil
+}
+
+// lookup walks the dot separated path down the json document.
+func lookup(doc map[string]interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return doc, true
+	}
+
+	var current interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func toString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64, bool:
+		return fmt.Sprint(v), true
+	}
+	return "", false
+}
+
+// splitUnescaped splits s around each instance of sep not preceded by a
+// backslash.
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescape(s string) string {
+	if strings.IndexByte(s, '\\') == -1 {
+		return s
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
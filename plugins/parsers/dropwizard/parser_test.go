@@ -0,0 +1,251 @@
+package dropwizard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validCounterJSON = `
+{
+	"version":    "3.0.0",
+	"counters" :  {
+		"measurement" : {
+			"count" : 1
+		}
+	},
+	"meters" :    {},
+	"gauges" :    {},
+	"histograms" : {},
+	"timers" :    {}
+}
+`
+
+func TestParseValidCounterJSON(t *testing.T) {
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(validCounterJSON))
+	require.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "measurement", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{
+		"count": float64(1),
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{"metric_type": "counter"}, metrics[0].Tags())
+}
+
+const validEmbeddedCounterJSON = `
+{
+	"time" : "2017-02-22T14:33:03.662+02:00",
+	"tags" : {
+		"tag1" : "green",
+		"tag2" : "yellow"
+	},
+	"metrics" : {
+		"counters" : {
+			"measurement" : {
+				"count" : 1
+			}
+		},
+		"meters" : {},
+		"gauges" : {},
+		"histograms" : {},
+		"timers" : {}
+	}
+}
+`
+
+func TestParseValidEmbeddedCounterJSON(t *testing.T) {
+	timeFormat := "2006-01-02T15:04:05Z07:00"
+	metricTime, _ := time.Parse(timeFormat, "2017-02-22T15:33:03.662+03:00")
+
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+	parser.MetricRegistryPath = "metrics"
+	parser.TagsPath = "tags"
+	parser.TimePath = "time"
+
+	metrics, err := parser.Parse([]byte(validEmbeddedCounterJSON))
+	require.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "measurement", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{
+		"count": float64(1),
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{
+		"metric_type": "counter",
+		"tag1":        "green",
+		"tag2":        "yellow",
+	}, metrics[0].Tags())
+	assert.True(t, metricTime.Equal(metrics[0].Time()))
+
+	// now test json tags through TagPathsMap
+	parser2, err := NewParser("", nil)
+	require.NoError(t, err)
+	parser2.MetricRegistryPath = "metrics"
+	parser2.TagPathsMap = map[string]string{"tag1": "tags.tag1"}
+	parser2.TimePath = "time"
+
+	metrics2, err := parser2.Parse([]byte(validEmbeddedCounterJSON))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"metric_type": "counter",
+		"tag1":        "green",
+	}, metrics2[0].Tags())
+}
+
+const validMeterJSON = `
+{
+	"version":    "3.0.0",
+	"counters" :  {},
+	"meters" :    {
+		"measurement,key=value" : {
+			"count" : 1,
+			"m15_rate" : 1.0,
+			"m1_rate" : 1.0,
+			"m5_rate" : 1.0,
+			"mean_rate" : 1.0,
+			"units" : "events/second"
+		}
+	},
+	"gauges" :    {},
+	"histograms" : {},
+	"timers" :    {}
+}
+`
+
+func TestParseValidMeterJSONWithTags(t *testing.T) {
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+
+	metrics, err := parser.Parse([]byte(validMeterJSON))
+	require.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "measurement", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{
+		"count":     float64(1),
+		"m15_rate":  float64(1),
+		"m1_rate":   float64(1),
+		"m5_rate":   float64(1),
+		"mean_rate": float64(1),
+		"units":     "events/second",
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{
+		"metric_type": "meter",
+		"key":         "value",
+	}, metrics[0].Tags())
+}
+
+const validTimerJSON = `
+{
+	"version": "3.0.0",
+	"timers" : {
+		"api.servers.web01.requests" : {
+			"count" : 1,
+			"max" : 2.0,
+			"mean" : 1.5,
+			"min" : 1.0,
+			"p50" : 1.0,
+			"p75" : 2.0,
+			"p95" : 2.0,
+			"p98" : 2.0,
+			"p99" : 2.0,
+			"p999" : 2.0,
+			"stddev" : 0.5,
+			"m15_rate" : 0.0,
+			"m1_rate" : 0.0,
+			"m5_rate" : 0.0,
+			"mean_rate" : 0.5,
+			"duration_units" : "seconds",
+			"rate_units" : "calls/second"
+		}
+	},
+	"gauges" : {
+		"api.servers.web01.queue" : {
+			"value" : 12
+		},
+		"api.servers.web01.state" : {
+			"value" : {"unsupported": "object"}
+		}
+	}
+}
+`
+
+func TestParseValidJSONWithTemplates(t *testing.T) {
+	parser, err := NewParser("_", []string{
+		"api.* .measurement.host.field",
+	})
+	require.NoError(t, err)
+	parser.SetDefaultTags(map[string]string{"dc": "eu"})
+
+	metrics, err := parser.Parse([]byte(validTimerJSON))
+	require.NoError(t, err)
+	// gauges with non scalar values are skipped
+	assert.Len(t, metrics, 2)
+
+	assert.Equal(t, "servers", metrics[0].Name())
+	assert.Equal(t, map[string]interface{}{
+		"queue_value": float64(12),
+	}, metrics[0].Fields())
+	assert.Equal(t, map[string]string{
+		"metric_type": "gauge",
+		"host":        "web01",
+		"dc":          "eu",
+	}, metrics[0].Tags())
+
+	assert.Equal(t, "servers", metrics[1].Name())
+	assert.Equal(t, float64(2), metrics[1].Fields()["requests_p99"])
+	assert.Equal(t, float64(0.5), metrics[1].Fields()["requests_mean_rate"])
+	assert.Equal(t, "seconds", metrics[1].Fields()["requests_duration_units"])
+	assert.Equal(t, map[string]string{
+		"metric_type": "timer",
+		"host":        "web01",
+		"dc":          "eu",
+	}, metrics[1].Tags())
+}
+
+func TestParseInvalidJSON(t *testing.T) {
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+
+	_, err = parser.Parse([]byte(`{"counters": {`))
+	assert.Error(t, err)
+}
+
+func TestParseMissingRegistry(t *testing.T) {
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+	parser.MetricRegistryPath = "metrics"
+
+	_, err = parser.Parse([]byte(validCounterJSON))
+	assert.Error(t, err)
+}
+
+func TestParseInvalidTime(t *testing.T) {
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+	parser.MetricRegistryPath = "metrics"
+	parser.TimePath = "time"
+	parser.TimeFormat = "2006-01-02"
+
+	_, err = parser.Parse([]byte(validEmbeddedCounterJSON))
+	assert.Error(t, err)
+}
+
+func TestParseLine(t *testing.T) {
+	parser, err := NewParser("", nil)
+	require.NoError(t, err)
+
+	metric, err := parser.ParseLine(`{"counters": {"hits": {"count": 5}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, "hits", metric.Name())
+	assert.Equal(t, map[string]interface{}{
+		"count": float64(5),
+	}, metric.Fields())
+
+	_, err = parser.ParseLine(`{"counters": {}}`)
+	assert.Error(t, err)
+}
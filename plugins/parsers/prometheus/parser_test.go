@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/influxdata/telegraf"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -163,3 +164,56 @@ func TestParseValidPrometheus(t *testing.T) {
 		metrics[0].Tags())
 
 }
+
+func TestParseNaN(t *testing.T) {
+	metrics, err := Parse([]byte(`# TYPE requests counter
+requests NaN
+# TYPE temperature untyped
+temperature NaN
+# TYPE load gauge
+load NaN
+# TYPE up gauge
+up 1
+`), http.Header{})
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "up", metrics[0].Name())
+}
+
+func TestPrometheusParserDefaultTags(t *testing.T) {
+	parser := PrometheusParser{}
+	parser.SetDefaultTags(map[string]string{
+		"host":    "localhost",
+		"handler": "default",
+	})
+
+	metrics, err := parser.Parse([]byte(validUniqueSummary))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, "http_request_duration_microseconds", metrics[0].Name())
+	assert.Equal(t, map[string]string{
+		"handler": "prometheus",
+		"host":    "localhost",
+	}, metrics[0].Tags())
+	assert.Equal(t, telegraf.Summary, metrics[0].Type())
+
+	metrics, err = parser.Parse([]byte(validUniqueCounter))
+	assert.NoError(t, err)
+	assert.Len(t, metrics, 1)
+	assert.Equal(t, telegraf.Counter, metrics[0].Type())
+}
+
+func TestPrometheusParserParseLine(t *testing.T) {
+	parser := PrometheusParser{}
+
+	metric, err := parser.ParseLine(`node_load1{cpu="all"} 0.25`)
+	assert.NoError(t, err)
+	assert.Equal(t, "node_load1", metric.Name())
+	assert.Equal(t, map[string]interface{}{
+		"value": float64(0.25),
+	}, metric.Fields())
+	assert.Equal(t, map[string]string{"cpu": "all"}, metric.Tags())
+
+	_, err = parser.ParseLine(`node_load1{cpu="all" 0.25`)
+	assert.Error(t, err)
+}
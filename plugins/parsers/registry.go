@@ -5,10 +5,12 @@ import (
 
 	"github.com/influxdata/telegraf"
 
+	"github.com/influxdata/telegraf/plugins/parsers/dropwizard"
 	"github.com/influxdata/telegraf/plugins/parsers/graphite"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/plugins/parsers/json"
 	"github.com/influxdata/telegraf/plugins/parsers/nagios"
+	"github.com/influxdata/telegraf/plugins/parsers/prometheus"
 	"github.com/influxdata/telegraf/plugins/parsers/value"
 )
 
@@ -40,12 +42,13 @@ type Parser interface {
 // Config is a struct that covers the data types needed for all parser types,
 // and can be used to instantiate _any_ of the parsers.
 type Config struct {
-	// Dataformat can be one of: json, influx, graphite, value, nagios
+	// Dataformat can be one of: json, influx, graphite, value, nagios,
+	// dropwizard, prometheus
 	DataFormat string
 
-	// Separator only applied to Graphite data.
+	// Separator only applied to Graphite & Dropwizard data.
 	Separator string
-	// Templates only apply to Graphite data.
+	// Templates only apply to Graphite & Dropwizard data.
 	Templates []string
 
 	// TagKeys only apply to JSON data
@@ -56,6 +59,22 @@ type Config struct {
 	// DataType only applies to value, this will be the type to parse value to
 	DataType string
 
+	// DropwizardMetricRegistryPath is the path of the metric registry within
+	// the Dropwizard json document, only applies to Dropwizard data.
+	DropwizardMetricRegistryPath string
+	// DropwizardTimePath is the path of the timestamp within the Dropwizard
+	// json document, only applies to Dropwizard data.
+	DropwizardTimePath string
+	// DropwizardTimeFormat is the time layout of the value found at
+	// DropwizardTimePath, only applies to Dropwizard data.
+	DropwizardTimeFormat string
+	// DropwizardTagsPath is the path of an object holding tags within the
+	// Dropwizard json document, only applies to Dropwizard data.
+	DropwizardTagsPath string
+	// DropwizardTagPathsMap maps tag names to their path within the
+	// Dropwizard json document, only applies to Dropwizard data.
+	DropwizardTagPathsMap map[string]string
+
 	// DefaultTags are the default tags that will be added to all parsed metrics.
 	DefaultTags map[string]string
 }
@@ -75,9 +94,21 @@ func NewParser(config *Config) (Parser, error) {
 		parser, err = NewInfluxParser()
 	case "nagios":
 		parser, err = NewNagiosParser()
+	case "prometheus":
+		parser, err = NewPrometheusParser(config.DefaultTags)
 	case "graphite":
 		parser, err = NewGraphiteParser(config.Separator,
 			config.Templates, config.DefaultTags)
+	case "dropwizard":
+		parser, err = NewDropwizardParser(
+			config.DropwizardMetricRegistryPath,
+			config.DropwizardTimePath,
+			config.DropwizardTimeFormat,
+			config.DropwizardTagsPath,
+			config.DropwizardTagPathsMap,
+			config.DefaultTags,
+			config.Separator,
+			config.Templates)
 	default:
 		err = fmt.Errorf("Invalid data format: %s", config.DataFormat)
 	}
@@ -101,6 +132,12 @@ func NewNagiosParser() (Parser, error) {
 	return &nagios.NagiosParser{}, nil
 }
 
+func NewPrometheusParser(defaultTags map[string]string) (Parser, error) {
+	return &prometheus.PrometheusParser{
+		DefaultTags: defaultTags,
+	}, nil
+}
+
 func NewInfluxParser() (Parser, error) {
 	return &influx.InfluxParser{}, nil
 }
@@ -124,3 +161,28 @@ func NewValueParser(
 		DefaultTags: defaultTags,
 	}, nil
 }
+
+func NewDropwizardParser(
+	metricRegistryPath string,
+	timePath string,
+	timeFormat string,
+	tagsPath string,
+	tagPathsMap map[string]string,
+	defaultTags map[string]string,
+	separator string,
+	templates []string,
+) (Parser, error) {
+	parser, err := dropwizard.NewParser(separator, templates)
+	if err != nil {
+		return nil, err
+	}
+	parser.MetricRegistryPath = metricRegistryPath
+	parser.TimePath = timePath
+	if timeFormat != "" {
+		parser.TimeFormat = timeFormat
+	}
+	parser.TagsPath = tagsPath
+	parser.TagPathsMap = tagPathsMap
+	parser.DefaultTags = defaultTags
+	return parser, nil
+}
@@ -0,0 +1,95 @@
+package carbon2
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+// carbon2 tags are separated by spaces and split on "=".
+var sanitizedChars = strings.NewReplacer(" ", "_", "=", ":")
+
+// Carbon2Serializer serializes telegraf metrics into the carbon2 format,
+// "intrinsic_tags  meta_tags value timestamp", one line per field.
+// The measurement name, field name and metric tags are all written as
+// intrinsic tags, and no meta tags are written.
+type Carbon2Serializer struct {
+}
+
+func (s *Carbon2Serializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	var buf bytes.Buffer
+
+	timestamp := strconv.FormatInt(metric.UnixNano()/1000000000, 10)
+	tags := serializeTags(metric.Tags())
+
+	fields := metric.Fields()
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, fieldName := range keys {
+		value, ok := formatValue(fields[fieldName])
+		if !ok {
+			continue
+		}
+
+		buf.WriteString("metric=")
+		buf.WriteString(sanitizedChars.Replace(metric.Name()))
+		buf.WriteString(" field=")
+		buf.WriteString(sanitizedChars.Replace(fieldName))
+		buf.WriteString(tags)
+		// two spaces separate the (empty) meta tags from the intrinsic tags
+		buf.WriteString("  ")
+		buf.WriteString(value)
+		buf.WriteByte(' ')
+		buf.WriteString(timestamp)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+func serializeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		// metric and field are reserved for the measurement and field names
+		if k == "metric" || k == "field" || tags[k] == "" {
+			continue
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(sanitizedChars.Replace(k))
+		buf.WriteByte('=')
+		buf.WriteString(sanitizedChars.Replace(tags[k]))
+	}
+	return buf.String()
+}
+
+// formatValue formats numeric and boolean field values, string fields are
+// ignored.
+func formatValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case bool:
+		if v {
+			return "1", true
+		}
+		return "0", true
+	}
+	return "", false
+}
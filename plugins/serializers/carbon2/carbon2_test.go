@@ -0,0 +1,65 @@
+package carbon2
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestSerializeMetricFloat(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"cpu": "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := Carbon2Serializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	expS := fmt.Sprintf("metric=cpu field=usage_idle cpu=cpu0  91.5 %d\n", now.Unix())
+	assert.Equal(t, expS, string(buf))
+}
+
+func TestSerializeMetricMultipleFields(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"host": "local host",
+		"cpu":  "cpu=0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": int64(90),
+		"online":     true,
+		"model":      "foobar",
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := Carbon2Serializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	expS := fmt.Sprintf("metric=cpu field=online cpu=cpu:0 host=local_host  1 %d\n", now.Unix()) +
+		fmt.Sprintf("metric=cpu field=usage_idle cpu=cpu:0 host=local_host  90 %d\n", now.Unix())
+	assert.Equal(t, expS, string(buf))
+}
+
+func TestSerializeMetricString(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"usage_idle": "foobar",
+	}
+	m, err := metric.New("cpu", map[string]string{}, fields, now)
+	assert.NoError(t, err)
+
+	s := Carbon2Serializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(buf))
+}
@@ -1,9 +1,12 @@
 package graphite
 
 import (
+	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/influxdata/telegraf"
 )
@@ -13,35 +16,142 @@ const DEFAULT_TEMPLATE = "host.tags.measurement.field"
 var (
 	fieldDeleter   = strings.NewReplacer(".FIELDNAME", "", "FIELDNAME.", "")
 	sanitizedChars = strings.NewReplacer("/", "-", "@", "-", "*", "-", " ", "_", "..", ".", `\`, "", ")", "_", "(", "_")
+	// characters that are not allowed in the tags of graphite tagged series
+	sanitizedTagNameChars  = strings.NewReplacer(";", "_", "!", "_", "^", "_", "=", "_")
+	sanitizedTagValueChars = strings.NewReplacer(";", "_")
 )
 
 type GraphiteSerializer struct {
 	Prefix   string
 	Template string
+	// TagSupport serializes metrics as graphite 1.1 tagged series,
+	// "name;tag1=value1;tag2=value2", instead of applying the Template.
+	TagSupport bool
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
 }
 
 func (s *GraphiteSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
-	out := []byte{}
+	var buf bytes.Buffer
+	s.serializeTo(&buf, metric)
+	return buf.Bytes(), nil
+}
+
+// SerializeBatch serializes all of the metrics into a single buffer, reusing
+// a pooled buffer for the serialization itself.
+func (s *GraphiteSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+	buf.Reset()
+
+	for _, metric := range metrics {
+		s.serializeTo(buf, metric)
+	}
 
+	out := make([]byte, buf.Len())
+	copy(out, buf.Bytes())
+	return out, nil
+}
+
+// serializeTo writes the points of the metric to the buffer. The parts of the
+// point names which are the same for all of the fields are built once, and
+// the fields are inserted in between, unless they have to be sanitized.
+func (s *GraphiteSerializer) serializeTo(buf *bytes.Buffer, metric telegraf.Metric) {
+	var scratch [64]byte
 	// Convert UnixNano to Unix timestamps
-	timestamp := metric.UnixNano() / 1000000000
+	timestamp := strconv.AppendInt(scratch[:0], metric.UnixNano()/1000000000, 10)
+	value := scratch[len(timestamp):len(timestamp)]
+
+	if s.TagSupport {
+		name := metric.Name()
+		if s.Prefix != "" {
+			name = s.Prefix + "." + name
+		}
+		base := sanitize(name)
+		tags := serializeTags(metric.Tags())
+		for fieldName, v := range metric.Fields() {
+			switch {
+			case fieldName == "value":
+				buf.WriteString(base)
+			case isPlainField(fieldName) && !strings.HasSuffix(base, "."):
+				buf.WriteString(base)
+				buf.WriteByte('.')
+				buf.WriteString(fieldName)
+			default:
+				buf.WriteString(sanitize(name + "." + fieldName))
+			}
+			buf.WriteString(tags)
+			writeValue(buf, value, v, timestamp)
+		}
+		return
+	}
 
 	bucket := SerializeBucketName(metric.Name(), metric.Tags(), s.Template, s.Prefix)
 	if bucket == "" {
-		return out, nil
+		return
+	}
+
+	n := strings.Index(bucket, "FIELDNAME")
+	if n < 0 {
+		name := sanitize(bucket)
+		for _, v := range metric.Fields() {
+			buf.WriteString(name)
+			writeValue(buf, value, v, timestamp)
+		}
+		return
 	}
 
-	for fieldName, value := range metric.Fields() {
-		// Convert value to string
-		valueS := fmt.Sprintf("%#v", value)
-		point := []byte(fmt.Sprintf("%s %s %d\n",
+	before := sanitize(bucket[:n])
+	after := sanitize(bucket[n+len("FIELDNAME"):])
+	for fieldName, v := range metric.Fields() {
+		if fieldName != "value" && isPlainField(fieldName) {
+			buf.WriteString(before)
+			buf.WriteString(fieldName)
+			buf.WriteString(after)
+		} else {
 			// insert "field" section of template
-			sanitizedChars.Replace(InsertField(bucket, fieldName)),
-			sanitizedChars.Replace(valueS),
-			timestamp))
-		out = append(out, point...)
+			buf.WriteString(sanitize(InsertField(bucket, fieldName)))
+		}
+		writeValue(buf, value, v, timestamp)
 	}
-	return out, nil
+}
+
+// sanitize replaces the characters which aren't allowed in point names, the
+// replacer allocates even when there's nothing to replace.
+func sanitize(s string) string {
+	if !strings.ContainsAny(s, `/@* ()\`) && !strings.Contains(s, "..") {
+		return s
+	}
+	return sanitizedChars.Replace(s)
+}
+
+// isPlainField returns whether the field name is left as is by the
+// sanitization of the point names, and can't be merged with the characters
+// around it by the sanitization either.
+func isPlainField(fieldName string) bool {
+	return !strings.ContainsAny(fieldName, `/@* ()\.`)
+}
+
+// writeValue writes the value and the timestamp of a point, scratch is used
+// to format the numbers without allocating.
+func writeValue(buf *bytes.Buffer, scratch []byte, value interface{}, timestamp []byte) {
+	buf.WriteByte(' ')
+	// Convert value to string, the same as "%#v"
+	switch v := value.(type) {
+	case float64:
+		buf.Write(strconv.AppendFloat(scratch[:0], v, 'g', -1, 64))
+	case int64:
+		buf.Write(strconv.AppendInt(scratch[:0], v, 10))
+	default:
+		buf.WriteString(sanitize(fmt.Sprintf("%#v", value)))
+	}
+	buf.WriteByte(' ')
+	buf.Write(timestamp)
+	buf.WriteByte('\n')
 }
 
 // SerializeBucketName will take the given measurement name and tags and
@@ -105,6 +215,57 @@ func SerializeBucketName(
 	return prefix + "." + strings.Join(out, ".")
 }
 
+// SerializeBucketNameWithTags will take the given measurement name, tags and
+// field name and produce a graphite tagged series name, such as
+// "prefix.measurement.field;tag1=value1;tag2=value2". Tags are sorted by key.
+// If fieldName == "value", the field is omitted from the series name.
+func SerializeBucketNameWithTags(
+	measurement string,
+	tags map[string]string,
+	prefix string,
+	fieldName string,
+) string {
+	var out string
+	if prefix != "" {
+		out = prefix + "."
+	}
+	out += measurement
+	if fieldName != "value" {
+		out += "." + fieldName
+	}
+	return sanitize(out) + serializeTags(tags)
+}
+
+// serializeTags returns the tags of a tagged series, ";tag1=value1;tag2=value2"
+// sorted by key, without the empty ones.
+func serializeTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var out string
+	for _, k := range keys {
+		v := tags[k]
+		if v == "" {
+			continue
+		}
+		tagName := sanitizedTagNameChars.Replace(sanitize(k))
+		// "name" is reserved for the series name in graphite.
+		if tagName == "name" {
+			tagName = "_name"
+		}
+		// tag values may not start with a tilde.
+		tagValue := sanitizedTagValueChars.Replace(sanitize(v))
+		if strings.HasPrefix(tagValue, "~") {
+			tagValue = "_" + tagValue[1:]
+		}
+		out += ";" + tagName + "=" + tagValue
+	}
+	return out
+}
+
 // InsertField takes the bucket string from SerializeBucketName and replaces the
 // FIELDNAME portion. If fieldName == "value", it will simply delete the
 // FIELDNAME portion.
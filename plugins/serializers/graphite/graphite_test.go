@@ -9,6 +9,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 )
 
@@ -416,3 +417,183 @@ func TestTemplate6(t *testing.T) {
 	expS := "localhost.cpu0.us-west-2.cpu.FIELDNAME"
 	assert.Equal(t, expS, mS)
 }
+
+func TestSerializeMetricWithTags(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"host":       "localhost",
+		"cpu":        "cpu0",
+		"datacenter": "us-west-2",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+		"usage_busy": float64(8.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := GraphiteSerializer{
+		TagSupport: true,
+	}
+	buf, _ := s.Serialize(m)
+	mS := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	assert.NoError(t, err)
+
+	expS := []string{
+		fmt.Sprintf("cpu.usage_idle;cpu=cpu0;datacenter=us-west-2;host=localhost 91.5 %d", now.Unix()),
+		fmt.Sprintf("cpu.usage_busy;cpu=cpu0;datacenter=us-west-2;host=localhost 8.5 %d", now.Unix()),
+	}
+	sort.Strings(mS)
+	sort.Strings(expS)
+	assert.Equal(t, expS, mS)
+}
+
+func TestSerializeMetricWithTagsAndPrefix(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"host": "localhost",
+	}
+	fields := map[string]interface{}{
+		"value": float64(91.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := GraphiteSerializer{
+		Prefix:     "prefix",
+		TagSupport: true,
+	}
+	buf, _ := s.Serialize(m)
+	mS := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	assert.NoError(t, err)
+
+	expS := []string{
+		fmt.Sprintf("prefix.cpu;host=localhost 91.5 %d", now.Unix()),
+	}
+	assert.Equal(t, expS, mS)
+}
+
+func TestSerializeBucketNameWithTagsSanitized(t *testing.T) {
+	tags := map[string]string{
+		"name":     "foo",
+		"a;b!c^d=": "e;f=g",
+		"tilde":    "~value",
+		"space":    "some value",
+	}
+	mS := SerializeBucketNameWithTags("my measurement", tags, "", "usage idle")
+
+	expS := "my_measurement.usage_idle;a_b_c_d_=e_f=g;_name=foo;space=some_value;tilde=_value"
+	assert.Equal(t, expS, mS)
+}
+
+// The field names which have to be sanitized, or the ones next to which the
+// name has to be, are inserted in the whole name before sanitizing it.
+func TestSerializeSanitizedFieldNames(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"usage idle": float64(91.5),
+		".busy":      int64(8),
+		"value":      int64(1),
+		"plain":      "a b",
+	}
+	m, err := metric.New("cpu.", map[string]string{"host": "local host"}, fields, now)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		s   GraphiteSerializer
+		exp []string
+	}{
+		{
+			GraphiteSerializer{Template: "host.measurement.field"},
+			[]string{
+				"local_host.cpu.usage_idle 91.5 %d",
+				"local_host.cpu..busy 8 %d",
+				"local_host.cpu. 1 %d",
+				`local_host.cpu.plain "a_b" %d`,
+			},
+		},
+		{
+			GraphiteSerializer{Prefix: "p", TagSupport: true},
+			[]string{
+				"p.cpu.usage_idle;host=local_host 91.5 %d",
+				"p.cpu..busy;host=local_host 8 %d",
+				"p.cpu.;host=local_host 1 %d",
+				`p.cpu.plain;host=local_host "a_b" %d`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		buf, err := tt.s.Serialize(m)
+		assert.NoError(t, err)
+		mS := strings.Split(strings.TrimSpace(string(buf)), "\n")
+
+		var expS []string
+		for _, exp := range tt.exp {
+			expS = append(expS, fmt.Sprintf(exp, now.Unix()))
+		}
+		sort.Strings(mS)
+		sort.Strings(expS)
+		assert.Equal(t, expS, mS)
+	}
+}
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Now()
+	m1, err := metric.New("cpu",
+		map[string]string{"host": "localhost", "cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		now)
+	assert.NoError(t, err)
+	m2, err := metric.New("cpu",
+		map[string]string{"host": "localhost", "cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": float64(90)},
+		now)
+	assert.NoError(t, err)
+
+	s := GraphiteSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+	assert.NoError(t, err)
+
+	expS := fmt.Sprintf("localhost.cpu0.cpu.usage_idle 91.5 %d\n", now.Unix()) +
+		fmt.Sprintf("localhost.cpu1.cpu.usage_idle 90 %d\n", now.Unix())
+	assert.Equal(t, expS, string(buf))
+}
+
+func benchmarkMetrics(b *testing.B) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 100)
+	for i := range metrics {
+		m, err := metric.New("cpu",
+			map[string]string{"cpu": fmt.Sprintf("cpu%d", i), "host": "localhost"},
+			map[string]interface{}{"usage_idle": float64(91.5), "usage_user": int64(5)},
+			time.Now())
+		if err != nil {
+			b.Fatal(err)
+		}
+		metrics[i] = m
+	}
+	return metrics
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	metrics := benchmarkMetrics(b)
+	s := GraphiteSerializer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var out []byte
+		for _, m := range metrics {
+			buf, _ := s.Serialize(m)
+			out = append(out, buf...)
+		}
+	}
+}
+
+func BenchmarkSerializeBatch(b *testing.B) {
+	metrics := benchmarkMetrics(b)
+	s := GraphiteSerializer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.SerializeBatch(metrics)
+	}
+}
@@ -10,3 +10,20 @@ type InfluxSerializer struct {
 func (s *InfluxSerializer) Serialize(m telegraf.Metric) ([]byte, error) {
 	return m.Serialize(), nil
 }
+
+// SerializeBatch serializes all of the metrics into a single buffer. The size
+// of every serialized metric is known up front, so the buffer is allocated
+// once at its final size instead of being taken from a pool and copied.
+func (s *InfluxSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	size := 0
+	for _, m := range metrics {
+		size += m.Len()
+	}
+
+	out := make([]byte, size)
+	n := 0
+	for _, m := range metrics {
+		n += m.SerializeTo(out[n:])
+	}
+	return out[:n], nil
+}
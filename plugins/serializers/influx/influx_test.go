@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 )
 
@@ -70,3 +71,64 @@ func TestSerializeMetricString(t *testing.T) {
 	expS := []string{fmt.Sprintf("cpu,cpu=cpu0 usage_idle=\"foobar\" %d", now.UnixNano())}
 	assert.Equal(t, expS, mS)
 }
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Now()
+	m1, err := metric.New("cpu",
+		map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": float64(91.5)},
+		now)
+	assert.NoError(t, err)
+	m2, err := metric.New("cpu",
+		map[string]string{"cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": float64(90)},
+		now)
+	assert.NoError(t, err)
+
+	s := InfluxSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2})
+	assert.NoError(t, err)
+
+	expS := fmt.Sprintf("cpu,cpu=cpu0 usage_idle=91.5 %d\n", now.UnixNano()) +
+		fmt.Sprintf("cpu,cpu=cpu1 usage_idle=90 %d\n", now.UnixNano())
+	assert.Equal(t, expS, string(buf))
+}
+
+func benchmarkMetrics(b *testing.B) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 100)
+	for i := range metrics {
+		m, err := metric.New("cpu",
+			map[string]string{"cpu": fmt.Sprintf("cpu%d", i), "host": "localhost"},
+			map[string]interface{}{"usage_idle": float64(91.5), "usage_user": int64(5)},
+			time.Now())
+		if err != nil {
+			b.Fatal(err)
+		}
+		metrics[i] = m
+	}
+	return metrics
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	metrics := benchmarkMetrics(b)
+	s := InfluxSerializer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var out []byte
+		for _, m := range metrics {
+			buf, _ := s.Serialize(m)
+			out = append(out, buf...)
+		}
+	}
+}
+
+func BenchmarkSerializeBatch(b *testing.B) {
+	metrics := benchmarkMetrics(b)
+	s := InfluxSerializer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.SerializeBatch(metrics)
+	}
+}
@@ -1,21 +1,91 @@
 package json
 
 import (
+	"bytes"
 	ejson "encoding/json"
+	"fmt"
+	"sync"
+	"time"
 
 	"github.com/influxdata/telegraf"
 )
 
+const (
+	// BatchFormatArray serializes a batch of metrics as a json array.
+	BatchFormatArray = "array"
+	// BatchFormatObject serializes a batch of metrics as a json object
+	// holding the array of metrics under the BatchKey.
+	BatchFormatObject = "object"
+)
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
 type JsonSerializer struct {
+	// TimestampUnits is the precision of the integer timestamp, defaults to
+	// seconds.
+	TimestampUnits time.Duration
+	// TimestampFormat is the time layout of the timestamp, when set the
+	// timestamp is written as a string instead of an integer.
+	TimestampFormat string
+
+	// Keys of the metric name, tags, fields and timestamp, defaulting to
+	// "name", "tags", "fields" and "timestamp".
+	NameKey      string
+	TagsKey      string
+	FieldsKey    string
+	TimestampKey string
+
+	// Flatten writes the tags and fields alongside the name and timestamp,
+	// instead of nesting them under the TagsKey and FieldsKey.
+	Flatten bool
+
+	// BatchFormat is one of BatchFormatArray or BatchFormatObject, and
+	// selects how SerializeBatch encloses the serialized metrics. When empty,
+	// batches are serialized as one json object per line.
+	BatchFormat string
+	// BatchKey is the key of the metrics array when BatchFormat is
+	// BatchFormatObject, defaults to "metrics".
+	BatchKey string
+}
+
+// NewSerializer returns a JsonSerializer, checking the validity of the
+// given batch format.
+func NewSerializer(
+	timestampUnits time.Duration,
+	timestampFormat string,
+	nameKey string,
+	tagsKey string,
+	fieldsKey string,
+	timestampKey string,
+	flatten bool,
+	batchFormat string,
+	batchKey string,
+) (*JsonSerializer, error) {
+	switch batchFormat {
+	case "", BatchFormatArray, BatchFormatObject:
+	default:
+		return nil, fmt.Errorf("invalid json batch format: %s", batchFormat)
+	}
+
+	return &JsonSerializer{
+		TimestampUnits:  timestampUnits,
+		TimestampFormat: timestampFormat,
+		NameKey:         nameKey,
+		TagsKey:         tagsKey,
+		FieldsKey:       fieldsKey,
+		TimestampKey:    timestampKey,
+		Flatten:         flatten,
+		BatchFormat:     batchFormat,
+		BatchKey:        batchKey,
+	}, nil
 }
 
 func (s *JsonSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
-	m := make(map[string]interface{})
-	m["tags"] = metric.Tags()
-	m["fields"] = metric.Fields()
-	m["name"] = metric.Name()
-	m["timestamp"] = metric.UnixNano() / 1000000000
-	serialized, err := ejson.Marshal(m)
+	serialized, err := ejson.Marshal(s.createObject(metric))
 	if err != nil {
 		return []byte{}, err
 	}
@@ -23,3 +93,86 @@ func (s *JsonSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
 
 	return serialized, nil
 }
+
+// SerializeBatch serializes all of the given metrics at once, enclosed as
+// configured by the BatchFormat, reusing a pooled buffer for the
+// serialization itself.
+func (s *JsonSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer bufferPool.Put(buf)
+	buf.Reset()
+
+	// the encoder terminates each value with a newline
+	enc := ejson.NewEncoder(buf)
+	switch s.BatchFormat {
+	case BatchFormatArray:
+		buf.WriteByte('[')
+	case BatchFormatObject:
+		buf.WriteByte('{')
+		if err := enc.Encode(keyOrDefault(s.BatchKey, "metrics")); err != nil {
+			return []byte{}, err
+		}
+		buf.Truncate(buf.Len() - 1)
+		buf.WriteString(":[")
+	}
+
+	for i, metric := range metrics {
+		if s.BatchFormat != "" && i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := enc.Encode(s.createObject(metric)); err != nil {
+			return []byte{}, err
+		}
+		if s.BatchFormat != "" {
+			buf.Truncate(buf.Len() - 1)
+		}
+	}
+
+	switch s.BatchFormat {
+	case BatchFormatArray:
+		buf.WriteString("]\n")
+	case BatchFormatObject:
+		buf.WriteString("]}\n")
+	}
+
+	out := make([]byte, buf.Len())
+	copy(out, buf.Bytes())
+	return out, nil
+}
+
+func (s *JsonSerializer) createObject(metric telegraf.Metric) map[string]interface{} {
+	m := make(map[string]interface{})
+	if s.Flatten {
+		for k, v := range metric.Tags() {
+			m[k] = v
+		}
+		for k, v := range metric.Fields() {
+			m[k] = v
+		}
+	} else {
+		m[keyOrDefault(s.TagsKey, "tags")] = metric.Tags()
+		m[keyOrDefault(s.FieldsKey, "fields")] = metric.Fields()
+	}
+	m[keyOrDefault(s.NameKey, "name")] = metric.Name()
+	m[keyOrDefault(s.TimestampKey, "timestamp")] = s.timestamp(metric)
+	return m
+}
+
+func (s *JsonSerializer) timestamp(metric telegraf.Metric) interface{} {
+	if s.TimestampFormat != "" {
+		return metric.Time().UTC().Format(s.TimestampFormat)
+	}
+
+	units := s.TimestampUnits
+	if units <= 0 {
+		units = time.Second
+	}
+	return metric.UnixNano() / int64(units)
+}
+
+func keyOrDefault(key, defaultKey string) string {
+	if key == "" {
+		return defaultKey
+	}
+	return key
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
 )
 
@@ -108,3 +109,164 @@ func TestSerializeMetricWithEscapes(t *testing.T) {
 	expS := []byte(fmt.Sprintf(`{"fields":{"U,age=Idle":90},"name":"My CPU","tags":{"cpu tag":"cpu0"},"timestamp":%d}`, now.Unix()) + "\n")
 	assert.Equal(t, string(expS), string(buf))
 }
+
+func TestSerializeMetricTimestampUnits(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", map[string]string{}, fields, now)
+	assert.NoError(t, err)
+
+	s := JsonSerializer{TimestampUnits: time.Millisecond}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+
+	expS := []byte(fmt.Sprintf(`{"fields":{"usage_idle":91.5},"name":"cpu","tags":{},"timestamp":%d}`, now.UnixNano()/1000000) + "\n")
+	assert.Equal(t, string(expS), string(buf))
+
+	s = JsonSerializer{TimestampUnits: time.Nanosecond}
+	buf, err = s.Serialize(m)
+	assert.NoError(t, err)
+
+	expS = []byte(fmt.Sprintf(`{"fields":{"usage_idle":91.5},"name":"cpu","tags":{},"timestamp":%d}`, now.UnixNano()) + "\n")
+	assert.Equal(t, string(expS), string(buf))
+}
+
+func TestSerializeMetricTimestampFormat(t *testing.T) {
+	now := time.Date(2017, time.March, 1, 12, 30, 15, 0, time.UTC)
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", map[string]string{}, fields, now)
+	assert.NoError(t, err)
+
+	s := JsonSerializer{TimestampFormat: time.RFC3339}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+
+	expS := `{"fields":{"usage_idle":91.5},"name":"cpu","tags":{},"timestamp":"2017-03-01T12:30:15Z"}` + "\n"
+	assert.Equal(t, expS, string(buf))
+}
+
+func TestSerializeMetricRenamedKeys(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"cpu": "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := JsonSerializer{
+		NameKey:      "measurement",
+		TagsKey:      "dimensions",
+		FieldsKey:    "values",
+		TimestampKey: "time",
+	}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+
+	expS := []byte(fmt.Sprintf(`{"dimensions":{"cpu":"cpu0"},"measurement":"cpu","time":%d,"values":{"usage_idle":91.5}}`, now.Unix()) + "\n")
+	assert.Equal(t, string(expS), string(buf))
+}
+
+func TestSerializeMetricFlatten(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"cpu": "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := JsonSerializer{Flatten: true}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+
+	expS := []byte(fmt.Sprintf(`{"cpu":"cpu0","name":"cpu","timestamp":%d,"usage_idle":91.5}`, now.Unix()) + "\n")
+	assert.Equal(t, string(expS), string(buf))
+}
+
+func TestSerializeBatch(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m1, err := metric.New("cpu", map[string]string{"cpu": "cpu0"}, fields, now)
+	assert.NoError(t, err)
+	m2, err := metric.New("cpu", map[string]string{"cpu": "cpu1"}, fields, now)
+	assert.NoError(t, err)
+	metrics := []telegraf.Metric{m1, m2}
+
+	obj1 := fmt.Sprintf(`{"fields":{"usage_idle":91.5},"name":"cpu","tags":{"cpu":"cpu0"},"timestamp":%d}`, now.Unix())
+	obj2 := fmt.Sprintf(`{"fields":{"usage_idle":91.5},"name":"cpu","tags":{"cpu":"cpu1"},"timestamp":%d}`, now.Unix())
+
+	s := JsonSerializer{}
+	buf, err := s.SerializeBatch(metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, obj1+"\n"+obj2+"\n", string(buf))
+
+	s = JsonSerializer{BatchFormat: BatchFormatArray}
+	buf, err = s.SerializeBatch(metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, "["+obj1+","+obj2+"]\n", string(buf))
+
+	s = JsonSerializer{BatchFormat: BatchFormatObject}
+	buf, err = s.SerializeBatch(metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"metrics":[`+obj1+","+obj2+"]}\n", string(buf))
+
+	s = JsonSerializer{BatchFormat: BatchFormatObject, BatchKey: "points"}
+	buf, err = s.SerializeBatch(metrics)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"points":[`+obj1+","+obj2+"]}\n", string(buf))
+}
+
+func TestNewSerializerInvalidBatchFormat(t *testing.T) {
+	_, err := NewSerializer(0, "", "", "", "", "", false, "list", "")
+	assert.Error(t, err)
+}
+
+func benchmarkMetrics(b *testing.B) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 100)
+	for i := range metrics {
+		m, err := metric.New("cpu",
+			map[string]string{"cpu": fmt.Sprintf("cpu%d", i), "host": "localhost"},
+			map[string]interface{}{"usage_idle": float64(91.5), "usage_user": int64(5)},
+			time.Now())
+		if err != nil {
+			b.Fatal(err)
+		}
+		metrics[i] = m
+	}
+	return metrics
+}
+
+func BenchmarkSerialize(b *testing.B) {
+	metrics := benchmarkMetrics(b)
+	s := JsonSerializer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		var out []byte
+		for _, m := range metrics {
+			buf, _ := s.Serialize(m)
+			out = append(out, buf...)
+		}
+	}
+}
+
+func BenchmarkSerializeBatch(b *testing.B) {
+	metrics := benchmarkMetrics(b)
+	s := JsonSerializer{}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		s.SerializeBatch(metrics)
+	}
+}
@@ -0,0 +1,292 @@
+package prometheus
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+)
+
+var (
+	invalidNameCharRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// PrometheusSerializer serializes telegraf metrics into the prometheus text
+// exposition format, one sample per numeric field, or a summary or histogram
+// for the metrics of these types.
+type PrometheusSerializer struct {
+	// ExportTimestamp adds the metric timestamp, in milliseconds, to every
+	// sample. Some consumers, such as the node_exporter textfile collector,
+	// reject samples carrying a timestamp.
+	ExportTimestamp bool
+}
+
+func (s *PrometheusSerializer) Serialize(metric telegraf.Metric) ([]byte, error) {
+	return s.SerializeBatch([]telegraf.Metric{metric})
+}
+
+// SerializeBatch serializes the samples of the metrics grouped by family,
+// each family preceded by its type, as consumers reject families split
+// across the output. The types come from the metrics, samples conflicting
+// with the type of their family are skipped, and the last sample of a
+// series replaces the previous ones.
+func (s *PrometheusSerializer) SerializeBatch(metrics []telegraf.Metric) ([]byte, error) {
+	families := make(map[string]*family)
+	var names []string
+	for _, metric := range metrics {
+		tp := metric.Type()
+		var timestamp string
+		if s.ExportTimestamp {
+			timestamp = " " + strconv.FormatInt(metric.UnixNano()/1000000, 10)
+		}
+
+		add := func(name, series, value string) {
+			f, ok := families[name]
+			if !ok {
+				f = &family{tp: tp, samples: make(map[string]string)}
+				families[name] = f
+				names = append(names, name)
+			} else if f.tp != tp {
+				return
+			}
+			f.add(series, value+timestamp)
+		}
+
+		name := sanitize(metric.Name())
+		switch tp {
+		case telegraf.Summary, telegraf.Histogram:
+			for _, sample := range distribution(name, metric) {
+				add(name, sample.series, sample.value)
+			}
+		default:
+			labels := serializeLabels(metric.Tags(), "", "")
+			fields := metric.Fields()
+			keys := make([]string, 0, len(fields))
+			for k := range fields {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				value, ok := formatValue(fields[k])
+				if !ok {
+					continue
+				}
+				sname := sampleName(name, k)
+				add(sname, sname+labels, value)
+			}
+		}
+	}
+
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		f := families[name]
+		buf.WriteString("# TYPE ")
+		buf.WriteString(name)
+		buf.WriteByte(' ')
+		buf.WriteString(typeName(f.tp))
+		buf.WriteByte('\n')
+		for _, series := range f.series {
+			buf.WriteString(series)
+			buf.WriteByte(' ')
+			buf.WriteString(f.samples[series])
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// family holds the samples of a metric family by series, the name and labels
+// of the samples, in the order of their first occurrence.
+type family struct {
+	tp      telegraf.ValueType
+	series  []string
+	samples map[string]string
+}
+
+func (f *family) add(series, sample string) {
+	if _, ok := f.samples[series]; !ok {
+		f.series = append(f.series, series)
+	}
+	f.samples[series] = sample
+}
+
+type sample struct {
+	series string
+	value  string
+}
+
+// distribution returns the samples of a summary or histogram metric, whose
+// fields are the "count" and "sum" of the observations, and either the
+// quantiles or the cumulative counts of the buckets keyed by their upper
+// bound. Histograms get a +Inf bucket from the count when they have none.
+func distribution(name string, metric telegraf.Metric) []sample {
+	label := "quantile"
+	if metric.Type() == telegraf.Histogram {
+		label = "le"
+	}
+
+	var bounds []float64
+	values := make(map[float64]string)
+	var count, sum string
+	for k, v := range metric.Fields() {
+		value, ok := formatValue(v)
+		if !ok {
+			continue
+		}
+		switch k {
+		case "count":
+			count = value
+		case "sum":
+			sum = value
+		default:
+			bound, err := strconv.ParseFloat(k, 64)
+			if err != nil {
+				continue
+			}
+			bounds = append(bounds, bound)
+			values[bound] = value
+		}
+	}
+	sort.Float64s(bounds)
+
+	// the label of the quantiles or buckets is reserved
+	tags := make(map[string]string)
+	for k, v := range metric.Tags() {
+		if sanitize(k) != label {
+			tags[k] = v
+		}
+	}
+
+	var samples []sample
+	series := name
+	if label == "le" {
+		series = name + "_bucket"
+		if _, ok := values[math.Inf(1)]; !ok && count != "" {
+			bounds = append(bounds, math.Inf(1))
+			values[math.Inf(1)] = count
+		}
+	}
+	for _, bound := range bounds {
+		bs, _ := formatValue(bound)
+		samples = append(samples, sample{
+			series + serializeLabels(tags, label, bs),
+			values[bound],
+		})
+	}
+	labels := serializeLabels(tags, "", "")
+	if sum != "" {
+		samples = append(samples, sample{name + "_sum" + labels, sum})
+	}
+	if count != "" {
+		samples = append(samples, sample{name + "_count" + labels, count})
+	}
+	return samples
+}
+
+func typeName(tp telegraf.ValueType) string {
+	switch tp {
+	case telegraf.Counter:
+		return "counter"
+	case telegraf.Gauge:
+		return "gauge"
+	case telegraf.Summary:
+		return "summary"
+	case telegraf.Histogram:
+		return "histogram"
+	}
+	return "untyped"
+}
+
+// sampleName returns the name of the prometheus sample produced for the given
+// field of a metric. The "value" field is named after the metric itself,
+// other fields are appended to the metric name.
+func sampleName(name, field string) string {
+	field = sanitize(field)
+	if field == "value" {
+		return name
+	}
+	return name + "_" + field
+}
+
+// sanitize replaces the characters not allowed in prometheus metric and
+// label names.
+func sanitize(name string) string {
+	name = invalidNameCharRE.ReplaceAllString(name, "_")
+	if len(name) > 0 && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// serializeLabels returns the labels of the tags, along with the extra label
+// when given, which takes precedence over a tag of the same name.
+func serializeLabels(tags map[string]string, extra, extraValue string) string {
+	if len(tags) == 0 && extra == "" {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for _, k := range keys {
+		name := sanitize(k)
+		if len(name) == 0 || name == extra {
+			continue
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(name)
+		buf.WriteString(`="`)
+		buf.WriteString(labelValueEscaper.Replace(tags[k]))
+		buf.WriteByte('"')
+	}
+	if extra != "" {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(extra)
+		buf.WriteString(`="`)
+		buf.WriteString(extraValue)
+		buf.WriteByte('"')
+	}
+	if buf.Len() == 1 {
+		return ""
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+// formatValue formats numeric field values, string and bool fields are
+// ignored.
+func formatValue(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		switch {
+		case math.IsInf(v, 1):
+			return "+Inf", true
+		case math.IsInf(v, -1):
+			return "-Inf", true
+		case math.IsNaN(v):
+			return "NaN", true
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,167 @@
+package prometheus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestSerializeMetricFloat(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"cpu": "cpu0",
+	}
+	fields := map[string]interface{}{
+		"usage_idle": float64(91.5),
+	}
+	m, err := metric.New("cpu", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := PrometheusSerializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, "# TYPE cpu_usage_idle untyped\n"+
+		"cpu_usage_idle{cpu=\"cpu0\"} 91.5\n", string(buf))
+}
+
+func TestSerializeMetricWithTimestamp(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"usage_idle": int64(90),
+	}
+	m, err := metric.New("cpu", map[string]string{}, fields, now)
+	assert.NoError(t, err)
+
+	s := PrometheusSerializer{ExportTimestamp: true}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	expS := fmt.Sprintf("# TYPE cpu_usage_idle untyped\ncpu_usage_idle 90 %d\n",
+		now.UnixNano()/1000000)
+	assert.Equal(t, expS, string(buf))
+}
+
+func TestSerializeMetricValueField(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"value": float64(1),
+	}
+	m, err := metric.New("node.up", map[string]string{}, fields, now)
+	assert.NoError(t, err)
+
+	s := PrometheusSerializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, "# TYPE node_up untyped\nnode_up 1\n", string(buf))
+}
+
+func TestSerializeMetricMultipleFields(t *testing.T) {
+	now := time.Now()
+	tags := map[string]string{
+		"host":      "local\"host",
+		"2nd-label": "a\\b",
+	}
+	fields := map[string]interface{}{
+		"used":   int64(10),
+		"free":   uint64(20),
+		"total":  float64(30.5),
+		"name":   "foobar",
+		"active": true,
+	}
+	m, err := metric.New("mem", tags, fields, now)
+	assert.NoError(t, err)
+
+	s := PrometheusSerializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	labels := `{_2nd_label="a\\b",host="local\"host"}`
+	expS := "# TYPE mem_free untyped\n" +
+		"mem_free" + labels + " 20\n" +
+		"# TYPE mem_total untyped\n" +
+		"mem_total" + labels + " 30.5\n" +
+		"# TYPE mem_used untyped\n" +
+		"mem_used" + labels + " 10\n"
+	assert.Equal(t, expS, string(buf))
+}
+
+func TestSerializeMetricString(t *testing.T) {
+	now := time.Now()
+	fields := map[string]interface{}{
+		"usage_idle": "foobar",
+	}
+	m, err := metric.New("cpu", map[string]string{}, fields, now)
+	assert.NoError(t, err)
+
+	s := PrometheusSerializer{}
+	buf, err := s.Serialize(m)
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(buf))
+}
+
+func TestSerializeBatchFamilies(t *testing.T) {
+	now := time.Now()
+	m1, _ := metric.New("cpu", map[string]string{"cpu": "cpu0"},
+		map[string]interface{}{"usage_idle": 91.5, "time_user": int64(10)}, now)
+	m2, _ := metric.New("disk", map[string]string{},
+		map[string]interface{}{"used": int64(5)}, now, telegraf.Gauge)
+	m3, _ := metric.New("cpu", map[string]string{"cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": 80.0}, now)
+	// the same series as m3, replacing it
+	m4, _ := metric.New("cpu", map[string]string{"cpu": "cpu1"},
+		map[string]interface{}{"usage_idle": 85.0}, now)
+	// conflicting with the type of the family
+	m5, _ := metric.New("disk", map[string]string{"path": "/"},
+		map[string]interface{}{"used": int64(6)}, now, telegraf.Counter)
+
+	s := PrometheusSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{m1, m2, m3, m4, m5})
+	assert.NoError(t, err)
+	assert.Equal(t, "# TYPE cpu_time_user untyped\n"+
+		"cpu_time_user{cpu=\"cpu0\"} 10\n"+
+		"# TYPE cpu_usage_idle untyped\n"+
+		"cpu_usage_idle{cpu=\"cpu0\"} 91.5\n"+
+		"cpu_usage_idle{cpu=\"cpu1\"} 85\n"+
+		"# TYPE disk_used gauge\n"+
+		"disk_used 5\n", string(buf))
+}
+
+func TestSerializeDistributions(t *testing.T) {
+	now := time.Now()
+	summary, _ := metric.New("rpc_duration", map[string]string{"host": "a"},
+		map[string]interface{}{
+			"0.99":  76.5,
+			"0.5":   47.0,
+			"count": int64(2693),
+			"sum":   1.75e+07,
+		}, now, telegraf.Summary)
+	histogram, _ := metric.New("latency", map[string]string{"le": "tag"},
+		map[string]interface{}{
+			"0.1":   int64(3),
+			"0.05":  int64(2),
+			"count": int64(4),
+			"sum":   0.25,
+		}, now, telegraf.Histogram)
+	counter, _ := metric.New("requests", map[string]string{},
+		map[string]interface{}{"total": int64(1027)}, now, telegraf.Counter)
+
+	s := PrometheusSerializer{}
+	buf, err := s.SerializeBatch([]telegraf.Metric{summary, histogram, counter})
+	assert.NoError(t, err)
+	assert.Equal(t, "# TYPE latency histogram\n"+
+		"latency_bucket{le=\"0.05\"} 2\n"+
+		"latency_bucket{le=\"0.1\"} 3\n"+
+		"latency_bucket{le=\"+Inf\"} 4\n"+
+		"latency_sum 0.25\n"+
+		"latency_count 4\n"+
+		"# TYPE requests_total counter\n"+
+		"requests_total 1027\n"+
+		"# TYPE rpc_duration summary\n"+
+		"rpc_duration{host=\"a\",quantile=\"0.5\"} 47\n"+
+		"rpc_duration{host=\"a\",quantile=\"0.99\"} 76.5\n"+
+		"rpc_duration_sum{host=\"a\"} 1.75e+07\n"+
+		"rpc_duration_count{host=\"a\"} 2693\n", string(buf))
+}
@@ -1,11 +1,15 @@
 package serializers
 
 import (
+	"time"
+
 	"github.com/influxdata/telegraf"
 
+	"github.com/influxdata/telegraf/plugins/serializers/carbon2"
 	"github.com/influxdata/telegraf/plugins/serializers/graphite"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/plugins/serializers/json"
+	"github.com/influxdata/telegraf/plugins/serializers/prometheus"
 )
 
 // SerializerOutput is an interface for output plugins that are able to
@@ -24,10 +28,40 @@ type Serializer interface {
 	Serialize(metric telegraf.Metric) ([]byte, error)
 }
 
+// BatchSerializer is an optional interface for serializers able to serialize
+// a whole batch of metrics at once. This is more efficient than serializing
+// metric by metric, and allows formats to enclose a batch in an envelope,
+// such as a json array. Outputs writing batches should detect it using
+// SerializeBatch.
+type BatchSerializer interface {
+	// SerializeBatch takes a batch of telegraf metrics and turns them into a
+	// single byte buffer.
+	SerializeBatch(metrics []telegraf.Metric) ([]byte, error)
+}
+
+// SerializeBatch serializes the given metrics into a single byte buffer,
+// using SerializeBatch if the serializer is a BatchSerializer, and falling
+// back to serializing metric by metric otherwise.
+func SerializeBatch(s Serializer, metrics []telegraf.Metric) ([]byte, error) {
+	if bs, ok := s.(BatchSerializer); ok {
+		return bs.SerializeBatch(metrics)
+	}
+
+	var out []byte
+	for _, metric := range metrics {
+		buf, err := s.Serialize(metric)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, buf...)
+	}
+	return out, nil
+}
+
 // Config is a struct that covers the data types needed for all serializer types,
 // and can be used to instantiate _any_ of the serializers.
 type Config struct {
-	// Dataformat can be one of: influx, graphite
+	// Dataformat can be one of: influx, graphite, json, prometheus, carbon2
 	DataFormat string
 
 	// Prefix to add to all measurements, only supports Graphite
@@ -36,6 +70,29 @@ type Config struct {
 	// Template for converting telegraf metrics into Graphite
 	// only supports Graphite
 	Template string
+
+	// Serialize metrics as Graphite tagged series, only supports Graphite
+	GraphiteTagSupport bool
+
+	// Include the metric timestamp in every sample, only supports Prometheus
+	PrometheusExportTimestamp bool
+
+	// Precision of the integer timestamp, only supports JSON
+	JSONTimestampUnits time.Duration
+	// Time layout of the timestamp, written as a string when set,
+	// only supports JSON
+	JSONTimestampFormat string
+	// Keys of the name, tags, fields and timestamp, only supports JSON
+	JSONNameKey      string
+	JSONTagsKey      string
+	JSONFieldsKey    string
+	JSONTimestampKey string
+	// Write tags and fields at the top-level, only supports JSON
+	JSONFlatten bool
+	// Enclosing of batches, "array" or "object", only supports JSON
+	JSONBatchFormat string
+	// Key of the metrics array of "object" batches, only supports JSON
+	JSONBatchKey string
 }
 
 // NewSerializer a Serializer interface based on the given config.
@@ -46,24 +103,54 @@ func NewSerializer(config *Config) (Serializer, error) {
 	case "influx":
 		serializer, err = NewInfluxSerializer()
 	case "graphite":
-		serializer, err = NewGraphiteSerializer(config.Prefix, config.Template)
+		serializer, err = NewGraphiteSerializer(config.Prefix, config.Template,
+			config.GraphiteTagSupport)
 	case "json":
-		serializer, err = NewJsonSerializer()
+		serializer, err = NewJsonSerializer(config.JSONTimestampUnits,
+			config.JSONTimestampFormat, config.JSONNameKey, config.JSONTagsKey,
+			config.JSONFieldsKey, config.JSONTimestampKey, config.JSONFlatten,
+			config.JSONBatchFormat, config.JSONBatchKey)
+	case "prometheus":
+		serializer, err = NewPrometheusSerializer(config.PrometheusExportTimestamp)
+	case "carbon2":
+		serializer, err = NewCarbon2Serializer()
 	}
 	return serializer, err
 }
 
-func NewJsonSerializer() (Serializer, error) {
-	return &json.JsonSerializer{}, nil
+func NewJsonSerializer(
+	timestampUnits time.Duration,
+	timestampFormat string,
+	nameKey string,
+	tagsKey string,
+	fieldsKey string,
+	timestampKey string,
+	flatten bool,
+	batchFormat string,
+	batchKey string,
+) (Serializer, error) {
+	return json.NewSerializer(timestampUnits, timestampFormat, nameKey,
+		tagsKey, fieldsKey, timestampKey, flatten, batchFormat, batchKey)
+}
+
+func NewPrometheusSerializer(exportTimestamp bool) (Serializer, error) {
+	return &prometheus.PrometheusSerializer{
+		ExportTimestamp: exportTimestamp,
+	}, nil
 }
 
 func NewInfluxSerializer() (Serializer, error) {
 	return &influx.InfluxSerializer{}, nil
 }
 
-func NewGraphiteSerializer(prefix, template string) (Serializer, error) {
+func NewCarbon2Serializer() (Serializer, error) {
+	return &carbon2.Carbon2Serializer{}, nil
+}
+
+func NewGraphiteSerializer(prefix, template string, tagSupport bool) (Serializer, error) {
 	return &graphite.GraphiteSerializer{
-		Prefix:   prefix,
-		Template: template,
+		Prefix:     prefix,
+		Template:   template,
+		TagSupport: tagSupport,
 	}, nil
 }